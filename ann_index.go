@@ -0,0 +1,246 @@
+package face
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// annProbes is the number of inverted lists scanned per query. Higher values
+// raise recall at the cost of scanning more entries.
+const annProbes = 8
+
+// annTrainIterations is the number of k-means iterations used to train the
+// coarse quantizer
+const annTrainIterations = 8
+
+// annMaxTrainingEntries caps the entries sampled to train the coarse
+// quantizer so building stays fast on large galleries
+const annMaxTrainingEntries = 10000
+
+// annEntry is an indexed unit vector tagged with the person it belongs to
+type annEntry struct {
+	personID string
+	vector   []float32
+}
+
+// annIndex is an inverted-file (IVF) index over person embeddings used to
+// pick candidate persons without scanning the whole gallery.
+//
+// Entries are partitioned into about sqrt(N) lists by a k-means coarse
+// quantizer. A query only scans the annProbes lists whose centroids are
+// closest to it; the persons found there are then scored exactly by the
+// recognizer. Changes are applied incrementally, and the quantizer is
+// retrained once the index has doubled in size since it was trained.
+type annIndex struct {
+	syncMu sync.Mutex // Serializes building and refreshing (see FaceRecognizer.syncANN)
+
+	mu          sync.Mutex
+	built       bool
+	coarse      [][]float32         // Unit-length list centroids
+	lists       [][]annEntry        // Entries per coarse centroid
+	where       map[string][]int    // Lists holding each person's entries
+	dirty       map[string]struct{} // Persons whose entries must be refreshed
+	size        int
+	trainedSize int
+}
+
+// markDirty schedules a person's entries to be refreshed before the next query
+func (idx *annIndex) markDirty(personID string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.dirty == nil {
+		idx.dirty = make(map[string]struct{})
+	}
+	idx.dirty[personID] = struct{}{}
+}
+
+// reset drops the index; it is rebuilt on the next query
+func (idx *annIndex) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.built = false
+	idx.coarse = nil
+	idx.lists = nil
+	idx.where = nil
+	idx.size = 0
+	idx.trainedSize = 0
+}
+
+// needsBuild reports whether the quantizer must be (re)trained
+func (idx *annIndex) needsBuild() bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return !idx.built || idx.size > 2*idx.trainedSize
+}
+
+// takeDirty returns and clears the persons marked dirty
+func (idx *annIndex) takeDirty() []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	ids := make([]string, 0, len(idx.dirty))
+	for id := range idx.dirty {
+		ids = append(ids, id)
+	}
+	idx.dirty = nil
+
+	return ids
+}
+
+// build trains the coarse quantizer on entries and assigns every entry
+func (idx *annIndex) build(entries []annEntry) {
+	nlist := max(1, int(math.Sqrt(float64(len(entries)))))
+	coarse := trainCoarse(entries, nlist)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.built = true
+	idx.coarse = coarse
+	idx.lists = make([][]annEntry, len(coarse))
+	idx.where = make(map[string][]int)
+	idx.size = 0
+	idx.trainedSize = len(entries)
+	for _, entry := range entries {
+		idx.insert(entry)
+	}
+}
+
+// replace swaps the entries of a person; no entries removes the person
+func (idx *annIndex) replace(personID string, entries []annEntry) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if !idx.built {
+		return
+	}
+
+	for _, l := range idx.where[personID] {
+		kept := idx.lists[l][:0]
+		for _, entry := range idx.lists[l] {
+			if entry.personID != personID {
+				kept = append(kept, entry)
+			} else {
+				idx.size--
+			}
+		}
+		idx.lists[l] = kept
+	}
+	delete(idx.where, personID)
+
+	for _, entry := range entries {
+		idx.insert(entry)
+	}
+}
+
+// insert adds an entry to its nearest list. The caller must hold idx.mu.
+func (idx *annIndex) insert(entry annEntry) {
+	l := nearestCentroid(idx.coarse, entry.vector)
+	if l < 0 {
+		return
+	}
+
+	idx.lists[l] = append(idx.lists[l], entry)
+	if lists := idx.where[entry.personID]; len(lists) == 0 || lists[len(lists)-1] != l {
+		idx.where[entry.personID] = append(lists, l)
+	}
+	idx.size++
+}
+
+// candidates returns the IDs of persons with entries in the lists nearest to
+// feature. ok is false when the index cannot narrow the search (not built,
+// empty, or so small that every list would be probed anyway).
+func (idx *annIndex) candidates(feature []float32) (ids []string, ok bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if !idx.built || idx.size == 0 || len(idx.coarse) <= annProbes {
+		return nil, false
+	}
+
+	query := normalizeFeature(feature)
+	order := make([]int, len(idx.coarse))
+	scores := make([]float32, len(idx.coarse))
+	for l, centroid := range idx.coarse {
+		order[l] = l
+		scores[l] = dotProduct(query, centroid)
+	}
+	sort.Slice(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	seen := make(map[string]bool)
+	for _, l := range order[:annProbes] {
+		for _, entry := range idx.lists[l] {
+			if !seen[entry.personID] {
+				seen[entry.personID] = true
+				ids = append(ids, entry.personID)
+			}
+		}
+	}
+
+	return ids, true
+}
+
+// trainCoarse runs k-means (on the unit sphere) over an evenly strided
+// sample of entries and returns nlist unit-length centroids
+func trainCoarse(entries []annEntry, nlist int) [][]float32 {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	step := max(1, len(entries)/annMaxTrainingEntries)
+	sample := make([][]float32, 0, len(entries)/step+1)
+	for i := 0; i < len(entries); i += step {
+		sample = append(sample, entries[i].vector)
+	}
+	nlist = min(nlist, len(sample))
+
+	// Seed with evenly spaced samples so builds are deterministic
+	coarse := make([][]float32, nlist)
+	for c := range coarse {
+		coarse[c] = append([]float32(nil), sample[c*len(sample)/nlist]...)
+	}
+
+	dim := len(coarse[0])
+	for iter := 0; iter < annTrainIterations; iter++ {
+		sums := make([][]float32, nlist)
+		for c := range sums {
+			sums[c] = make([]float32, dim)
+		}
+
+		for _, v := range sample {
+			if len(v) != dim {
+				continue
+			}
+			c := nearestCentroid(coarse, v)
+			for i, x := range v {
+				sums[c][i] += x
+			}
+		}
+
+		// Empty clusters keep their previous centroid
+		for c, sum := range sums {
+			if normalized := normalizeFeature(sum); dotProduct(normalized, normalized) > 0 {
+				coarse[c] = normalized
+			}
+		}
+	}
+
+	return coarse
+}
+
+// nearestCentroid returns the index of the centroid with the highest dot
+// product with v, or -1 if there are no centroids
+func nearestCentroid(centroids [][]float32, v []float32) int {
+	best := -1
+	var bestScore float32
+	for c, centroid := range centroids {
+		if score := dotProduct(v, centroid); best < 0 || score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
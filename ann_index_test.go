@@ -0,0 +1,127 @@
+package face
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// randomGallery returns n persons with one random unit-length sample each
+func randomGallery(rng *rand.Rand, n, dim int) map[string]*Person {
+	persons := make(map[string]*Person, n)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("%05d", i)
+		persons[id] = &Person{ID: id, Name: "Person " + id, Features: []FaceFeature{
+			{PersonID: id, Feature: randomUnitVector(rng, dim)},
+		}}
+	}
+	return persons
+}
+
+func randomUnitVector(rng *rand.Rand, dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = float32(rng.NormFloat64())
+	}
+	return normalizeFeature(v)
+}
+
+// noisyCopy returns feature perturbed by Gaussian noise, like a second photo
+// of the same face
+func noisyCopy(rng *rand.Rand, feature []float32, sigma float64) []float32 {
+	noisy := make([]float32, len(feature))
+	for i, v := range feature {
+		noisy[i] = v + float32(rng.NormFloat64()*sigma)
+	}
+	return normalizeFeature(noisy)
+}
+
+func TestANNIndex_MatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	persons := randomGallery(rng, 2000, 128)
+
+	ids := make([]string, 0, len(persons))
+	for id := range persons {
+		ids = append(ids, id)
+	}
+
+	bruteForce := &FaceRecognizer{persons: persons}
+	indexed := &FaceRecognizer{persons: persons}
+	WithANNIndex(true)(indexed)
+
+	const queries = 200
+	agree := 0
+	for i := 0; i < queries; i++ {
+		source := persons[ids[rng.Intn(len(ids))]]
+		query := noisyCopy(rng, source.Features[0].Feature, 0.05)
+
+		expected, _, _ := bruteForce.matchPerson(query)
+		got, _, _ := indexed.matchPerson(query)
+		if got == expected {
+			agree++
+		}
+	}
+
+	if recall := float64(agree) / queries; recall < 0.95 {
+		t.Errorf("Expected ANN top-1 to agree with brute force on at least 95%% of queries, got %.1f%%", recall*100)
+	}
+
+	idx := indexed.ann
+	if len(idx.coarse) <= annProbes {
+		t.Fatalf("Expected more than %d lists for %d persons, got %d", annProbes, len(persons), len(idx.coarse))
+	}
+	if candidates, ok := idx.candidates(persons[ids[0]].Features[0].Feature); !ok || len(candidates) >= len(persons) {
+		t.Errorf("Expected the index to narrow the search, got %d of %d persons", len(candidates), len(persons))
+	}
+}
+
+func TestANNIndex_KeepsInSync(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	persons := randomGallery(rng, 500, 64)
+
+	fr := &FaceRecognizer{persons: persons}
+	WithANNIndex(true)(fr)
+
+	// Build the index
+	fr.matchPerson(randomUnitVector(rng, 64))
+
+	// A new sample becomes findable without a rebuild
+	newcomer := randomUnitVector(rng, 64)
+	persons["00007"].mu.Lock()
+	persons["00007"].Features = append(persons["00007"].Features, FaceFeature{PersonID: "00007", Feature: newcomer})
+	persons["00007"].mu.Unlock()
+	fr.invalidateMatchCache("00007")
+
+	if id, _, _ := fr.matchPerson(newcomer); id != "00007" {
+		t.Errorf("Expected added sample to match 00007, got %q", id)
+	}
+
+	// A removed person is no longer returned
+	target := persons["00042"].Features[0].Feature
+	delete(persons, "00042")
+	fr.invalidateMatchCache("00042")
+
+	if id, _, _ := fr.matchPerson(target); id == "00042" {
+		t.Error("Expected removed person not to match")
+	}
+	if _, ok := fr.ann.where["00042"]; ok {
+		t.Error("Expected removed person's entries to be dropped from the index")
+	}
+}
+
+func TestANNIndex_SmallGalleryFallsBack(t *testing.T) {
+	persons := map[string]*Person{
+		"001": {ID: "001", Name: "Alice", Features: []FaceFeature{{PersonID: "001", Feature: []float32{1, 0}}}},
+		"002": {ID: "002", Name: "Bob", Features: []FaceFeature{{PersonID: "002", Feature: []float32{0, 1}}}},
+	}
+
+	fr := &FaceRecognizer{persons: persons}
+	WithANNIndex(true)(fr)
+
+	if id, _, confidence := fr.matchPerson([]float32{0.9, 0.1}); id != "001" || confidence <= 0 {
+		t.Errorf("Expected exhaustive match on 001, got %q (%.2f)", id, confidence)
+	}
+	if _, ok := fr.ann.candidates([]float32{1, 0}); ok {
+		t.Error("Expected small index to defer to brute force")
+	}
+}
@@ -1,19 +1,40 @@
 package face
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/proxy"
 )
 
+// ErrModelNotFound is returned for model keys missing from AvailableModels
+var ErrModelNotFound = errors.New("model not found")
+
+// ErrChecksumMismatch is returned when a downloaded file does not match the
+// model's MD5; the file is removed
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrDownloadStalled is returned when no data arrives for StallTimeout; the
+// partial file is kept so the download can be resumed
+var ErrDownloadStalled = errors.New("download stalled")
+
+// partSuffix is appended to a model's filename while it is downloaded; the
+// file is renamed to the model's filename once the download completes
+const partSuffix = ".part"
+
 // ModelInfo contains information about a downloadable model
 type ModelInfo struct {
 	Name        string
@@ -23,6 +44,11 @@ type ModelInfo struct {
 	Size        int64  // Expected size in bytes
 	Description string
 	ModelType   ModelType
+	Headers     http.Header // Extra request headers, overriding ModelDownloader.Headers per key
+
+	// Mirrors are fallback URLs of the same file, tried in order when the
+	// download from URL (including checksum verification) fails
+	Mirrors []string
 }
 
 // AvailableModels Available models for download
@@ -42,6 +68,10 @@ var AvailableModels = map[string]ModelInfo{
 		Size:        31510785, // ~30MB
 		Description: "OpenFace face recognition model (96x96, 128-dim)",
 		ModelType:   ModelOpenFace,
+		Mirrors: []string{
+			"https://raw.githubusercontent.com/pyannote/pyannote-data/master/openface.nn4.small2.v1.t7",
+			"https://files.kde.org/digikam/facesengine/dnnface/openface_nn4.small2.v1.t7",
+		},
 	},
 	"openface-alternative": {
 		Name:        "OpenFace nn4.small2.v1 (Mirror)",
@@ -63,31 +93,74 @@ var AvailableModels = map[string]ModelInfo{
 
 // DownloadProgress represents download progress
 type DownloadProgress struct {
+	ModelKey   string // Key in AvailableModels (empty for DownloadModel)
 	Total      int64
 	Downloaded int64
 	Percentage float64
 	Speed      float64 // bytes per second
 	Elapsed    time.Duration
+	ETA        time.Duration // Estimated time remaining (0 when Total or Speed is unknown)
 }
 
 // ProgressCallback is called during download to report progress
 type ProgressCallback func(progress DownloadProgress)
 
+// Logger receives status messages from ModelDownloader. *log.Logger
+// satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
 // ModelDownloader handles model file downloads
 type ModelDownloader struct {
 	OutputDir        string
 	OnProgress       ProgressCallback
-	Timeout          time.Duration
 	SkipVerification bool
-	ProxyURL         string // SOCKS5 or HTTP proxy URL (e.g., "socks5://127.0.0.1:10808")
+	ProxyURL         string // SOCKS5 or HTTP proxy URL (e.g., "socks5://127.0.0.1:10808"); overrides HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	Concurrency      int    // Parallel downloads in DownloadAll (default 1)
+
+	// Timeouts per phase of a download, so a stalled connection fails fast
+	// without capping how long a slow but steady transfer may take. Zero
+	// disables a timeout.
+	DialTimeout           time.Duration // Connecting to the server or proxy (default 30s)
+	ResponseHeaderTimeout time.Duration // Waiting for the response headers once the request is sent (default 30s)
+	StallTimeout          time.Duration // Waiting for the next bytes of the body (default 1m)
+	Timeout               time.Duration // Whole download, including the body (default none)
+
+	// Headers are sent with every download request, e.g. Authorization for
+	// a private mirror or a custom User-Agent. Go's HTTP client drops
+	// Authorization when a redirect leaves the original host.
+	Headers http.Header
+
+	// HTTPClient, if set, is used instead of an internally built client,
+	// e.g. to share a client or add custom TLS settings or round trippers.
+	// A copy is made: Timeout applies only if the client has none, and
+	// ProxyURL, DialTimeout and ResponseHeaderTimeout only if it has no
+	// Transport. StallTimeout always applies.
+	HTTPClient *http.Client
+
+	// Logger receives status messages (starting, resuming, verifying,
+	// proxy in use) and, without OnProgress, progress lines. nil (the
+	// default) is silent.
+	Logger Logger
 }
 
 // NewModelDownloader creates a new model downloader
 func NewModelDownloader(outputDir string) *ModelDownloader {
 	return &ModelDownloader{
-		OutputDir:        outputDir,
-		Timeout:          10 * time.Minute,
-		SkipVerification: false,
+		OutputDir:             outputDir,
+		SkipVerification:      false,
+		Concurrency:           1,
+		DialTimeout:           30 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		StallTimeout:          time.Minute,
+	}
+}
+
+// logf sends a status message to the logger, if any
+func (md *ModelDownloader) logf(format string, v ...interface{}) {
+	if md.Logger != nil {
+		md.Logger.Printf(format, v...)
 	}
 }
 
@@ -95,14 +168,25 @@ func NewModelDownloader(outputDir string) *ModelDownloader {
 func (md *ModelDownloader) Download(modelKey string) error {
 	model, exists := AvailableModels[modelKey]
 	if !exists {
-		return fmt.Errorf("model '%s' not found in available models", modelKey)
+		return fmt.Errorf("%w: %s", ErrModelNotFound, modelKey)
 	}
 
-	return md.DownloadModel(model)
+	return md.downloadModel(context.Background(), modelKey, model)
 }
 
 // DownloadModel downloads a specific model
 func (md *ModelDownloader) DownloadModel(model ModelInfo) error {
+	return md.DownloadModelContext(context.Background(), model)
+}
+
+// DownloadModelContext is like DownloadModel but aborts the download when ctx
+// is done, returning ctx.Err(). The partial file is kept so it can be resumed.
+func (md *ModelDownloader) DownloadModelContext(ctx context.Context, model ModelInfo) error {
+	return md.downloadModel(ctx, "", model)
+}
+
+// downloadModel downloads a model, reporting progress under modelKey
+func (md *ModelDownloader) downloadModel(ctx context.Context, modelKey string, model ModelInfo) error {
 	// Create output directory
 	if err := os.MkdirAll(md.OutputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %v", err)
@@ -112,24 +196,23 @@ func (md *ModelDownloader) DownloadModel(model ModelInfo) error {
 
 	// Check if file already exists
 	if md.fileExists(outputPath) {
-		fmt.Printf("File already exists: %s\n", outputPath)
+		md.logf("File already exists: %s", outputPath)
 
 		if !md.SkipVerification && model.MD5 != "" {
-			fmt.Println("Verifying existing file...")
+			md.logf("Verifying existing file...")
 			if md.verifyMD5(outputPath, model.MD5) {
-				fmt.Println("✓ File verification passed")
+				md.logf("✓ File verification passed")
 				return nil
 			}
-			fmt.Println("✗ File verification failed, re-downloading...")
+			md.logf("✗ File verification failed, re-downloading...")
 			os.Remove(outputPath)
 		} else {
 			return nil
 		}
 	}
 
-	fmt.Printf("Downloading %s...\n", model.Name)
-	fmt.Printf("URL: %s\n", model.URL)
-	fmt.Printf("Output: %s\n", outputPath)
+	md.logf("Downloading %s...", model.Name)
+	md.logf("Output: %s", outputPath)
 
 	// Create HTTP client with timeout and proxy support
 	client, err := md.createHTTPClient()
@@ -137,54 +220,212 @@ func (md *ModelDownloader) DownloadModel(model ModelInfo) error {
 		return fmt.Errorf("failed to create HTTP client: %v", err)
 	}
 
-	// Make request
-	resp, err := client.Get(model.URL)
+	urls := append([]string{model.URL}, model.Mirrors...)
+	var errs []error
+	for i, rawURL := range urls {
+		if i > 0 {
+			md.logf("✗ Download failed, trying mirror %d of %d...", i, len(model.Mirrors))
+		}
+
+		err := md.downloadFrom(ctx, client, modelKey, model, rawURL, outputPath)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if len(urls) == 1 {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", rawURL, err))
+	}
+
+	return fmt.Errorf("all %d URLs failed for %s: %w", len(urls), model.Name, errors.Join(errs...))
+}
+
+// downloadFrom downloads a model from one of its URLs into outputPath and
+// verifies its checksum
+func (md *ModelDownloader) downloadFrom(ctx context.Context, client *http.Client, modelKey string, model ModelInfo, rawURL, outputPath string) error {
+	md.logf("URL: %s", rawURL)
+
+	// Download into a .part file so interrupted downloads can be resumed
+	partPath := outputPath + partSuffix
+
+	resp, offset, err := md.startDownload(ctx, client, rawURL, md.requestHeader(model), partPath)
 	if err != nil {
-		return fmt.Errorf("failed to download: %v", err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
 	}
-	defer resp.Body.Close()
+	body := newStallReader(resp.Body, md.StallTimeout)
+	defer body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status: %s", resp.Status)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		md.logf("Resuming from %s", formatBytes(offset))
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
 	}
 
 	// Create output file
-	outFile, err := os.Create(outputPath)
+	outFile, err := os.OpenFile(partPath, flags, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %v", err)
 	}
-	defer outFile.Close()
+
+	totalSize := resp.ContentLength
+	if totalSize > 0 {
+		totalSize += offset
+	}
 
 	// Download with progress tracking
-	if err := md.downloadWithProgress(outFile, resp.Body, resp.ContentLength); err != nil {
-		os.Remove(outputPath)
-		return fmt.Errorf("download failed: %v", err)
+	err = md.downloadWithProgress(ctx, modelKey, outFile, body, totalSize, offset)
+	closeErr := outFile.Close()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		// Keep the partial file so the next attempt can resume
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to write output file: %v", closeErr)
 	}
 
-	fmt.Println("\n✓ Download completed")
+	if err := os.Rename(partPath, outputPath); err != nil {
+		return fmt.Errorf("failed to finalize download: %v", err)
+	}
+
+	md.logf("✓ Download completed")
 
 	// Verify MD5 checksum if provided
 	if !md.SkipVerification && model.MD5 != "" {
-		fmt.Println("Verifying checksum...")
+		md.logf("Verifying checksum...")
 		if !md.verifyMD5(outputPath, model.MD5) {
 			os.Remove(outputPath)
-			return fmt.Errorf("checksum verification failed")
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, model.Filename)
 		}
-		fmt.Println("✓ Checksum verified")
+		md.logf("✓ Checksum verified")
 	}
 
 	return nil
 }
 
-// downloadWithProgress downloads content with progress reporting
-func (md *ModelDownloader) downloadWithProgress(dst io.Writer, src io.Reader, totalSize int64) error {
+// stallReader wraps a response body and closes it when no data arrives for
+// timeout, turning a stalled connection into ErrDownloadStalled instead of a
+// Read that blocks forever
+type stallReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer // nil without a timeout
+	stalled atomic.Bool
+}
+
+// newStallReader wraps body; timeout <= 0 disables the stall detection
+func newStallReader(body io.ReadCloser, timeout time.Duration) *stallReader {
+	r := &stallReader{body: body, timeout: timeout}
+	if timeout > 0 {
+		r.timer = time.AfterFunc(timeout, func() {
+			r.stalled.Store(true)
+			body.Close()
+		})
+	}
+	return r
+}
+
+// Read reads from the body, restarting the stall timer whenever data arrives
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if r.stalled.Load() {
+		return n, fmt.Errorf("%w: no data received for %v", ErrDownloadStalled, r.timeout)
+	}
+	if n > 0 && r.timer != nil {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// Close stops the stall timer and closes the body
+func (r *stallReader) Close() error {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	return r.body.Close()
+}
+
+// requestHeader merges the downloader headers with the model's own headers,
+// which replace downloader values for the same key
+func (md *ModelDownloader) requestHeader(model ModelInfo) http.Header {
+	header := md.Headers.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	for key, values := range model.Headers {
+		header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	return header
+}
+
+// startDownload requests a model URL with the given headers, resuming from
+// an existing partial file when the server supports range requests. It
+// returns the response and the number of bytes of the partial file the
+// response continues from.
+func (md *ModelDownloader) startDownload(ctx context.Context, client *http.Client, rawURL string, header http.Header, partPath string) (*http.Response, int64, error) {
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	// Make request
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to download: %v", err)
+	}
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		return resp, offset, nil
+	case resp.StatusCode == http.StatusOK:
+		// Fresh download, or the server ignored the range: restart from zero
+		return resp, 0, nil
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The partial file no longer matches the remote file, start over
+		resp.Body.Close()
+		if err := os.Remove(partPath); err != nil {
+			return nil, 0, fmt.Errorf("failed to remove partial file: %v", err)
+		}
+		return md.startDownload(ctx, client, rawURL, header, partPath)
+	default:
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("download failed with status: %s", resp.Status)
+	}
+}
+
+// downloadWithProgress downloads content with progress reporting.
+// offset is the number of bytes already downloaded by a previous attempt.
+func (md *ModelDownloader) downloadWithProgress(ctx context.Context, modelKey string, dst io.Writer, src io.Reader, totalSize, offset int64) error {
 	startTime := time.Now()
-	var downloaded int64
+	downloaded := offset
 
 	buffer := make([]byte, 32*1024) // 32KB buffer
 	lastUpdate := time.Now()
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		n, err := src.Read(buffer)
 		if n > 0 {
 			if _, writeErr := dst.Write(buffer[:n]); writeErr != nil {
@@ -194,24 +435,12 @@ func (md *ModelDownloader) downloadWithProgress(dst io.Writer, src io.Reader, to
 
 			// Update progress every 100ms
 			if time.Since(lastUpdate) > 100*time.Millisecond {
+				progress := newDownloadProgress(modelKey, totalSize, downloaded, downloaded-offset, time.Since(startTime))
 				if md.OnProgress != nil {
-					elapsed := time.Since(startTime)
-					speed := float64(downloaded) / elapsed.Seconds()
-					percentage := 0.0
-					if totalSize > 0 {
-						percentage = float64(downloaded) / float64(totalSize) * 100
-					}
-
-					md.OnProgress(DownloadProgress{
-						Total:      totalSize,
-						Downloaded: downloaded,
-						Percentage: percentage,
-						Speed:      speed,
-						Elapsed:    elapsed,
-					})
+					md.OnProgress(progress)
 				} else {
 					// Default progress output
-					md.printProgress(downloaded, totalSize)
+					md.logProgress(progress)
 				}
 				lastUpdate = time.Now()
 			}
@@ -228,16 +457,46 @@ func (md *ModelDownloader) downloadWithProgress(dst io.Writer, src io.Reader, to
 	return nil
 }
 
-// printProgress prints download progress
-func (md *ModelDownloader) printProgress(downloaded, total int64) {
+// newDownloadProgress computes the progress of a download. transferred is
+// the number of bytes received in this attempt, which the speed is based on.
+func newDownloadProgress(modelKey string, total, downloaded, transferred int64, elapsed time.Duration) DownloadProgress {
+	progress := DownloadProgress{
+		ModelKey:   modelKey,
+		Total:      total,
+		Downloaded: downloaded,
+		Elapsed:    elapsed,
+	}
+
+	if elapsed > 0 {
+		progress.Speed = float64(transferred) / elapsed.Seconds()
+	}
 	if total > 0 {
-		percentage := float64(downloaded) / float64(total) * 100
-		fmt.Printf("\rProgress: %.1f%% (%s / %s)",
-			percentage,
-			formatBytes(downloaded),
-			formatBytes(total))
+		progress.Percentage = float64(downloaded) / float64(total) * 100
+
+		// Speed is 0 before the first bytes of this attempt arrive
+		if remaining := total - downloaded; remaining > 0 && progress.Speed > 0 {
+			progress.ETA = time.Duration(float64(remaining) / progress.Speed * float64(time.Second))
+		}
+	}
+
+	return progress
+}
+
+// logProgress logs download progress
+func (md *ModelDownloader) logProgress(progress DownloadProgress) {
+	if progress.Total > 0 {
+		eta := "unknown"
+		if progress.ETA > 0 {
+			eta = formatDuration(progress.ETA)
+		}
+		md.logf("Progress: %.1f%% (%s / %s, %s, ETA %s)",
+			progress.Percentage,
+			formatBytes(progress.Downloaded),
+			formatBytes(progress.Total),
+			formatSpeed(progress.Speed),
+			eta)
 	} else {
-		fmt.Printf("\rDownloaded: %s", formatBytes(downloaded))
+		md.logf("Downloaded: %s", formatBytes(progress.Downloaded))
 	}
 }
 
@@ -247,6 +506,36 @@ func (md *ModelDownloader) fileExists(path string) bool {
 	return err == nil
 }
 
+// CleanPartials removes the partial (.part) files left in OutputDir by
+// interrupted downloads and returns how many were removed. Partial files are
+// never mistaken for complete models, but are resumed by the next download
+// of the same model; cleaning them frees the space and forces a fresh
+// download. It must not be called while downloads are running.
+func (md *ModelDownloader) CleanPartials() (int, error) {
+	entries, err := os.ReadDir(md.OutputDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read output directory: %v", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || filepath.Ext(entry.Name()) != partSuffix {
+			continue
+		}
+		path := filepath.Join(md.OutputDir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove partial file: %v", err)
+		}
+		md.logf("Removed partial download: %s", path)
+		removed++
+	}
+
+	return removed, nil
+}
+
 // verifyMD5 verifies the MD5 checksum of a file
 func (md *ModelDownloader) verifyMD5(path, expectedMD5 string) bool {
 	file, err := os.Open(path)
@@ -264,26 +553,74 @@ func (md *ModelDownloader) verifyMD5(path, expectedMD5 string) bool {
 	return actualMD5 == expectedMD5
 }
 
-// DownloadAll downloads all available models
+// DownloadAll downloads all available models, running up to Concurrency
+// downloads in parallel. Mirrors sharing an output file are downloaded one
+// after another so they never write the same file concurrently.
 func (md *ModelDownloader) DownloadAll() error {
-	fmt.Printf("Downloading %d models...\n\n", len(AvailableModels))
-
-	failed := make([]string, 0)
+	md.logf("Downloading %d models...", len(AvailableModels))
 
-	for key, model := range AvailableModels {
-		fmt.Printf("\n[%s]\n", key)
-		if err := md.DownloadModel(model); err != nil {
-			fmt.Printf("✗ Failed: %v\n", err)
-			failed = append(failed, key)
+	// Group model keys by output file, in a stable order
+	keys := make([]string, 0, len(AvailableModels))
+	for key := range AvailableModels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	groups := make([][]string, 0, len(keys))
+	groupIndex := make(map[string]int)
+	for _, key := range keys {
+		filename := AvailableModels[key].Filename
+		if i, ok := groupIndex[filename]; ok {
+			groups[i] = append(groups[i], key)
 			continue
 		}
+		groupIndex[filename] = len(groups)
+		groups = append(groups, []string{key})
+	}
+
+	workers := min(max(md.Concurrency, 1), len(groups))
+	jobs := make(chan []string)
+	errs := make([]error, len(keys))
+	keyIndex := make(map[string]int, len(keys))
+	for i, key := range keys {
+		keyIndex[key] = i
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range jobs {
+				for _, key := range group {
+					md.logf("[%s]", key)
+					if err := md.Download(key); err != nil {
+						md.logf("✗ Failed [%s]: %v", key, err)
+						errs[keyIndex[key]] = fmt.Errorf("%s: %w", key, err)
+					}
+				}
+			}
+		}()
+	}
+
+	for _, group := range groups {
+		jobs <- group
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := make([]error, 0)
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
 	}
 
 	if len(failed) > 0 {
-		return fmt.Errorf("failed to download %d model(s): %v", len(failed), failed)
+		return fmt.Errorf("failed to download %d model(s): %w", len(failed), errors.Join(failed...))
 	}
 
-	fmt.Println("\n✓ All models downloaded successfully")
+	md.logf("✓ All models downloaded successfully")
 	return nil
 }
 
@@ -291,30 +628,19 @@ func (md *ModelDownloader) DownloadAll() error {
 func (md *ModelDownloader) DownloadRequired() error {
 	required := []string{"pigo-facefinder", "openface"}
 
-	fmt.Printf("Downloading required models...\n\n")
+	md.logf("Downloading required models...")
 
 	for _, key := range required {
 		if err := md.Download(key); err != nil {
-			// Try alternative mirrors for OpenFace
-			if key == "openface" {
-				fmt.Printf("✗ Primary mirror failed, trying alternative...\n")
-				if altErr := md.Download("openface-alternative"); altErr != nil {
-					fmt.Printf("✗ Alternative mirror failed, trying KDE mirror...\n")
-					if kdeErr := md.Download("openface-kde"); kdeErr != nil {
-						return fmt.Errorf("all mirrors failed for OpenFace model")
-					}
-				}
-			} else {
-				return err
-			}
+			return err
 		}
 	}
 
-	fmt.Println("\n✓ Required models downloaded successfully")
+	md.logf("✓ Required models downloaded successfully")
 	return nil
 }
 
-// ListAvailableModels lists all available models
+// ListAvailableModels prints all available models to stdout
 func ListAvailableModels() {
 	fmt.Println("Available models:")
 	fmt.Println()
@@ -336,7 +662,7 @@ func ListAvailableModels() {
 func GetModelPath(outputDir, modelKey string) (string, error) {
 	model, exists := AvailableModels[modelKey]
 	if !exists {
-		return "", fmt.Errorf("model '%s' not found", modelKey)
+		return "", fmt.Errorf("%w: %s", ErrModelNotFound, modelKey)
 	}
 
 	return filepath.Join(outputDir, model.Filename), nil
@@ -375,14 +701,41 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%dm %ds", minutes, seconds)
 }
 
-// createHTTPClient creates an HTTP client with proxy support
+// createHTTPClient returns a copy of HTTPClient, or a new client, with the
+// timeouts and proxy applied where not already configured. Without ProxyURL
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
 func (md *ModelDownloader) createHTTPClient() (*http.Client, error) {
-	client := &http.Client{
-		Timeout: md.Timeout,
+	client := &http.Client{}
+	if md.HTTPClient != nil {
+		*client = *md.HTTPClient
+	}
+
+	if client.Timeout == 0 {
+		client.Timeout = md.Timeout
+	}
+
+	dialer := &net.Dialer{Timeout: md.DialTimeout, KeepAlive: 30 * time.Second}
+
+	// Without an explicit proxy, use the environment (HTTP_PROXY,
+	// HTTPS_PROXY, NO_PROXY; read once per process by net/http) even if
+	// http.DefaultTransport was reconfigured
+	if md.ProxyURL == "" && client.Transport == nil {
+		if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+			transport := defaultTransport.Clone()
+			transport.Proxy = http.ProxyFromEnvironment
+			transport.DialContext = dialer.DialContext
+			transport.ResponseHeaderTimeout = md.ResponseHeaderTimeout
+			client.Transport = transport
+		}
 	}
 
 	// If proxy URL is provided, configure the client to use it
 	if md.ProxyURL != "" {
+		if client.Transport != nil {
+			md.logf("Ignoring proxy %s: HTTP client has its own transport", md.ProxyURL)
+			return client, nil
+		}
+
 		proxyURL, err := url.Parse(md.ProxyURL)
 		if err != nil {
 			return nil, fmt.Errorf("invalid proxy URL: %v", err)
@@ -391,21 +744,24 @@ func (md *ModelDownloader) createHTTPClient() (*http.Client, error) {
 		switch proxyURL.Scheme {
 		case "socks5":
 			// SOCKS5 proxy
-			dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, nil, proxy.Direct)
+			socksDialer, err := proxy.SOCKS5("tcp", proxyURL.Host, nil, dialer)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create SOCKS5 dialer: %v", err)
 			}
 			client.Transport = &http.Transport{
-				Dial: dialer.Dial,
+				Dial:                  socksDialer.Dial,
+				ResponseHeaderTimeout: md.ResponseHeaderTimeout,
 			}
-			fmt.Printf("Using SOCKS5 proxy: %s\n", proxyURL.Host)
+			md.logf("Using SOCKS5 proxy: %s", proxyURL.Host)
 
 		case "http", "https":
 			// HTTP/HTTPS proxy
 			client.Transport = &http.Transport{
-				Proxy: http.ProxyURL(proxyURL),
+				Proxy:                 http.ProxyURL(proxyURL),
+				DialContext:           dialer.DialContext,
+				ResponseHeaderTimeout: md.ResponseHeaderTimeout,
 			}
-			fmt.Printf("Using HTTP proxy: %s\n", md.ProxyURL)
+			md.logf("Using HTTP proxy: %s", md.ProxyURL)
 
 		default:
 			return nil, fmt.Errorf("unsupported proxy scheme: %s (supported: socks5, http, https)", proxyURL.Scheme)
@@ -1,15 +1,22 @@
 package face
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -26,8 +33,14 @@ func TestNewModelDownloader(t *testing.T) {
 		t.Errorf("Expected output dir %s, got %s", outputDir, downloader.OutputDir)
 	}
 
-	if downloader.Timeout != 10*time.Minute {
-		t.Errorf("Expected default timeout 10m, got %v", downloader.Timeout)
+	if downloader.Timeout != 0 {
+		t.Errorf("Expected no overall timeout by default, got %v", downloader.Timeout)
+	}
+	if downloader.DialTimeout != 30*time.Second || downloader.ResponseHeaderTimeout != 30*time.Second {
+		t.Errorf("Expected dial and response header timeouts of 30s, got %v and %v", downloader.DialTimeout, downloader.ResponseHeaderTimeout)
+	}
+	if downloader.StallTimeout != time.Minute {
+		t.Errorf("Expected default stall timeout 1m, got %v", downloader.StallTimeout)
 	}
 
 	if downloader.SkipVerification {
@@ -312,8 +325,8 @@ func TestDownloadModel_MD5Verification(t *testing.T) {
 			t.Error("Download should fail with incorrect MD5")
 		}
 
-		if !strings.Contains(err.Error(), "checksum") {
-			t.Errorf("Error should mention checksum, got: %v", err)
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Expected ErrChecksumMismatch, got: %v", err)
 		}
 	})
 
@@ -341,6 +354,501 @@ func TestDownloadModel_MD5Verification(t *testing.T) {
 	})
 }
 
+func TestDownloadModel_Resume(t *testing.T) {
+	testData := make([]byte, 64*1024)
+	for i := range testData {
+		testData[i] = byte(i % 251)
+	}
+	half := len(testData) / 2
+
+	t.Run("Range supported", func(t *testing.T) {
+		var rangeHeader string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rangeHeader = r.Header.Get("Range")
+			http.ServeContent(w, r, "model.dat", time.Time{}, bytes.NewReader(testData))
+		}))
+		defer server.Close()
+
+		outputDir := t.TempDir()
+		partPath := filepath.Join(outputDir, "resume.dat.part")
+		if err := os.WriteFile(partPath, testData[:half], 0644); err != nil {
+			t.Fatalf("Failed to create partial file: %v", err)
+		}
+
+		downloader := NewModelDownloader(outputDir)
+		testModel := ModelInfo{
+			Name:     "Test Model",
+			URL:      server.URL,
+			Filename: "resume.dat",
+			MD5:      calculateMD5(testData),
+		}
+
+		if err := downloader.DownloadModel(testModel); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+
+		if rangeHeader != fmt.Sprintf("bytes=%d-", half) {
+			t.Errorf("Expected Range header bytes=%d-, got %q", half, rangeHeader)
+		}
+
+		content, err := os.ReadFile(filepath.Join(outputDir, "resume.dat"))
+		if err != nil {
+			t.Fatalf("Failed to read downloaded file: %v", err)
+		}
+		if !bytes.Equal(content, testData) {
+			t.Error("Resumed content does not match original")
+		}
+
+		if fileExists(partPath) {
+			t.Error("Partial file should be renamed after success")
+		}
+	})
+
+	t.Run("Range not supported", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Ignore the Range header and always send the full body
+			w.Write(testData)
+		}))
+		defer server.Close()
+
+		outputDir := t.TempDir()
+		partPath := filepath.Join(outputDir, "restart.dat.part")
+		if err := os.WriteFile(partPath, []byte("stale partial content"), 0644); err != nil {
+			t.Fatalf("Failed to create partial file: %v", err)
+		}
+
+		downloader := NewModelDownloader(outputDir)
+		testModel := ModelInfo{
+			Name:     "Test Model",
+			URL:      server.URL,
+			Filename: "restart.dat",
+			MD5:      calculateMD5(testData),
+		}
+
+		if err := downloader.DownloadModel(testModel); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+
+		content, err := os.ReadFile(filepath.Join(outputDir, "restart.dat"))
+		if err != nil {
+			t.Fatalf("Failed to read downloaded file: %v", err)
+		}
+		if !bytes.Equal(content, testData) {
+			t.Error("Restarted content does not match original")
+		}
+	})
+}
+
+func TestDownloadModelContext_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", 1024*1024))
+		// Stream slowly until the client goes away
+		chunk := make([]byte, 1024)
+		for i := 0; i < 1024; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	downloader := NewModelDownloader(outputDir)
+	downloader.OnProgress = func(DownloadProgress) {}
+
+	testModel := ModelInfo{
+		Name:     "Test Model",
+		URL:      server.URL,
+		Filename: "test_cancel.dat",
+	}
+
+	t.Run("Cancelled mid-download", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := downloader.DownloadModelContext(ctx, testModel)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+
+		if fileExists(filepath.Join(outputDir, testModel.Filename)) {
+			t.Error("Cancelled download should not produce the final file")
+		}
+	})
+
+	t.Run("Cancelled before start", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := downloader.DownloadModelContext(ctx, testModel)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestDownloadModel_StallTimeout(t *testing.T) {
+	const chunks = 10
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", chunks*1024))
+		chunk := make([]byte, 1024)
+		for i := 0; i < chunks; i++ {
+			if r.URL.Path == "/stall" && i == 2 {
+				// Hold the connection open without sending anything
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+				return
+			}
+			w.Write(chunk)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	outputDir := t.TempDir()
+	downloader := NewModelDownloader(outputDir)
+	downloader.OnProgress = func(DownloadProgress) {}
+	downloader.StallTimeout = 200 * time.Millisecond
+
+	t.Run("Stalled", func(t *testing.T) {
+		model := ModelInfo{Name: "Stalled", URL: server.URL + "/stall", Filename: "stalled.dat"}
+
+		err := downloader.DownloadModel(model)
+		if !errors.Is(err, ErrDownloadStalled) {
+			t.Fatalf("Expected ErrDownloadStalled, got %v", err)
+		}
+
+		// The partial file is kept for resuming
+		info, err := os.Stat(filepath.Join(outputDir, model.Filename+".part"))
+		if err != nil {
+			t.Fatalf("Expected partial file: %v", err)
+		}
+		if info.Size() != 2*1024 {
+			t.Errorf("Expected 2048 partial bytes, got %d", info.Size())
+		}
+	})
+
+	t.Run("Slow but steady", func(t *testing.T) {
+		// Takes longer than StallTimeout in total, but never pauses that long
+		model := ModelInfo{Name: "Steady", URL: server.URL + "/steady", Filename: "steady.dat"}
+
+		if err := downloader.DownloadModel(model); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		info, err := os.Stat(filepath.Join(outputDir, model.Filename))
+		if err != nil || info.Size() != chunks*1024 {
+			t.Errorf("Expected complete file, got %v, %v", info, err)
+		}
+	})
+}
+
+func TestDownloadModel_ResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	downloader := NewModelDownloader(t.TempDir())
+	downloader.ResponseHeaderTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	err := downloader.DownloadModel(ModelInfo{Name: "Slow", URL: server.URL, Filename: "slow.dat"})
+	if err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") {
+		t.Errorf("Expected response header timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the download to fail fast, took %v", elapsed)
+	}
+}
+
+func TestDownloadAll_Concurrent(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("model " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	original := AvailableModels
+	defer func() { AvailableModels = original }()
+	AvailableModels = map[string]ModelInfo{
+		"a":        {Name: "A", URL: server.URL + "/a", Filename: "a.dat"},
+		"b":        {Name: "B", URL: server.URL + "/b", Filename: "b.dat"},
+		"b-mirror": {Name: "B mirror", URL: server.URL + "/b-mirror", Filename: "b.dat"},
+		"c":        {Name: "C", URL: server.URL + "/c", Filename: "c.dat"},
+		"missing":  {Name: "Missing", URL: server.URL + "/missing", Filename: "missing.dat"},
+	}
+
+	outputDir := t.TempDir()
+	downloader := NewModelDownloader(outputDir)
+	downloader.Timeout = 5 * time.Second
+	downloader.Concurrency = 4
+
+	err := downloader.DownloadAll()
+	if err == nil {
+		t.Fatal("Expected error for the missing model")
+	}
+	if !strings.Contains(err.Error(), "failed to download 1 model(s)") || !strings.Contains(err.Error(), "missing:") {
+		t.Errorf("Expected aggregated error naming the missing model, got: %v", err)
+	}
+
+	if maxInFlight < 2 {
+		t.Errorf("Expected parallel downloads, max in flight was %d", maxInFlight)
+	}
+
+	// The first mirror in key order wins; the second finds the file present
+	data, err := os.ReadFile(filepath.Join(outputDir, "b.dat"))
+	if err != nil || string(data) != "model /b" {
+		t.Errorf("Expected b.dat from the first mirror, got %q (%v)", data, err)
+	}
+	for _, name := range []string{"a.dat", "c.dat"} {
+		if !fileExists(filepath.Join(outputDir, name)) {
+			t.Errorf("Expected %s to be downloaded", name)
+		}
+	}
+}
+
+func TestDownloadModel_Headers(t *testing.T) {
+	testData := []byte("private model")
+
+	var mu sync.Mutex
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = r.Header.Clone()
+		mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer model-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(testData)
+	}))
+	defer server.Close()
+
+	downloader := NewModelDownloader(t.TempDir())
+	downloader.Timeout = 5 * time.Second
+	downloader.Headers = http.Header{
+		"Authorization": {"Bearer downloader-token"},
+		"User-Agent":    {"face-downloader/1.0"},
+	}
+
+	testModel := ModelInfo{
+		Name:     "Private Model",
+		URL:      server.URL,
+		Filename: "private_model.dat",
+		// Lower-case keys are canonicalized and override the downloader value
+		Headers: http.Header{"authorization": {"Bearer model-token"}},
+	}
+
+	if err := downloader.DownloadModel(testModel); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := received.Get("User-Agent"); got != "face-downloader/1.0" {
+		t.Errorf("Expected downloader User-Agent, got %q", got)
+	}
+	if got := received.Values("Authorization"); len(got) != 1 || got[0] != "Bearer model-token" {
+		t.Errorf("Expected model Authorization to override the downloader's, got %v", got)
+	}
+
+	// The downloader's own headers are left untouched
+	if got := downloader.Headers.Get("Authorization"); got != "Bearer downloader-token" {
+		t.Errorf("Expected downloader headers to be unchanged, got %q", got)
+	}
+}
+
+func TestCreateHTTPClient_EnvironmentProxy(t *testing.T) {
+	// net/http reads the proxy variables once per process, so the checks
+	// run in a child test process with the variables set
+	if os.Getenv("FACE_TEST_PROXY_CHILD") != "1" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCreateHTTPClient_EnvironmentProxy$", "-test.v")
+		cmd.Env = append(os.Environ(),
+			"FACE_TEST_PROXY_CHILD=1",
+			"HTTPS_PROXY=http://env-proxy.example:3128",
+			"NO_PROXY=mirror.example",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("Child test failed: %v\n%s", err, out)
+		}
+		return
+	}
+
+	tests := []struct {
+		name     string
+		proxyURL string
+		target   string
+		expected string
+	}{
+		{"From environment", "", "https://github.com/model.t7", "http://env-proxy.example:3128"},
+		{"Excluded by NO_PROXY", "", "https://mirror.example/model.t7", ""},
+		{"Explicit field wins", "http://explicit.example:8080", "https://github.com/model.t7", "http://explicit.example:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloader := NewModelDownloader(t.TempDir())
+			downloader.ProxyURL = tt.proxyURL
+
+			client, err := downloader.createHTTPClient()
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("Expected *http.Transport, got %T", client.Transport)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+			proxyURL, err := transport.Proxy(req)
+			if err != nil {
+				t.Fatalf("Proxy lookup failed: %v", err)
+			}
+
+			got := ""
+			if proxyURL != nil {
+				got = proxyURL.String()
+			}
+			if got != tt.expected {
+				t.Errorf("Expected proxy %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// countingTransport counts the requests passing through it
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDownloadModel_HTTPClient(t *testing.T) {
+	testData := []byte("model data")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testData)
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	client := &http.Client{Transport: transport}
+
+	downloader := NewModelDownloader(t.TempDir())
+	downloader.HTTPClient = client
+	// Ignored: the client has its own transport
+	downloader.ProxyURL = "http://127.0.0.1:1"
+
+	testModel := ModelInfo{
+		Name:     "Client Model",
+		URL:      server.URL,
+		Filename: "client_model.dat",
+	}
+	if err := downloader.DownloadModel(testModel); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if transport.requests != 1 {
+		t.Errorf("Expected 1 request through the custom client, got %d", transport.requests)
+	}
+
+	// The shared client is not modified
+	if client.Timeout != 0 {
+		t.Errorf("Expected client timeout to be unchanged, got %v", client.Timeout)
+	}
+}
+
+func TestDownloadModel_Logger(t *testing.T) {
+	testData := []byte("model data")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testData)
+	}))
+	defer server.Close()
+
+	testModel := ModelInfo{
+		Name:     "Logged Model",
+		URL:      server.URL,
+		Filename: "logged_model.dat",
+	}
+
+	// Silent by default: nothing reaches stdout
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+
+	downloader := NewModelDownloader(t.TempDir())
+	downloader.Timeout = 5 * time.Second
+	err = downloader.DownloadModel(testModel)
+
+	os.Stdout = stdout
+	w.Close()
+	printed, _ := ioutil.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if len(printed) > 0 {
+		t.Errorf("Expected no output without a logger, got %q", printed)
+	}
+
+	// With a logger, status messages go to it
+	var buf bytes.Buffer
+	downloader = NewModelDownloader(t.TempDir())
+	downloader.Timeout = 5 * time.Second
+	downloader.Logger = log.New(&buf, "", 0)
+
+	if err := downloader.DownloadModel(testModel); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	logged := buf.String()
+	for _, want := range []string{"Downloading Logged Model...", "✓ Download completed"} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("Expected log to contain %q, got:\n%s", want, logged)
+		}
+	}
+}
+
 func TestDownload_ByKey(t *testing.T) {
 	outputDir, err := ioutil.TempDir("", "model_test")
 	if err != nil {
@@ -387,8 +895,8 @@ func TestGetModelPath(t *testing.T) {
 			path, err := GetModelPath(outputDir, tt.modelKey)
 
 			if tt.expectError {
-				if err == nil {
-					t.Error("Expected error but got nil")
+				if !errors.Is(err, ErrModelNotFound) {
+					t.Errorf("Expected ErrModelNotFound, got %v", err)
 				}
 			} else {
 				if err != nil {
@@ -429,6 +937,37 @@ func TestFormatBytes(t *testing.T) {
 	}
 }
 
+func TestNewDownloadProgress(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       int64
+		downloaded  int64
+		transferred int64
+		elapsed     time.Duration
+		speed       float64
+		eta         time.Duration
+	}{
+		{"Halfway", 1000, 500, 500, 5 * time.Second, 100, 5 * time.Second},
+		{"Resumed", 1000, 800, 200, 2 * time.Second, 100, 2 * time.Second},
+		{"Unknown total", 0, 500, 500, 5 * time.Second, 100, 0},
+		{"No elapsed time", 1000, 0, 0, 0, 0, 0},
+		{"Stalled", 1000, 600, 0, 3 * time.Second, 0, 0},
+		{"Complete", 1000, 1000, 1000, 10 * time.Second, 100, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := newDownloadProgress("key", tt.total, tt.downloaded, tt.transferred, tt.elapsed)
+			if progress.Speed != tt.speed {
+				t.Errorf("Expected speed %v, got %v", tt.speed, progress.Speed)
+			}
+			if progress.ETA != tt.eta {
+				t.Errorf("Expected ETA %v, got %v", tt.eta, progress.ETA)
+			}
+		})
+	}
+}
+
 func TestFormatSpeed(t *testing.T) {
 	tests := []struct {
 		bytesPerSec float64
@@ -520,6 +1059,80 @@ func TestDownloadModel_HTTPError(t *testing.T) {
 	}
 }
 
+func TestDownloadModel_Mirrors(t *testing.T) {
+	good := []byte("model weights")
+
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/good":
+			w.Write(good)
+		case "/corrupt":
+			w.Write([]byte("truncated"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		mirrors   []string
+		wantErr   bool
+		requested []string
+	}{
+		{"Primary succeeds", "/good", []string{"/missing"}, false, []string{"/good"}},
+		{"Falls back in order", "/missing", []string{"/corrupt", "/good"}, false, []string{"/missing", "/corrupt", "/good"}},
+		{"All fail", "/missing", []string{"/corrupt"}, true, []string{"/missing", "/corrupt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested = nil
+
+			downloader := NewModelDownloader(t.TempDir())
+			model := ModelInfo{
+				Name:     "Test Model",
+				URL:      server.URL + tt.url,
+				Filename: "model.dat",
+				MD5:      calculateMD5(good),
+			}
+			for _, mirror := range tt.mirrors {
+				model.Mirrors = append(model.Mirrors, server.URL+mirror)
+			}
+
+			err := downloader.DownloadModel(model)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(requested, tt.requested) {
+				t.Errorf("Expected requests %v, got %v", tt.requested, requested)
+			}
+
+			if err != nil {
+				// Every URL is reported
+				for _, path := range tt.requested {
+					if !strings.Contains(err.Error(), server.URL+path) {
+						t.Errorf("Expected error to mention %s, got %v", path, err)
+					}
+				}
+				return
+			}
+
+			data, _ := os.ReadFile(filepath.Join(downloader.OutputDir, "model.dat"))
+			if string(data) != string(good) {
+				t.Errorf("Expected downloaded %q, got %q", good, data)
+			}
+		})
+	}
+}
+
 // Benchmark tests
 
 func BenchmarkFormatBytes(b *testing.B) {
@@ -545,6 +1158,47 @@ func BenchmarkCalculateMD5(b *testing.B) {
 
 // Helper functions
 
+func TestCleanPartials(t *testing.T) {
+	outputDir := t.TempDir()
+	files := map[string]bool{ // Name: expected to be removed
+		"openface.t7.part": true,
+		"arcface.onnx":     false,
+		"notes.part.txt":   false,
+	}
+	for name := range files {
+		if err := os.WriteFile(filepath.Join(outputDir, name), []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	// Directories are left alone
+	if err := os.Mkdir(filepath.Join(outputDir, "cache.part"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	downloader := NewModelDownloader(outputDir)
+	removed, err := downloader.CleanPartials()
+	if err != nil {
+		t.Fatalf("CleanPartials failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 partial file removed, got %d", removed)
+	}
+	for name, remove := range files {
+		if exists := fileExists(filepath.Join(outputDir, name)); exists == remove {
+			t.Errorf("%s: expected removed %v, exists %v", name, remove, exists)
+		}
+	}
+	if !fileExists(filepath.Join(outputDir, "cache.part")) {
+		t.Error("Expected directory to be kept")
+	}
+
+	// A missing output directory has nothing to clean
+	downloader = NewModelDownloader(filepath.Join(outputDir, "missing"))
+	if removed, err := downloader.CleanPartials(); removed != 0 || err != nil {
+		t.Errorf("Expected 0, nil for a missing directory, got %d, %v", removed, err)
+	}
+}
+
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
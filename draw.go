@@ -0,0 +1,99 @@
+package face
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+// DrawOptions configures DrawResults. Zero values use the defaults.
+type DrawOptions struct {
+	KnownColor   color.RGBA // Box and label color of matched persons (default green)
+	UnknownColor color.RGBA // Box and label color of unknown, ambiguous and failed faces (default red)
+	TextColor    color.RGBA // Label text color (default white)
+	Thickness    int        // Box line thickness (default 2)
+	FontScale    float64    // Label font scale (default 0.5)
+
+	// UnknownID is the PersonID drawn in UnknownColor (default
+	// DefaultUnknownID); set it when using WithUnknownLabel
+	UnknownID string
+
+	// HideConfidence draws only the person name
+	HideConfidence bool
+}
+
+// labelPadding is the space in pixels between the label text and its background edge
+const labelPadding = 3
+
+// DrawResults annotates img with a box and a "name confidence" label per
+// result. Labels sit above their box, or inside it when the box touches the
+// top of the image, and are shifted to stay within the image.
+func DrawResults(img *gocv.Mat, results []RecognizeResult, opts DrawOptions) {
+	opts = opts.withDefaults()
+	bounds := image.Rect(0, 0, img.Cols(), img.Rows())
+
+	for _, result := range results {
+		c := opts.KnownColor
+		if result.Error != nil || result.PersonID == opts.UnknownID || result.PersonID == "ambiguous" {
+			c = opts.UnknownColor
+		}
+
+		gocv.Rectangle(img, result.BoundingBox, c, opts.Thickness)
+
+		text := result.PersonName
+		if !opts.HideConfidence && result.Error == nil {
+			text = fmt.Sprintf("%s %.2f", result.PersonName, result.Confidence)
+		}
+
+		size, baseline := gocv.GetTextSizeWithBaseline(text, gocv.FontHersheySimplex, opts.FontScale, 1)
+		label := labelRect(result.BoundingBox, image.Pt(size.X, size.Y+baseline), bounds)
+
+		gocv.Rectangle(img, label, c, -1)
+		origin := image.Pt(label.Min.X+labelPadding, label.Max.Y-labelPadding-baseline)
+		gocv.PutText(img, text, origin, gocv.FontHersheySimplex, opts.FontScale, opts.TextColor, 1)
+	}
+}
+
+// withDefaults fills in unset options
+func (o DrawOptions) withDefaults() DrawOptions {
+	if o.KnownColor == (color.RGBA{}) {
+		o.KnownColor = color.RGBA{0, 255, 0, 255}
+	}
+	if o.UnknownColor == (color.RGBA{}) {
+		o.UnknownColor = color.RGBA{255, 0, 0, 255}
+	}
+	if o.TextColor == (color.RGBA{}) {
+		o.TextColor = color.RGBA{255, 255, 255, 255}
+	}
+	if o.Thickness <= 0 {
+		o.Thickness = 2
+	}
+	if o.FontScale <= 0 {
+		o.FontScale = 0.5
+	}
+	if o.UnknownID == "" {
+		o.UnknownID = DefaultUnknownID
+	}
+	return o
+}
+
+// labelRect places a label background for text of the given size on top of
+// box, moving it inside the box when there is no room above and shifting it
+// horizontally and vertically to stay within bounds
+func labelRect(box image.Rectangle, text image.Point, bounds image.Rectangle) image.Rectangle {
+	size := text.Add(image.Pt(2*labelPadding, 2*labelPadding))
+
+	origin := image.Pt(box.Min.X, box.Min.Y-size.Y)
+	if origin.Y < bounds.Min.Y {
+		origin.Y = box.Min.Y
+	}
+
+	// Shift back inside, preferring the left/top edge when the label is
+	// larger than the image
+	origin.X = max(bounds.Min.X, min(origin.X, bounds.Max.X-size.X))
+	origin.Y = max(bounds.Min.Y, min(origin.Y, bounds.Max.Y-size.Y))
+
+	return image.Rectangle{Min: origin, Max: origin.Add(size)}
+}
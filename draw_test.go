@@ -0,0 +1,72 @@
+package face
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestLabelRect(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	text := image.Pt(40, 10) // Padded to 46x16
+
+	tests := []struct {
+		name     string
+		box      image.Rectangle
+		expected image.Rectangle
+	}{
+		{"Above box", image.Rect(50, 50, 90, 90), image.Rect(50, 34, 96, 50)},
+		{"Inside box at top edge", image.Rect(50, 5, 90, 45), image.Rect(50, 5, 96, 21)},
+		{"Shifted left at right edge", image.Rect(180, 50, 200, 70), image.Rect(154, 34, 200, 50)},
+		{"Shifted up at bottom edge", image.Rect(10, 0, 30, 100), image.Rect(10, 0, 56, 16)},
+		{"Box partly outside", image.Rect(-20, -20, 30, 30), image.Rect(0, 0, 46, 16)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := labelRect(tt.box, text, bounds)
+			if got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+			if !got.In(bounds) {
+				t.Errorf("Label %v is not within %v", got, bounds)
+			}
+		})
+	}
+}
+
+func TestDrawResults(t *testing.T) {
+	img := gocv.NewMatWithSize(200, 300, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	DrawResults(&img, []RecognizeResult{
+		{PersonID: "001", PersonName: "Alice", Confidence: 0.9, BoundingBox: image.Rect(20, 50, 100, 150)},
+		{PersonID: DefaultUnknownID, PersonName: "Unknown", Confidence: 0.3, BoundingBox: image.Rect(150, 50, 230, 150)},
+		{PersonID: "error", PersonName: "Error", BoundingBox: image.Rect(240, 160, 290, 190), Error: errors.New("encoder failed")},
+	}, DrawOptions{})
+
+	// Pixels on the bottom edge of each box (BGR)
+	tests := []struct {
+		name  string
+		point image.Point
+		bgr   [3]uint8
+	}{
+		{"Known in green", image.Pt(60, 150), [3]uint8{0, 255, 0}},
+		{"Unknown in red", image.Pt(190, 150), [3]uint8{0, 0, 255}},
+		{"Error in red", image.Pt(265, 190), [3]uint8{0, 0, 255}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [3]uint8
+			for c := range got {
+				got[c] = img.GetUCharAt(tt.point.Y, tt.point.X*3+c)
+			}
+			if got != tt.bgr {
+				t.Errorf("Expected BGR %v at %v, got %v", tt.bgr, tt.point, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package face
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// EncoderConfig describes a secondary face encoder registered with WithEncoder
+type EncoderConfig struct {
+	Model      string // Model weights file (.onnx, .t7, .caffemodel, ...)
+	ConfigFile string // Optional config file for some models
+
+	// Type selects the predefined preprocessing of the model (default
+	// ModelOpenFace); use ModelCustom to supply it in Custom instead
+	Type   ModelType
+	Custom ModelConfig
+}
+
+// namedEncoder is a secondary encoder network with its own preprocessing
+type namedEncoder struct {
+	spec   EncoderConfig
+	config ModelConfig
+	net    gocv.Net
+	mu     sync.Mutex // Serializes inference on net
+}
+
+// WithEncoder registers a secondary face encoder under name, loaded by
+// NewFaceRecognizer next to the primary one, e.g. to A/B test models or to
+// compute features for a different database. Query it with
+// ExtractFeatureWith; recognition and enrollment keep using the primary
+// encoder. Registering a name twice keeps the last config.
+func WithEncoder(name string, config EncoderConfig) Option {
+	return func(fr *FaceRecognizer) {
+		if fr.encoders == nil {
+			fr.encoders = make(map[string]*namedEncoder)
+		}
+		fr.encoders[name] = &namedEncoder{spec: config}
+	}
+}
+
+// modelConfig resolves the preprocessing of an encoder config
+func (c EncoderConfig) modelConfig() (ModelConfig, error) {
+	switch c.Type {
+	case "":
+		return modelConfigs[ModelOpenFace], nil
+	case ModelCustom:
+		config := c.Custom
+		config.Type = ModelCustom
+		return config, config.validate()
+	}
+
+	config, exists := modelConfigs[c.Type]
+	if !exists {
+		return ModelConfig{}, fmt.Errorf("unknown model type %s", c.Type)
+	}
+	return config, nil
+}
+
+// loadEncoders loads the networks of the secondary encoders
+func (fr *FaceRecognizer) loadEncoders() error {
+	for name, enc := range fr.encoders {
+		if name == "" {
+			return errors.New("encoder name must not be empty")
+		}
+
+		config, err := enc.spec.modelConfig()
+		if err != nil {
+			return fmt.Errorf("invalid encoder %s: %v", name, err)
+		}
+		enc.config = config
+
+		enc.net = readEncoderNet(enc.spec.Model, enc.spec.ConfigFile)
+		if enc.net.Empty() {
+			return fmt.Errorf("failed to load encoder %s from %s", name, enc.spec.Model)
+		}
+
+		// The primary encoder has already fallen back if the preferred
+		// backend is unavailable
+		if fr.backend != gocv.NetBackendDefault || fr.target != gocv.NetTargetCPU {
+			enc.net.SetPreferableBackend(fr.backend)
+			enc.net.SetPreferableTarget(fr.target)
+		}
+	}
+
+	return nil
+}
+
+// readEncoderNet reads an encoder network, picking the reader by the config
+// file or the model extension
+func readEncoderNet(model, configFile string) gocv.Net {
+	if configFile != "" {
+		return gocv.ReadNet(model, configFile)
+	}
+	if strings.EqualFold(filepath.Ext(model), ".onnx") {
+		return gocv.ReadNetFromONNX(model)
+	}
+	return gocv.ReadNet(model, "")
+}
+
+// ReloadModel replaces the primary encoder at runtime, keeping the enrolled
+// persons: it loads config.FaceEncoderModel (and FaceEncoderConfig), runs a
+// test inference, then swaps the network and model config in under a write
+// lock, waiting for in-flight extractions, and closes the old network.
+// Options that configure the encoder (WithModelType, WithCustomModel,
+// WithBackend) apply to the new model; without them the current settings are
+// kept. Other options and Config fields are ignored.
+//
+// Source images of enrolled samples are not kept, so their features can't
+// be re-extracted: if the new model produces features of a different
+// dimension than the enrolled samples, the swap is refused with
+// ErrIncompatibleModel. Re-enroll the persons or clear the database first.
+// Features of the same dimension are kept as they are, so only swap in
+// models that produce comparable features (e.g. a re-export or a quantized
+// copy of the same model). The feature cache is cleared.
+func (fr *FaceRecognizer) ReloadModel(config Config, opts ...Option) error {
+	if err := fr.checkEncoder(); err != nil {
+		return err
+	}
+	if config.FaceEncoderModel == "" {
+		return errors.New("face encoder model is required")
+	}
+
+	// Collect the encoder settings the options change
+	fr.modelMu.RLock()
+	settings := &FaceRecognizer{modelConfig: fr.modelConfig, backend: fr.backend, target: fr.target}
+	fr.modelMu.RUnlock()
+	for _, opt := range opts {
+		opt(settings)
+	}
+
+	modelConfig := settings.modelConfig
+	if err := modelConfig.validate(); err != nil {
+		return fmt.Errorf("invalid model config: %v", err)
+	}
+	if modelConfig.Normalization != fr.normalization {
+		return fmt.Errorf("%w: normalization %v differs from the enrolled samples' %v", ErrIncompatibleModel, modelConfig.Normalization, fr.normalization)
+	}
+
+	net := readEncoderNet(config.FaceEncoderModel, config.FaceEncoderConfig)
+	if net.Empty() {
+		return errors.New("failed to load face encoder model")
+	}
+	backend, target := settings.backend, settings.target
+	if backend != gocv.NetBackendDefault || target != gocv.NetTargetCPU {
+		backend, target = applyBackend(net, backend, target)
+	}
+
+	dim, err := fr.probeDimension(net, modelConfig)
+	if err != nil {
+		net.Close()
+		return fmt.Errorf("test inference failed: %v", err)
+	}
+	if modelConfig.FeatureDim > 0 && dim != modelConfig.FeatureDim {
+		net.Close()
+		return fmt.Errorf("model produced a %d-dim feature, expected %d for model %s", dim, modelConfig.FeatureDim, modelConfig.Type)
+	}
+
+	// Hold the database so no sample is added between the check and the swap
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	if enrolled, ok := fr.enrolledDimension(dim); !ok {
+		net.Close()
+		return fmt.Errorf("%w: the new model produces %d-dim features, enrolled samples have %d; re-enroll or clear the database first",
+			ErrIncompatibleModel, dim, enrolled)
+	}
+
+	fr.modelMu.Lock()
+	previous := fr.faceEncoder
+	fr.faceEncoder, fr.modelConfig = net, modelConfig
+	fr.backend, fr.target = backend, target
+	if fr.featureCache != nil {
+		fr.featureCache.clear()
+	}
+	fr.modelMu.Unlock()
+
+	return previous.Close()
+}
+
+// probeDimension runs a blank face through a network that is not shared yet
+// and returns the dimension of its features
+func (fr *FaceRecognizer) probeDimension(net gocv.Net, config ModelConfig) (int, error) {
+	blank := gocv.NewMatWithSize(config.InputSize.Y, config.InputSize.X, gocv.MatTypeCV8UC3)
+	defer blank.Close()
+
+	feature, err := fr.extractFeature(blank, config, func(blob gocv.Mat) gocv.Mat {
+		net.SetInput(blob, config.InputLayerName)
+		output := net.Forward(config.OutputLayerName)
+		defer output.Close()
+		return output.Clone()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(feature), nil
+}
+
+// enrolledDimension reports whether all enrolled samples have dimension
+// dim, returning the dimension of the first one that does not. The caller
+// must hold fr.mu.
+func (fr *FaceRecognizer) enrolledDimension(dim int) (int, bool) {
+	for _, person := range fr.persons {
+		person.mu.RLock()
+		for _, sample := range person.Features {
+			if len(sample.Feature) != dim {
+				person.mu.RUnlock()
+				return len(sample.Feature), false
+			}
+		}
+		person.mu.RUnlock()
+	}
+	return dim, true
+}
+
+// Encoders returns the names of the secondary encoders, sorted
+func (fr *FaceRecognizer) Encoders() []string {
+	names := make([]string, 0, len(fr.encoders))
+	for name := range fr.encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ExtractFeatureWith extracts a face feature vector using the secondary
+// encoder registered under name with WithEncoder. An empty name uses the
+// primary encoder, like ExtractFeature. Features of different encoders are
+// not comparable with each other.
+func (fr *FaceRecognizer) ExtractFeatureWith(name string, faceImg gocv.Mat) ([]float32, error) {
+	if name == "" {
+		return fr.ExtractFeature(faceImg)
+	}
+
+	enc, exists := fr.encoders[name]
+	if !exists {
+		return nil, fmt.Errorf("encoder %s is not registered", name)
+	}
+
+	return fr.extractFeature(faceImg, enc.config, enc.forward)
+}
+
+// forward runs the encoder on a blob, see FaceRecognizer.forward
+func (enc *namedEncoder) forward(blob gocv.Mat) gocv.Mat {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+
+	enc.net.SetInput(blob, enc.config.InputLayerName)
+	output := enc.net.Forward(enc.config.OutputLayerName)
+	defer output.Close()
+
+	return output.Clone()
+}
+
+// closeEncoders releases the secondary encoder networks
+func (fr *FaceRecognizer) closeEncoders() error {
+	var errs []error
+	for name, enc := range fr.encoders {
+		if enc.net.Empty() {
+			continue
+		}
+		if err := enc.net.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close encoder %s: %v", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
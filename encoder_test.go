@@ -0,0 +1,240 @@
+package face
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestEncoderConfig_ModelConfig(t *testing.T) {
+	custom := ModelConfig{InputSize: image.Pt(64, 64), FeatureDim: 256, ScaleFactor: 1}
+
+	tests := []struct {
+		name     string
+		config   EncoderConfig
+		expected ModelConfig
+		wantErr  bool
+	}{
+		{"Default", EncoderConfig{}, modelConfigs[ModelOpenFace], false},
+		{"Predefined", EncoderConfig{Type: ModelArcFace}, modelConfigs[ModelArcFace], false},
+		{"Custom", EncoderConfig{Type: ModelCustom, Custom: custom}, ModelConfig{Type: ModelCustom, InputSize: image.Pt(64, 64), FeatureDim: 256, ScaleFactor: 1}, false},
+		{"Unknown", EncoderConfig{Type: "vggface"}, ModelConfig{}, true},
+		{"Invalid custom", EncoderConfig{Type: ModelCustom, Custom: ModelConfig{InputSize: image.Pt(64, 64), Channels: 4}}, ModelConfig{Type: ModelCustom, InputSize: image.Pt(64, 64), Channels: 4}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.modelConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExtractFeatureWith_UnknownEncoder(t *testing.T) {
+	fr := &FaceRecognizer{}
+	WithEncoder("arcface", EncoderConfig{Type: ModelArcFace})(fr)
+	WithEncoder("sface", EncoderConfig{Type: ModelSFace})(fr)
+
+	if names := fr.Encoders(); !reflect.DeepEqual(names, []string{"arcface", "sface"}) {
+		t.Errorf("Expected encoders [arcface sface], got %v", names)
+	}
+
+	face := gocv.NewMatWithSize(96, 96, gocv.MatTypeCV8UC3)
+	defer face.Close()
+
+	if _, err := fr.ExtractFeatureWith("facenet", face); err == nil {
+		t.Error("Expected error for unregistered encoder")
+	}
+}
+
+func TestExtractFeatureWith(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config, WithEncoder("secondary", EncoderConfig{
+		Model: "./testdata/nn4.small2.v1.t7",
+		Type:  ModelOpenFace,
+	}))
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	face := gocv.NewMatWithSize(96, 96, gocv.MatTypeCV8UC3)
+	defer face.Close()
+
+	primary, err := recognizer.ExtractFeature(face)
+	if err != nil {
+		t.Fatalf("Failed to extract with primary encoder: %v", err)
+	}
+	secondary, err := recognizer.ExtractFeatureWith("secondary", face)
+	if err != nil {
+		t.Fatalf("Failed to extract with secondary encoder: %v", err)
+	}
+
+	// Same model, same preprocessing
+	if len(secondary) != len(primary) {
+		t.Fatalf("Expected %d-dim feature, got %d", len(primary), len(secondary))
+	}
+	if similarity := cosineSimilarity(primary, secondary); similarity < 0.999 {
+		t.Errorf("Expected identical features, got similarity %.4f", similarity)
+	}
+}
+
+func TestNewFaceRecognizer_InvalidEncoder(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	tests := []struct {
+		name    string
+		encName string
+		config  EncoderConfig
+	}{
+		{"Empty name", "", EncoderConfig{Model: "./testdata/nn4.small2.v1.t7"}},
+		{"Unknown type", "x", EncoderConfig{Model: "./testdata/nn4.small2.v1.t7", Type: "vggface"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recognizer, err := NewFaceRecognizer(config, WithEncoder(tt.encName, tt.config))
+			if err == nil {
+				recognizer.Close()
+				t.Error("Expected error for invalid encoder")
+			}
+		})
+	}
+}
+
+func TestReloadModel_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		fr     *FaceRecognizer
+		config Config
+		opts   []Option
+		target error
+	}{
+		{"Detection only", &FaceRecognizer{noEncoder: true}, Config{FaceEncoderModel: "./testdata/nn4.small2.v1.t7"}, nil, ErrNoEncoder},
+		{"No model", &FaceRecognizer{}, Config{}, nil, nil},
+		{"Invalid model config", &FaceRecognizer{}, Config{FaceEncoderModel: "./testdata/nn4.small2.v1.t7"},
+			[]Option{WithCustomModel(ModelConfig{InputSize: image.Pt(96, 96), Channels: 2})}, nil},
+		{"Normalization change", &FaceRecognizer{}, Config{FaceEncoderModel: "./testdata/nn4.small2.v1.t7"},
+			[]Option{WithCustomModel(ModelConfig{InputSize: image.Pt(96, 96), Normalization: NormNone})}, ErrIncompatibleModel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fr.ReloadModel(tt.config, tt.opts...)
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if tt.target != nil && !errors.Is(err, tt.target) {
+				t.Errorf("Expected %v, got %v", tt.target, err)
+			}
+		})
+	}
+}
+
+func TestEnrolledDimension(t *testing.T) {
+	fr := &FaceRecognizer{persons: map[string]*Person{
+		"001": {ID: "001", Features: []FaceFeature{{Feature: make([]float32, 128)}}},
+		"002": {ID: "002"},
+	}}
+
+	if _, ok := fr.enrolledDimension(128); !ok {
+		t.Error("Expected 128-dim samples to be compatible")
+	}
+	if enrolled, ok := fr.enrolledDimension(512); ok || enrolled != 128 {
+		t.Errorf("Expected 128-dim samples to be incompatible with 512, got %d, %v", enrolled, ok)
+	}
+
+	// An empty database accepts any dimension
+	empty := &FaceRecognizer{persons: map[string]*Person{}}
+	if _, ok := empty.enrolledDimension(512); !ok {
+		t.Error("Expected an empty database to accept any dimension")
+	}
+}
+
+func TestReloadModel(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config)
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	face := gocv.NewMatWithSize(96, 96, gocv.MatTypeCV8UC3)
+	defer face.Close()
+
+	before, err := recognizer.ExtractFeature(face)
+	if err != nil {
+		t.Fatalf("Failed to extract feature: %v", err)
+	}
+	if err := recognizer.AddPerson("001", "Alice"); err != nil {
+		t.Fatalf("Failed to add person: %v", err)
+	}
+	recognizer.persons["001"].Features = []FaceFeature{{PersonID: "001", Feature: before}}
+
+	// Reload while extractions are running
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if _, err := recognizer.ExtractFeature(face); err != nil {
+				t.Errorf("Extraction failed during reload: %v", err)
+				return
+			}
+		}
+	}()
+	if err := recognizer.ReloadModel(config); err != nil {
+		t.Fatalf("Failed to reload model: %v", err)
+	}
+	<-done
+
+	after, err := recognizer.ExtractFeature(face)
+	if err != nil {
+		t.Fatalf("Failed to extract feature after reload: %v", err)
+	}
+	if similarity := cosineSimilarity(before, after); similarity < 0.999 {
+		t.Errorf("Expected the same model to produce the same feature, got similarity %.4f", similarity)
+	}
+	if !recognizer.HasPerson("001") {
+		t.Error("Expected enrolled persons to be kept")
+	}
+
+	// A model whose declared dimension doesn't match its output is refused
+	wrongDim := modelConfigs[ModelOpenFace]
+	wrongDim.FeatureDim = 256
+	if err := recognizer.ReloadModel(config, WithCustomModel(wrongDim)); err == nil {
+		t.Error("Expected error for a feature dimension mismatch")
+	}
+
+	// Samples of another dimension can't be kept
+	recognizer.persons["001"].Features = []FaceFeature{{PersonID: "001", Feature: make([]float32, 64)}}
+	if err := recognizer.ReloadModel(config); !errors.Is(err, ErrIncompatibleModel) {
+		t.Errorf("Expected ErrIncompatibleModel, got %v", err)
+	}
+}
@@ -1,13 +1,22 @@
 package face
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
+	"io"
 	"io/ioutil"
+	"maps"
 	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 
 	pigo "github.com/esimov/pigo/core"
 	"gocv.io/x/gocv"
@@ -25,6 +34,8 @@ const (
 	ModelArcFace ModelType = "arcface"
 	// ModelDlib is the Dlib ResNet model (128-dim, 150x150 input)
 	ModelDlib ModelType = "dlib"
+	// ModelSFace is OpenCV's SFace model used by FaceRecognizerSF (128-dim, 112x112 input)
+	ModelSFace ModelType = "sface"
 	// ModelCustom allows custom model configuration
 	ModelCustom ModelType = "custom"
 )
@@ -36,8 +47,26 @@ type ModelConfig struct {
 	FeatureDim  int         // Feature vector dimension
 	MeanValues  gocv.Scalar // Mean values for normalization
 	ScaleFactor float64     // Scale factor for normalization
-	SwapRB      bool        // Swap Red and Blue channels
+	SwapRB      bool        // Model expects RGB: swap Red and Blue of BGR input (see WithColorOrder); ignored if Channels is 1
 	Crop        bool        // Center crop
+
+	// Channels is the number of input channels: 3 for color models (0
+	// means 3), 1 for grayscale models, whose crops are converted to
+	// grayscale (honoring WithColorOrder) before the blob is built. Only the
+	// first component of MeanValues applies to grayscale models.
+	Channels int
+
+	// Normalization is applied to the encoder output after the forward
+	// pass: NormL2 (default), NormNone or NormMeanStd. Stored samples and
+	// centroids are normalized the same way, so it cannot change on a
+	// running recognizer (see ReloadModel).
+	Normalization FeatureNormalization
+
+	// InputLayerName and OutputLayerName name the encoder's input and
+	// output tensors, as required by multi-output ONNX graphs. Empty names
+	// use the network's default input and final output.
+	InputLayerName  string
+	OutputLayerName string
 }
 
 // Predefined model configurations
@@ -78,56 +107,465 @@ var modelConfigs = map[ModelType]ModelConfig{
 		SwapRB:      true,
 		Crop:        false,
 	},
+	// Matches FaceRecognizerSF's preprocessing: raw 0-255 RGB, no mean subtraction
+	ModelSFace: {
+		Type:        ModelSFace,
+		InputSize:   image.Pt(112, 112),
+		FeatureDim:  128,
+		MeanValues:  gocv.NewScalar(0, 0, 0, 0),
+		ScaleFactor: 1.0,
+		SwapRB:      true,
+		Crop:        false,
+	},
+}
+
+// channels returns the number of input channels of the model
+func (c ModelConfig) channels() int {
+	if c.Channels == 0 {
+		return 3
+	}
+	return c.Channels
+}
+
+// validate checks that the model can be fed
+func (c ModelConfig) validate() error {
+	if c.Channels != 0 && c.Channels != 1 && c.Channels != 3 {
+		return fmt.Errorf("channels must be 1 or 3, got %d", c.Channels)
+	}
+	if c.InputSize.X <= 0 || c.InputSize.Y <= 0 {
+		return fmt.Errorf("input size must be positive, got %v", c.InputSize)
+	}
+	if _, ok := featureNormalizers[c.Normalization]; !ok {
+		return fmt.Errorf("unknown normalization %v", c.Normalization)
+	}
+	return nil
+}
+
+// FeatureNormalization selects how encoder outputs are normalized into
+// features (see ModelConfig.Normalization)
+type FeatureNormalization int
+
+const (
+	// NormL2 scales features to unit length, so cosine similarity is a dot
+	// product (default)
+	NormL2 FeatureNormalization = iota
+	// NormNone keeps the raw encoder output, e.g. for models whose outputs
+	// are already normalized or whose magnitude matters (MetricEuclidean)
+	NormNone
+	// NormMeanStd subtracts the mean of each feature and divides by its
+	// standard deviation
+	NormMeanStd
+)
+
+// featureNormalizers are the normalization strategies by mode
+var featureNormalizers = map[FeatureNormalization]func([]float32) []float32{
+	NormL2:      normalizeFeature,
+	NormNone:    func(feature []float32) []float32 { return feature },
+	NormMeanStd: standardizeFeature,
+}
+
+// String returns the normalization name
+func (n FeatureNormalization) String() string {
+	switch n {
+	case NormL2:
+		return "l2"
+	case NormNone:
+		return "none"
+	case NormMeanStd:
+		return "mean-std"
+	default:
+		return fmt.Sprintf("FeatureNormalization(%d)", int(n))
+	}
+}
+
+// normalize applies the normalization to a feature
+func (n FeatureNormalization) normalize(feature []float32) []float32 {
+	return featureNormalizers[n](feature)
+}
+
+// DistanceMetric defines how feature vectors are compared
+type DistanceMetric int
+
+const (
+	// MetricCosine compares features by cosine similarity (higher is better)
+	MetricCosine DistanceMetric = iota
+	// MetricEuclidean compares features by Euclidean distance (lower is better).
+	// The threshold is a maximum distance and confidences are reported as
+	// 1 / (1 + distance) so that higher is still better.
+	MetricEuclidean
+)
+
+// String returns the metric name
+func (m DistanceMetric) String() string {
+	switch m {
+	case MetricCosine:
+		return "cosine"
+	case MetricEuclidean:
+		return "euclidean"
+	default:
+		return fmt.Sprintf("DistanceMetric(%d)", int(m))
+	}
 }
 
+// MatchStrategy defines how a face is compared against a person's samples
+type MatchStrategy int
+
+const (
+	// StrategyMaxSample compares against every sample and keeps the best score
+	StrategyMaxSample MatchStrategy = iota
+	// StrategyCentroid compares against the person's averaged, renormalized
+	// embedding: one comparison per person regardless of sample count
+	StrategyCentroid
+)
+
+// ConfidenceScale selects how RecognizeResult.Confidence is reported (see
+// WithConfidenceScaling). Matching always uses the raw similarity.
+type ConfidenceScale int
+
+const (
+	// ScaleRaw reports the raw similarity: cosine in [-1, 1], or
+	// 1 / (1 + distance) with MetricEuclidean
+	ScaleRaw ConfidenceScale = iota
+	// ScaleZeroOne maps cosine similarity to [0, 1] as (cos + 1) / 2;
+	// Euclidean confidences are already in (0, 1] and are kept
+	ScaleZeroOne
+	// ScalePercent is ScaleZeroOne times 100
+	ScalePercent
+)
+
+// PrimaryFaceMode selects which detected face is treated as the subject of
+// an image by DetectPrimaryFace, AddFaceSample and Verify
+type PrimaryFaceMode int
+
+const (
+	// PrimaryLargest picks the face with the largest area
+	PrimaryLargest PrimaryFaceMode = iota
+	// PrimaryCentral picks the face whose center is nearest the image center
+	PrimaryCentral
+)
+
+// ResultOrdering selects the order of the results returned by Recognize
+type ResultOrdering int
+
+const (
+	// OrderDetection keeps results in detection order, matching DetectFaces
+	OrderDetection ResultOrdering = iota
+	// OrderConfidence puts known persons first, then ambiguous, unknown and
+	// failed faces, each group sorted by descending confidence
+	OrderConfidence
+)
+
+// ColorOrder is the channel order of the color Mats passed to the recognizer
+// (see WithColorOrder)
+type ColorOrder int
+
+const (
+	// ColorOrderBGR is OpenCV's native order, as produced by gocv.IMRead,
+	// LoadImage and LoadImageFromStdImage (the default)
+	ColorOrderBGR ColorOrder = iota
+	// ColorOrderRGB is for Mats filled by other decoders or cameras, e.g.
+	// from the pixels of an image.RGBA
+	ColorOrderRGB
+)
+
+// RetentionStrategy selects which sample is dropped when a person exceeds
+// the sample limit set by WithMaxSamplesPerPerson
+type RetentionStrategy int
+
+const (
+	// RetainNewest drops the oldest samples
+	RetainNewest RetentionStrategy = iota
+	// RetainMostRepresentative drops the sample whose removal changes the
+	// person's centroid least, i.e. the one adding the least information
+	RetainMostRepresentative
+)
+
 // FaceFeature represents a face feature vector
 type FaceFeature struct {
-	PersonID string    `json:"person_id"`
-	Feature  []float32 `json:"feature"`
+	PersonID  string            `json:"person_id"`
+	Feature   []float32         `json:"feature,omitempty"`
+	Quantized *QuantizedFeature `json:"quantized,omitempty"` // Compact stored form, see WithStoreQuantized
+
+	// Provenance recorded by AddFaceSample and AddFaceSamples, e.g. to drop
+	// old samples or audit which photo produced an embedding. Samples
+	// enrolled before these fields existed leave them zero.
+	CreatedAt   time.Time `json:"created_at,omitzero"`    // When the sample was enrolled (UTC)
+	SourceImage string    `json:"source_image,omitempty"` // Image file, set by AddFaceSampleFromFile
+	Quality     float32   `json:"quality,omitempty"`      // Detection quality of the enrolled face
 }
 
 // Person represents a person with multiple face samples
 type Person struct {
-	ID       string        `json:"id"`
-	Name     string        `json:"name"`
-	Features []FaceFeature `json:"features"`
-	mu       sync.RWMutex
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Features []FaceFeature     `json:"features"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Disabled excludes the person from matching while keeping their
+	// samples; see SetPersonEnabled
+	Disabled bool `json:"disabled,omitempty"`
+
+	// SchemaVersion is the layout version the person was stored with (0
+	// for persons not read from storage). Persons are always written with
+	// PersonSchemaVersion and older records are migrated when read, so a
+	// lower value only marks a record MigrateStorage would rewrite.
+	SchemaVersion int `json:"schema_version"`
+
+	mu sync.RWMutex
 }
 
 // RecognizeResult represents a face recognition result
 type RecognizeResult struct {
-	PersonID    string          `json:"person_id"`
-	PersonName  string          `json:"person_name"`
-	Confidence  float32         `json:"confidence"`
-	BoundingBox image.Rectangle `json:"bounding_box"`
+	PersonID         string          `json:"person_id"`
+	PersonName       string          `json:"person_name"`
+	Confidence       float32         `json:"confidence"`
+	BoundingBox      image.Rectangle `json:"bounding_box"`
+	DetectionQuality float32         `json:"detection_quality"` // Pigo detection score
+
+	// Occlusion is the EstimateOcclusion score of the face, only computed
+	// with WithMaxOcclusion. Faces above the maximum are reported as unknown.
+	Occlusion float64 `json:"occlusion,omitempty"`
+
+	// Error is set, with PersonID "error", when the face was detected but
+	// its feature could not be extracted
+	Error error `json:"-"`
 }
 
-// FaceRecognizer is the main face recognition engine
+// FaceRecognizer is the main face recognition engine.
+//
+// All methods are safe for concurrent use, except Close, which must only be
+// called once no other call is in flight. Detection and matching run in
+// parallel; encoder inference (SetInput/Forward on the shared DNN) is
+// serialized by an internal mutex, so concurrent Recognize calls queue for
+// the encoder. Run several recognizers for parallel inference.
 type FaceRecognizer struct {
 	pigoClassifier *pigo.Pigo
+	puplocCascade  *pigo.PuplocCascade // Optional pupil localizer used for alignment
+	alignment      bool
+	flplocCascades map[string]*pigo.PuplocCascade // Optional facial landmark localizers by name
 	faceEncoder    gocv.Net
+	noEncoder      bool                // Detection-only: no FaceEncoderModel was configured
+	encoderMu      sync.Mutex          // Serializes inference on faceEncoder
+	modelMu        sync.RWMutex        // Guards faceEncoder, modelConfig, backend and target (see ReloadModel)
+	backend        gocv.NetBackendType // Preferred DNN backend for the encoder
+	target         gocv.NetTargetType  // Preferred DNN target device for the encoder
 	modelConfig    ModelConfig
+	normalization  FeatureNormalization    // modelConfig.Normalization at construction, fixed for the stored features
+	interpolation  gocv.InterpolationFlags // Resize mode for encoder input
+	grayscale      GrayscaleConverter      // Detector input conversion (nil = BT.601)
 	persons        map[string]*Person
 	storage        FaceStorage // Storage backend
+	autoPersist    bool        // Write person changes through to storage
+	autoPersistSet bool        // autoPersist was set explicitly via WithAutoPersist
+	storeQuantized bool        // Persist features in 8-bit quantized form
+	strictDims     bool        // Reject loaded features whose length differs from FeatureDim
 	mu             sync.RWMutex
 	threshold      float32
+	margin         float32 // Minimum best/runner-up confidence gap (0 = disabled)
+	unknownID      string  // PersonID reported for faces below the threshold
+	unknownName    string  // PersonName reported for faces below the threshold
 	pigoParams     PigoParams
+	angles         []float64 // Detection angles in degrees (nil = upright only)
+	detectMaxDim   int       // Longer edge above which detection runs on a downscaled copy (0 = off)
+	metric         DistanceMetric
+	strategy       MatchStrategy
+	primaryFace    PrimaryFaceMode
+	confidence     ConfidenceScale
+	ordering       ResultOrdering
+	colorOrder     ColorOrder
+	minSharpness   float64              // Minimum Laplacian variance of enrolled faces (0 = disabled)
+	minFacePixels  int                  // Minimum side length of enrolled faces (0 = disabled)
+	maxOcclusion   float64              // Maximum EstimateOcclusion score of recognized and enrolled faces (0 = disabled)
+	cropMargin     float64              // Fraction of the box added on every side of face crops
+	maxSamples     int                  // Maximum samples kept per person (0 = unlimited)
+	retention      RetentionStrategy    // Which samples maxSamples drops
+	minFaces       int                  // Minimum faces per image (0 = unbounded)
+	maxFaces       int                  // Maximum faces per image (0 = unbounded)
+	workers        int                  // Goroutines used by matchPerson (<= 1 = serial)
+	centroids      map[string][]float32 // Cached centroid per person (StrategyCentroid)
+	centroidMu     sync.Mutex
+	dirty          map[string]struct{} // Persons changed since the last SaveDirty (auto-persist off)
+	dirtyMu        sync.Mutex
+	ann            *annIndex                // Approximate candidate search (nil = brute force)
+	encoders       map[string]*namedEncoder // Secondary encoders by name (see WithEncoder)
+	featureCache   *featureCache            // Features by crop hash (nil = disabled)
+	gallery        galleryMatrix            // Packed matching vectors, rebuilt on changes
+	stats          recognizerStats          // Operational counters (see Stats)
+	recognizeHook  func(RecognizeResult)    // Called per recognized face (nil = none)
+	motionThresh   float64                  // Frame change below which RecognizeStream reuses detections (0 = off)
 }
 
-// PigoParams holds Pigo face detector parameters
+// PigoParams holds Pigo face detector parameters. NewFaceRecognizer rejects
+// values the detector can't run with.
 type PigoParams struct {
-	MinSize          int     // Minimum face size
-	MaxSize          int     // Maximum face size
-	ShiftFactor      float64 // Shift factor
-	ScaleFactor      float64 // Scale factor
-	QualityThreshold float32 // Detection quality threshold
+	MinSize          int     // Minimum face size (> 0)
+	MaxSize          int     // Maximum face size (> MinSize)
+	ShiftFactor      float64 // Shift factor, in (0, 1]
+	ScaleFactor      float64 // Scale factor (> 1)
+	QualityThreshold float32 // Detection quality threshold (>= 0)
+
+	// ClusterThreshold is the IoU above which overlapping detections are
+	// merged into one face (default 0.2). Raise it in crowded scenes so
+	// neighbouring faces stay separate; lower it for single-face setups
+	// so duplicate boxes collapse. Zero keeps the default; values outside
+	// [0, 1) are rejected.
+	ClusterThreshold float64
+}
+
+// validate checks that the detector can run with the parameters; a scale
+// factor of at most 1 or a zero minimum size would never finish the scan
+func (p PigoParams) validate() error {
+	switch {
+	case !(p.ScaleFactor > 1):
+		return fmt.Errorf("scale factor must be greater than 1, got %v", p.ScaleFactor)
+	case !(p.ShiftFactor > 0 && p.ShiftFactor <= 1):
+		return fmt.Errorf("shift factor must be in (0, 1], got %v", p.ShiftFactor)
+	case p.MinSize <= 0:
+		return fmt.Errorf("minimum face size must be positive, got %d", p.MinSize)
+	case p.MinSize >= p.MaxSize:
+		return fmt.Errorf("minimum face size %d must be less than maximum face size %d", p.MinSize, p.MaxSize)
+	case !(p.QualityThreshold >= 0):
+		return fmt.Errorf("quality threshold must not be negative, got %v", p.QualityThreshold)
+	case !(p.ClusterThreshold >= 0 && p.ClusterThreshold < 1):
+		// Pigo merges detections whose IoU is strictly above the threshold,
+		// so at 1 even a detection's overlap with itself would not count
+		return fmt.Errorf("cluster threshold must be in [0, 1), got %v", p.ClusterThreshold)
+	}
+
+	return nil
+}
+
+// GrayscaleConverter converts an image into the row-major 8-bit grayscale
+// buffer the Pigo detector runs on
+type GrayscaleConverter interface {
+	Grayscale(img image.Image) (pixels []uint8, width, height int)
+}
+
+// LumaWeights is a GrayscaleConverter computing a weighted sum of the RGB
+// channels. Weights are in thousandths and should sum to 1000.
+type LumaWeights struct {
+	R, G, B uint32
+}
+
+var (
+	// GrayscaleBT601 uses the ITU-R BT.601 luma weights (the default)
+	GrayscaleBT601 = LumaWeights{R: 299, G: 587, B: 114}
+	// GrayscaleBT709 uses the ITU-R BT.709 (HDTV) luma weights
+	GrayscaleBT709 = LumaWeights{R: 213, G: 715, B: 72}
+)
+
+// Grayscale implements GrayscaleConverter
+func (w LumaWeights) Grayscale(img image.Image) ([]uint8, int, int) {
+	bounds := img.Bounds()
+	width, height := bounds.Max.X, bounds.Max.Y
+
+	pixels := make([]uint8, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			gray := uint8((r*w.R + g*w.G + b*w.B) / 1000 / 256)
+			pixels[y*width+x] = gray
+		}
+	}
+
+	return pixels, width, height
+}
+
+// Detection represents a detected face and its Pigo quality score
+type Detection struct {
+	Rect    image.Rectangle
+	Quality float32
+}
+
+// RotatedFace represents a face detected at an in-plane rotation
+type RotatedFace struct {
+	Rect  image.Rectangle // Axis-aligned box bounding the rotated detection
+	Angle float64         // Face rotation in degrees, positive is counter-clockwise
+}
+
+// FaceLandmarks is a detected face with its landmark points, all in image
+// coordinates. A zero pupil means the pupils could not be located.
+type FaceLandmarks struct {
+	Rect       image.Rectangle
+	LeftPupil  image.Point // Pupil on the left side of the image
+	RightPupil image.Point
+
+	// Points are the facial landmarks around the eyes, nose and mouth in the
+	// order of flplocPoints, a zero point where one was not found. Nil
+	// without Config.FlplocCascadeDir, if the pupils were not found or if the
+	// face is rotated.
+	Points []image.Point
+}
+
+// rotatedDetection is a Pigo detection tagged with the angle it was found at
+type rotatedDetection struct {
+	pigo.Detection
+	angle float64
+	rect  image.Rectangle // Bounding box clipped to the image
 }
 
+// maxDetectionAngle is the largest rotation (in degrees) supported by the sweep
+const maxDetectionAngle = 45.0
+
+// detectionAngleStep is the step (in degrees) used by WithDetectionAngle
+const detectionAngleStep = 15.0
+
+// ErrLowQualityFace is returned by AddFaceSample when the face crop fails the
+// enrollment quality check (see WithEnrollmentQuality)
+var ErrLowQualityFace = errors.New("low quality face")
+
+// ErrOccludedFace is returned by AddFaceSample when the face appears covered,
+// e.g. by a mask (see WithMaxOcclusion)
+var ErrOccludedFace = errors.New("occluded face")
+
+// ErrTooFewFaces and ErrTooManyFaces are returned when the number of detected
+// faces is outside the bounds set with WithFaceCountBounds
+var (
+	ErrTooFewFaces  = errors.New("too few faces")
+	ErrTooManyFaces = errors.New("too many faces")
+)
+
+// ErrPersonNotFound is returned when a person ID is not in the database or
+// the storage backend
+var ErrPersonNotFound = errors.New("person not found")
+
+// ErrPersonExists is returned by AddPerson when the ID is already taken
+var ErrPersonExists = errors.New("person already exists")
+
+// ErrNoFaceDetected is returned by enrollment and verification when an image
+// contains no face
+var ErrNoFaceDetected = errors.New("no face detected")
+
+// ErrPupilsNotFound is returned by EyesOpen when the pupils can't be located
+// in the face, e.g. because the face is turned away or the eyes are covered
+var ErrPupilsNotFound = errors.New("pupils not found")
+
+// ErrIncompatibleModel is returned by ReloadModel and ImportDatabase when
+// the new model's or archive's features can't be compared with the enrolled
+// samples
+var ErrIncompatibleModel = errors.New("incompatible model")
+
+// ErrNoEncoder is returned by feature extraction, recognition and enrollment
+// on a detection-only recognizer (see NewFaceDetector)
+var ErrNoEncoder = errors.New("face encoder not configured")
+
+// DefaultUnknownID and DefaultUnknownName label faces below the similarity
+// threshold unless changed with WithUnknownLabel
+const (
+	DefaultUnknownID   = "unknown"
+	DefaultUnknownName = "Unknown"
+)
+
+// defaultClusterThreshold is the IoU used to cluster overlapping detections
+const defaultClusterThreshold = 0.2
+
 // Config holds the basic configuration for FaceRecognizer
 type Config struct {
 	PigoCascadeFile   string
-	FaceEncoderModel  string
+	FaceEncoderModel  string // Empty for a detection-only recognizer
 	FaceEncoderConfig string // Optional config file for some models
+	PuplocCascadeFile string // Optional Pigo pupil localization cascade (required for alignment)
+	FlplocCascadeDir  string // Optional directory of Pigo facial landmark cascades (lp38, lp42, ...)
 }
 
 // Option is a function that configures FaceRecognizer
@@ -157,13 +595,211 @@ func WithSimilarityThreshold(threshold float32) Option {
 	}
 }
 
-// WithPigoParams sets custom Pigo detector parameters
+// WithMinConfidenceMargin sets the minimum confidence gap between the best
+// and second-best person. Faces whose gap is smaller are reported with
+// PersonID "ambiguous" instead of a name. 0 (the default) disables the check.
+func WithMinConfidenceMargin(margin float32) Option {
+	return func(fr *FaceRecognizer) {
+		fr.margin = margin
+	}
+}
+
+// WithUnknownLabel sets the PersonID and PersonName reported for faces below
+// the similarity threshold (default DefaultUnknownID / DefaultUnknownName),
+// e.g. to avoid colliding with an enrolled ID or to localize the name
+func WithUnknownLabel(id, name string) Option {
+	return func(fr *FaceRecognizer) {
+		fr.unknownID = id
+		fr.unknownName = name
+	}
+}
+
+// WithDistanceMetric sets the metric used to compare feature vectors.
+// With MetricEuclidean the similarity threshold is a maximum distance.
+func WithDistanceMetric(metric DistanceMetric) Option {
+	return func(fr *FaceRecognizer) {
+		fr.metric = metric
+	}
+}
+
+// WithConfidenceScaling sets how Recognize and RecognizeTopK report
+// RecognizeResult.Confidence, e.g. ScalePercent for a UI. The threshold and
+// confidence margin still apply to the raw similarity, so matching is
+// unchanged.
+func WithConfidenceScaling(scale ConfidenceScale) Option {
+	return func(fr *FaceRecognizer) {
+		fr.confidence = scale
+	}
+}
+
+// WithMatchStrategy sets how faces are matched against a person's samples
+func WithMatchStrategy(strategy MatchStrategy) Option {
+	return func(fr *FaceRecognizer) {
+		fr.strategy = strategy
+		fr.gallery.invalidate() // Packed rows depend on the strategy
+	}
+}
+
+// WithPrimaryFace sets how the subject is chosen when an image contains
+// several faces (default PrimaryLargest)
+func WithPrimaryFace(mode PrimaryFaceMode) Option {
+	return func(fr *FaceRecognizer) {
+		fr.primaryFace = mode
+	}
+}
+
+// WithResultOrdering sets the order of recognition results (default
+// OrderDetection). With OrderConfidence, results no longer correspond
+// positionally to DetectFaces or to the regions passed to RecognizeRegions;
+// use BoundingBox to relate them.
+func WithResultOrdering(ordering ResultOrdering) Option {
+	return func(fr *FaceRecognizer) {
+		fr.ordering = ordering
+	}
+}
+
+// WithColorOrder sets the channel order of the 3- and 4-channel Mats passed
+// to recognition, enrollment, verification and detection (default
+// ColorOrderBGR). Feature extraction then swaps red and blue only if the
+// input order differs from the model's (see ModelConfig.SwapRB), so RGB
+// input is not silently swapped into the wrong order.
+func WithColorOrder(order ColorOrder) Option {
+	return func(fr *FaceRecognizer) {
+		fr.colorOrder = order
+	}
+}
+
+// swapRB reports whether red and blue must be swapped to turn input in the
+// configured color order into the order a model expects. Grayscale models
+// never swap: their crops are converted to grayscale in the input order.
+func (fr *FaceRecognizer) swapRB(config ModelConfig) bool {
+	if config.channels() == 1 {
+		return false
+	}
+	return config.SwapRB != (fr.colorOrder == ColorOrderRGB)
+}
+
+// WithCropMargin expands every face box by fraction of its width and height
+// on each side (clamped to the image) before the face is cropped for feature
+// extraction, in enrollment, recognition and verification alike. Most models
+// were trained on crops with some forehead and chin margin, which Pigo's
+// tight boxes lack; 0.1-0.2 is a typical value. Reported bounding boxes are
+// not changed.
+func WithCropMargin(fraction float64) Option {
+	return func(fr *FaceRecognizer) {
+		fr.cropMargin = fraction
+	}
+}
+
+// WithEnrollmentQuality makes AddFaceSample reject blurry or tiny faces with
+// ErrLowQualityFace. minSharpness is the minimum variance of the Laplacian of
+// the grayscale crop (around 100 separates sharp from blurred photos);
+// minPixels is the minimum width and height of the crop. Zero disables a check.
+func WithEnrollmentQuality(minSharpness float64, minPixels int) Option {
+	return func(fr *FaceRecognizer) {
+		fr.minSharpness = minSharpness
+		fr.minFacePixels = minPixels
+	}
+}
+
+// WithFaceCountBounds makes Recognize, RecognizeTopK, AddFaceSample and
+// Verify fail with ErrTooFewFaces or ErrTooManyFaces when the number of
+// detected faces is below min or above max, before any feature is extracted
+// (RecognizeStream drops such frames).
+// WithFaceCountBounds(1, 1) enforces exactly one face. Zero or negative
+// bounds leave that side unbounded.
+func WithFaceCountBounds(min, max int) Option {
+	return func(fr *FaceRecognizer) {
+		fr.minFaces = min
+		fr.maxFaces = max
+	}
+}
+
+// WithMaxSamplesPerPerson caps the samples kept per person. When adding
+// samples exceeds n, samples are dropped according to the retention strategy
+// (see WithRetentionStrategy) before the person is persisted, so dropped
+// samples stay dropped after a reload. 0 (the default) keeps every sample.
+func WithMaxSamplesPerPerson(n int) Option {
+	return func(fr *FaceRecognizer) {
+		fr.maxSamples = n
+	}
+}
+
+// WithRetentionStrategy sets which samples are dropped when a person exceeds
+// WithMaxSamplesPerPerson (default RetainNewest)
+func WithRetentionStrategy(strategy RetentionStrategy) Option {
+	return func(fr *FaceRecognizer) {
+		fr.retention = strategy
+	}
+}
+
+// WithANNIndex enables an approximate nearest-neighbor (IVF) index that
+// narrows each match to the persons near the query instead of scanning the
+// whole database. It trades a small loss of recall (the true best match is
+// occasionally missed and the face reported as unknown or as a weaker
+// match) for matching time that grows with about sqrt(N) instead of N.
+// The index is built on first use and kept in sync with sample changes;
+// small databases are always scanned exhaustively.
+func WithANNIndex(enabled bool) Option {
+	return func(fr *FaceRecognizer) {
+		if enabled {
+			fr.ann = &annIndex{}
+		} else {
+			fr.ann = nil
+		}
+	}
+}
+
+// WithMatchConcurrency sets the number of goroutines used to scan persons
+// during recognition. Values below 1 mean a serial scan.
+func WithMatchConcurrency(n int) Option {
+	return func(fr *FaceRecognizer) {
+		fr.workers = n
+	}
+}
+
+// WithResizeInterpolation sets the interpolation used to resize face crops
+// to the model input size (default gocv.InterpolationLinear). Cubic or
+// Lanczos keep more detail when upscaling small faces, at some extra cost.
+func WithResizeInterpolation(interpolation gocv.InterpolationFlags) Option {
+	return func(fr *FaceRecognizer) {
+		fr.interpolation = interpolation
+	}
+}
+
+// WithGrayscaleMethod sets how color images are converted to grayscale for
+// detection, e.g. GrayscaleBT709 or a custom GrayscaleConverter. The default
+// is GrayscaleBT601. Grayscale input (image.Gray, single-channel Mat) is
+// used as-is.
+func WithGrayscaleMethod(converter GrayscaleConverter) Option {
+	return func(fr *FaceRecognizer) {
+		fr.grayscale = converter
+	}
+}
+
+// WithPigoParams sets custom Pigo detector parameters; NewFaceRecognizer
+// returns an error for values outside the ranges documented on PigoParams
 func WithPigoParams(params PigoParams) Option {
 	return func(fr *FaceRecognizer) {
+		if params.ClusterThreshold == 0 {
+			params.ClusterThreshold = defaultClusterThreshold
+		}
 		fr.pigoParams = params
 	}
 }
 
+// WithClusterThreshold sets the IoU above which overlapping detections are
+// merged; zero keeps the default. NewFaceRecognizer returns an error for
+// values outside [0, 1) (see PigoParams.ClusterThreshold).
+func WithClusterThreshold(threshold float64) Option {
+	return func(fr *FaceRecognizer) {
+		if threshold == 0 {
+			threshold = defaultClusterThreshold
+		}
+		fr.pigoParams.ClusterThreshold = threshold
+	}
+}
+
 // WithMinFaceSize sets the minimum face size for detection
 func WithMinFaceSize(size int) Option {
 	return func(fr *FaceRecognizer) {
@@ -178,6 +814,62 @@ func WithMaxFaceSize(size int) Option {
 	}
 }
 
+// WithDetectionMaxDimension runs the detector on a downscaled copy of images
+// whose longer edge exceeds px, which is much faster on large photos. Boxes
+// are still reported in the coordinates of the original image, and the face
+// size limits (WithMinFaceSize, WithMaxFaceSize) keep referring to original
+// pixels. 0 (the default) disables downscaling.
+func WithDetectionMaxDimension(px int) Option {
+	return func(fr *FaceRecognizer) {
+		fr.detectMaxDim = max(0, px)
+	}
+}
+
+// WithDetectionAngle enables rotated detection, sweeping from -maxAngle to
+// +maxAngle degrees in 15° steps. Angles are clamped to ±45°.
+func WithDetectionAngle(maxAngle float64) Option {
+	return func(fr *FaceRecognizer) {
+		maxAngle = math.Min(math.Abs(maxAngle), maxDetectionAngle)
+		angles := []float64{0}
+		for a := detectionAngleStep; a <= maxAngle; a += detectionAngleStep {
+			angles = append(angles, -a, a)
+		}
+		if maxAngle > 0 && math.Mod(maxAngle, detectionAngleStep) != 0 {
+			angles = append(angles, -maxAngle, maxAngle)
+		}
+		fr.angles = angles
+	}
+}
+
+// WithDetectionAngles sets the exact rotation angles (in degrees) swept during detection
+func WithDetectionAngles(angles ...float64) Option {
+	return func(fr *FaceRecognizer) {
+		fr.angles = make([]float64, 0, len(angles))
+		for _, a := range angles {
+			fr.angles = append(fr.angles, math.Max(-maxDetectionAngle, math.Min(a, maxDetectionAngle)))
+		}
+	}
+}
+
+// WithBackend sets the preferred DNN backend and target device for the face
+// encoder, e.g. gocv.NetBackendCUDA with gocv.NetTargetCUDA. CUDA requires
+// OpenCV and gocv built with CUDA support; if the backend can't be used the
+// encoder keeps running on the default CPU backend, which GetBackend reports.
+func WithBackend(backend gocv.NetBackendType, target gocv.NetTargetType) Option {
+	return func(fr *FaceRecognizer) {
+		fr.backend = backend
+		fr.target = target
+	}
+}
+
+// WithAlignment enables aligning faces by their pupils before feature
+// extraction. Requires Config.PuplocCascadeFile.
+func WithAlignment(enabled bool) Option {
+	return func(fr *FaceRecognizer) {
+		fr.alignment = enabled
+	}
+}
+
 // WithStorage sets a custom storage backend
 func WithStorage(storage FaceStorage) Option {
 	return func(fr *FaceRecognizer) {
@@ -185,10 +877,53 @@ func WithStorage(storage FaceStorage) Option {
 	}
 }
 
+// WithAutoPersist controls whether AddPerson, AddFaceSample and RemovePerson
+// write through to the storage backend. It defaults to on for every storage
+// except MemoryStorage. When off, changed persons are tracked and written
+// by SaveDirty.
+func WithAutoPersist(enabled bool) Option {
+	return func(fr *FaceRecognizer) {
+		fr.autoPersist = enabled
+		fr.autoPersistSet = true
+	}
+}
+
+// WithStoreQuantized makes persisted features (storage and SaveDatabase) use
+// the compact 8-bit QuantizedFeature form. Loading dequantizes transparently.
+// SQLiteStorage always stores full float32 blobs, so it saves no space there.
+func WithStoreQuantized(enabled bool) Option {
+	return func(fr *FaceRecognizer) {
+		fr.storeQuantized = enabled
+	}
+}
+
+// WithStrictDimensionCheck sets whether LoadDatabase and loading from storage
+// reject feature vectors whose length differs from the model's FeatureDim,
+// e.g. a 512-dim ArcFace database loaded into a 128-dim OpenFace recognizer.
+// It is on by default; mismatched vectors never match anyone.
+func WithStrictDimensionCheck(enabled bool) Option {
+	return func(fr *FaceRecognizer) {
+		fr.strictDims = enabled
+	}
+}
+
+// WithRecognizeHook sets a function called with each face's result during
+// Recognize, RecognizeContext, RecognizeRegions and RecognizeStream, e.g. for
+// audit logging or metrics. It runs synchronously on the recognizing
+// goroutine right after the face is resolved, before the call returns, so it
+// must not block: hand slow work such as network calls off to a goroutine or
+// channel. It is also called for faces with Error set.
+func WithRecognizeHook(hook func(RecognizeResult)) Option {
+	return func(fr *FaceRecognizer) {
+		fr.recognizeHook = hook
+	}
+}
+
 // NewFaceRecognizer creates a new FaceRecognizer instance
 func NewFaceRecognizer(config Config, opts ...Option) (*FaceRecognizer, error) {
 	fr := &FaceRecognizer{
 		persons:   make(map[string]*Person),
+		centroids: make(map[string][]float32),
 		storage:   NewMemoryStorage(), // Default to memory storage
 		threshold: 0.6,                // Default threshold
 		pigoParams: PigoParams{
@@ -197,8 +932,13 @@ func NewFaceRecognizer(config Config, opts ...Option) (*FaceRecognizer, error) {
 			ShiftFactor:      0.1,
 			ScaleFactor:      1.1,
 			QualityThreshold: 5.0,
+			ClusterThreshold: defaultClusterThreshold,
 		},
-		modelConfig: modelConfigs[ModelOpenFace], // Default model
+		modelConfig:   modelConfigs[ModelOpenFace], // Default model
+		interpolation: gocv.InterpolationLinear,
+		unknownID:     DefaultUnknownID,
+		unknownName:   DefaultUnknownName,
+		strictDims:    true,
 	}
 
 	// Apply options
@@ -206,6 +946,20 @@ func NewFaceRecognizer(config Config, opts ...Option) (*FaceRecognizer, error) {
 		opt(fr)
 	}
 
+	if err := fr.pigoParams.validate(); err != nil {
+		return nil, fmt.Errorf("invalid Pigo parameters: %v", err)
+	}
+	if err := fr.modelConfig.validate(); err != nil {
+		return nil, fmt.Errorf("invalid model config: %v", err)
+	}
+	fr.normalization = fr.modelConfig.Normalization
+
+	// Write through by default unless storage is the volatile in-memory one
+	if !fr.autoPersistSet {
+		_, inMemory := fr.storage.(*MemoryStorage)
+		fr.autoPersist = !inMemory
+	}
+
 	// Load Pigo face detector
 	cascadeFile, err := ioutil.ReadFile(config.PigoCascadeFile)
 	if err != nil {
@@ -219,29 +973,102 @@ func NewFaceRecognizer(config Config, opts ...Option) (*FaceRecognizer, error) {
 	}
 	fr.pigoClassifier = classifier
 
-	// Load face encoder model
-	if config.FaceEncoderConfig != "" {
-		fr.faceEncoder = gocv.ReadNet(config.FaceEncoderModel, config.FaceEncoderConfig)
-	} else {
-		fr.faceEncoder = gocv.ReadNet(config.FaceEncoderModel, "")
-	}
+	// Load Pigo pupil localizer
+	if config.PuplocCascadeFile != "" {
+		puplocFile, err := ioutil.ReadFile(config.PuplocCascadeFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Pigo puploc cascade file: %v", err)
+		}
 
-	if fr.faceEncoder.Empty() {
-		return nil, errors.New("failed to load face encoder model")
+		plc, err := pigo.NewPuplocCascade().UnpackCascade(puplocFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unpack Pigo puploc cascade: %v", err)
+		}
+		fr.puplocCascade = plc
 	}
 
-	// Load existing persons from storage
-	if err := fr.loadFromStorage(); err != nil {
-		return nil, fmt.Errorf("failed to load persons from storage: %v", err)
+	if fr.alignment && fr.puplocCascade == nil {
+		return nil, errors.New("face alignment requires a pupil localization cascade (Config.PuplocCascadeFile)")
 	}
 
-	return fr, nil
-}
+	// Load Pigo facial landmark localizers
+	if config.FlplocCascadeDir != "" {
+		if fr.puplocCascade == nil {
+			return nil, errors.New("facial landmark localization requires a pupil localization cascade (Config.PuplocCascadeFile)")
+		}
 
-// loadFromStorage loads all persons from storage into memory
-func (fr *FaceRecognizer) loadFromStorage() error {
-	persons, err := fr.storage.LoadAllPersons()
-	if err != nil {
+		cascades, err := loadFlplocCascades(config.FlplocCascadeDir)
+		if err != nil {
+			return nil, err
+		}
+		fr.flplocCascades = cascades
+	}
+
+	// Load face encoder model
+	if config.FaceEncoderModel == "" {
+		fr.noEncoder = true
+	} else {
+		fr.faceEncoder = readEncoderNet(config.FaceEncoderModel, config.FaceEncoderConfig)
+		if fr.faceEncoder.Empty() {
+			return nil, errors.New("failed to load face encoder model")
+		}
+
+		if fr.backend != gocv.NetBackendDefault || fr.target != gocv.NetTargetCPU {
+			fr.backend, fr.target = applyBackend(fr.faceEncoder, fr.backend, fr.target)
+		}
+	}
+
+	if err := fr.loadEncoders(); err != nil {
+		return nil, err
+	}
+
+	// Load existing persons from storage
+	if err := fr.loadFromStorage(); err != nil {
+		return nil, fmt.Errorf("failed to load persons from storage: %v", err)
+	}
+
+	return fr, nil
+}
+
+// NewFaceDetector creates a detection-only FaceRecognizer from a Pigo
+// cascade file, without loading an encoder model. Detection (DetectFaces,
+// DetectFacesWithScores, DetectAndCrop, ...) works fully; feature
+// extraction, recognition, verification and enrollment return ErrNoEncoder.
+func NewFaceDetector(cascadeFile string, opts ...Option) (*FaceRecognizer, error) {
+	return NewFaceRecognizer(Config{PigoCascadeFile: cascadeFile}, opts...)
+}
+
+// checkEncoder returns ErrNoEncoder for a detection-only recognizer
+func (fr *FaceRecognizer) checkEncoder() error {
+	if fr.noEncoder {
+		return ErrNoEncoder
+	}
+	return nil
+}
+
+// applyBackend sets the preferred backend and target on an encoder network,
+// falling back to the default CPU backend if they are rejected, and returns
+// the backend and target in effect. The fallback is silent; callers see it
+// through GetBackend.
+func applyBackend(net gocv.Net, backend gocv.NetBackendType, target gocv.NetTargetType) (gocv.NetBackendType, gocv.NetTargetType) {
+	if err := net.SetPreferableBackend(backend); err != nil {
+		backend, target = gocv.NetBackendDefault, gocv.NetTargetCPU
+		net.SetPreferableBackend(backend)
+	}
+
+	if err := net.SetPreferableTarget(target); err != nil {
+		backend, target = gocv.NetBackendDefault, gocv.NetTargetCPU
+		net.SetPreferableBackend(backend)
+		net.SetPreferableTarget(target)
+	}
+
+	return backend, target
+}
+
+// loadFromStorage loads all persons from storage into memory
+func (fr *FaceRecognizer) loadFromStorage() error {
+	persons, err := fr.storedPersons()
+	if err != nil {
 		return err
 	}
 
@@ -258,8 +1085,42 @@ func (fr *FaceRecognizer) loadFromStorage() error {
 	return nil
 }
 
-// Close releases all resources
+// storedPersons loads all persons from storage, checking and normalizing
+// their samples like LoadDatabase
+func (fr *FaceRecognizer) storedPersons() ([]*Person, error) {
+	persons, err := fr.storage.LoadAllPersons()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, person := range persons {
+		if err := fr.checkDimensions(person); err != nil {
+			return nil, err
+		}
+		fr.normalizeSamples(person)
+	}
+
+	return persons, nil
+}
+
+// Close releases all resources, including the storage backend (so stores
+// that flush on close, like JSONStorage, write their final state). Errors
+// from the encoder and the storage are joined.
 func (fr *FaceRecognizer) Close() error {
+	encoderErr := errors.Join(fr.closeEncoder(), fr.closeEncoders())
+
+	var storageErr error
+	if fr.storage != nil {
+		if err := fr.storage.Close(); err != nil {
+			storageErr = fmt.Errorf("failed to close storage: %v", err)
+		}
+	}
+
+	return errors.Join(encoderErr, storageErr)
+}
+
+// closeEncoder releases the face encoder network
+func (fr *FaceRecognizer) closeEncoder() error {
 	// Use defer/recover to handle any CGO panics during cleanup
 	defer func() {
 		if r := recover(); r != nil {
@@ -268,7 +1129,7 @@ func (fr *FaceRecognizer) Close() error {
 		}
 	}()
 
-	if fr.faceEncoder.Empty() {
+	if fr.noEncoder || fr.faceEncoder.Empty() {
 		return nil
 	}
 	return fr.faceEncoder.Close()
@@ -276,24 +1137,239 @@ func (fr *FaceRecognizer) Close() error {
 
 // DetectFaces detects faces in an image using Pigo
 func (fr *FaceRecognizer) DetectFaces(img image.Image) []image.Rectangle {
-	// Convert to grayscale
-	bounds := img.Bounds()
-	width, height := bounds.Max.X, bounds.Max.Y
+	rotated := fr.DetectFacesRotated(img)
 
-	pixels := make([]uint8, width*height)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			// Convert to grayscale using luminosity method
-			gray := uint8((r*299 + g*587 + b*114) / 1000 / 256)
-			pixels[y*width+x] = gray
+	faces := make([]image.Rectangle, 0, len(rotated))
+	for _, face := range rotated {
+		faces = append(faces, face.Rect)
+	}
+
+	return faces
+}
+
+// DetectFacesWithScores detects faces and returns them with their detection quality
+func (fr *FaceRecognizer) DetectFacesWithScores(img image.Image) []Detection {
+	dets := fr.detect(img)
+
+	detections := make([]Detection, 0, len(dets))
+	for _, det := range dets {
+		detections = append(detections, Detection{
+			Rect:    det.rect,
+			Quality: det.Q,
+		})
+	}
+
+	return detections
+}
+
+// DetectFacesRotated detects faces at every configured detection angle.
+// Detections of the same face found at several angles are merged, keeping
+// the angle with the strongest response.
+func (fr *FaceRecognizer) DetectFacesRotated(img image.Image) []RotatedFace {
+	dets := fr.detect(img)
+
+	faces := make([]RotatedFace, 0, len(dets))
+	for _, det := range dets {
+		faces = append(faces, RotatedFace{
+			Rect:  det.rect,
+			Angle: det.angle,
+		})
+	}
+
+	return faces
+}
+
+// DetectFacesWithLandmarks detects faces along with their pupils (requires
+// Config.PuplocCascadeFile) and facial landmark points (requires
+// Config.FlplocCascadeDir). Faces whose landmarks can't be located are
+// still returned, with zero landmarks.
+func (fr *FaceRecognizer) DetectFacesWithLandmarks(img image.Image) []FaceLandmarks {
+	pixels, width, height := fr.toGrayscale(img)
+	dets := fr.detectPixels(pixels, width, height)
+
+	imgParams := pigo.ImageParams{
+		Pixels: pixels,
+		Rows:   height,
+		Cols:   width,
+		Dim:    width,
+	}
+
+	faces := make([]FaceLandmarks, 0, len(dets))
+	for _, det := range dets {
+		face := FaceLandmarks{Rect: det.rect}
+
+		if fr.puplocCascade != nil {
+			left, right, ok := fr.pupilsAt(imgParams, det.Row, det.Col, float32(det.Scale), det.angle)
+			if ok {
+				face.LeftPupil, face.RightPupil = left, right
+
+				// The landmark cascades only handle upright faces
+				if det.angle == 0 {
+					face.Points = fr.landmarkPoints(imgParams, left, right)
+				}
+			}
+		}
+
+		faces = append(faces, face)
+	}
+
+	return faces
+}
+
+// DetectFacesInROI detects faces only inside roi (in img coordinates), e.g.
+// the doorway of a fixed camera, and returns their boxes in img coordinates.
+// Only the ROI is converted and scanned, which is faster and avoids false
+// positives outside it. The ROI is clamped to the image; nil is returned if
+// nothing of it remains.
+func (fr *FaceRecognizer) DetectFacesInROI(img image.Image, roi image.Rectangle) []image.Rectangle {
+	roi = roi.Intersect(img.Bounds())
+	if roi.Empty() {
+		return nil
+	}
+
+	var src image.Image = roiImage{Image: img, rect: roi}
+	if gray, ok := img.(*image.Gray); ok {
+		// Copy the rows so the ROI still takes the grayscale fast path
+		width, height := roi.Dx(), roi.Dy()
+		sub := image.NewGray(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			start := gray.PixOffset(roi.Min.X, roi.Min.Y+y)
+			copy(sub.Pix[y*width:(y+1)*width], gray.Pix[start:start+width])
+		}
+		src = sub
+	}
+
+	dets := fr.detect(src)
+	faces := make([]image.Rectangle, 0, len(dets))
+	for _, det := range dets {
+		faces = append(faces, det.rect.Add(roi.Min))
+	}
+
+	return faces
+}
+
+// roiImage exposes a rectangle of an image with its origin moved to (0, 0),
+// as the grayscale converters expect
+type roiImage struct {
+	image.Image
+	rect image.Rectangle
+}
+
+func (r roiImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, r.rect.Dx(), r.rect.Dy())
+}
+
+func (r roiImage) At(x, y int) color.Color {
+	return r.Image.At(x+r.rect.Min.X, y+r.rect.Min.Y)
+}
+
+// DetectAndCrop detects faces and returns a crop of each, as fed to the
+// encoder: padded by the crop margin (see WithCropMargin) and aligned by the
+// pupils when alignment is enabled. Crops and boxes share indices; the crops
+// can be passed straight to ExtractFeatures.
+//
+// The crops are copies owned by the caller, who must Close every one of them.
+func (fr *FaceRecognizer) DetectAndCrop(img gocv.Mat) ([]gocv.Mat, []image.Rectangle, error) {
+	dets, err := fr.detectMat(img)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert image: %v", err)
+	}
+
+	crops := make([]gocv.Mat, 0, len(dets))
+	boxes := make([]image.Rectangle, 0, len(dets))
+	for _, det := range dets {
+		region := fr.faceRegion(img, det.rect)
+		crop, ok := fr.alignFace(region)
+		if !ok {
+			// Regions share img's pixels; copy so the crop outlives it
+			crop = region.Clone()
 		}
+		region.Close()
+
+		crops = append(crops, crop)
+		boxes = append(boxes, det.rect)
+	}
+
+	return crops, boxes, nil
+}
+
+// DetectPrimaryFace detects faces and returns the dominant one, chosen
+// according to WithPrimaryFace. It reports false if no face is found.
+func (fr *FaceRecognizer) DetectPrimaryFace(img image.Image) (image.Rectangle, bool) {
+	bounds := img.Bounds()
+	return fr.selectPrimaryFace(fr.DetectFaces(img), image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+}
+
+// DetectFacesFromMat detects faces in a Mat. It skips the image.Image
+// conversion of DetectFaces by converting to grayscale with OpenCV.
+func (fr *FaceRecognizer) DetectFacesFromMat(img gocv.Mat) []image.Rectangle {
+	dets, err := fr.detectMat(img)
+	if err != nil {
+		return nil
+	}
+
+	faces := make([]image.Rectangle, 0, len(dets))
+	for _, det := range dets {
+		faces = append(faces, det.rect)
+	}
+
+	return faces
+}
+
+// detectMatWithScores is the Mat counterpart of DetectFacesWithScores
+func (fr *FaceRecognizer) detectMatWithScores(img gocv.Mat) ([]Detection, error) {
+	dets, err := fr.detectMat(img)
+	if err != nil {
+		return nil, err
+	}
+
+	detections := make([]Detection, 0, len(dets))
+	for _, det := range dets {
+		detections = append(detections, Detection{
+			Rect:    det.rect,
+			Quality: det.Q,
+		})
+	}
+
+	return detections, nil
+}
+
+// detect runs the Pigo cascade on an image.Image
+func (fr *FaceRecognizer) detect(img image.Image) []rotatedDetection {
+	pixels, width, height := fr.toGrayscale(img)
+	return fr.detectPixels(pixels, width, height)
+}
+
+// detectMat runs the Pigo cascade on a Mat
+func (fr *FaceRecognizer) detectMat(img gocv.Mat) ([]rotatedDetection, error) {
+	pixels, width, height, err := fr.matGrayscale(img)
+	if err != nil {
+		return nil, err
+	}
+	return fr.detectPixels(pixels, width, height), nil
+}
+
+// detectPixels runs the Pigo cascade at every configured angle over a
+// row-major grayscale buffer and returns the clustered detections above the
+// quality threshold. Buffers larger than WithDetectionMaxDimension are
+// downscaled first.
+func (fr *FaceRecognizer) detectPixels(pixels []uint8, width, height int) []rotatedDetection {
+	bounds := image.Rect(0, 0, width, height)
+	minSize, maxSize := fr.pigoParams.MinSize, fr.pigoParams.MaxSize
+
+	if longest := max(width, height); fr.detectMaxDim > 0 && longest > fr.detectMaxDim {
+		scale := float64(fr.detectMaxDim) / float64(longest)
+		pixels, width, height = downscaleGray(pixels, width, height, scale)
+
+		// Face size limits refer to the original image
+		minSize = max(1, int(float64(minSize)*scale+0.5))
+		maxSize = max(minSize, int(float64(maxSize)*scale+0.5))
 	}
 
 	// Pigo detection parameters
 	cParams := pigo.CascadeParams{
-		MinSize:     fr.pigoParams.MinSize,
-		MaxSize:     fr.pigoParams.MaxSize,
+		MinSize:     minSize,
+		MaxSize:     maxSize,
 		ShiftFactor: fr.pigoParams.ShiftFactor,
 		ScaleFactor: fr.pigoParams.ScaleFactor,
 		ImageParams: pigo.ImageParams{
@@ -304,293 +1380,2467 @@ func (fr *FaceRecognizer) DetectFaces(img image.Image) []image.Rectangle {
 		},
 	}
 
-	// Run cascade detector
-	dets := fr.pigoClassifier.RunCascade(cParams, 0.0)
-	dets = fr.pigoClassifier.ClusterDetections(dets, 0.2)
+	angles := fr.angles
+	if len(angles) == 0 {
+		angles = []float64{0}
+	}
+
+	var dets []rotatedDetection
+	for _, angle := range angles {
+		// Run cascade detector
+		angleDets := fr.pigoClassifier.RunCascade(cParams, pigoAngle(angle))
+		angleDets = fr.pigoClassifier.ClusterDetections(angleDets, fr.pigoParams.ClusterThreshold)
 
-	// Convert to image.Rectangle
-	faces := make([]image.Rectangle, 0, len(dets))
+		for _, det := range angleDets {
+			if det.Q > fr.pigoParams.QualityThreshold {
+				dets = append(dets, rotatedDetection{Detection: det, angle: angle})
+			}
+		}
+	}
+
+	if len(angles) > 1 {
+		dets = mergeRotatedDetections(dets, fr.pigoParams.ClusterThreshold)
+	}
+
+	if width != bounds.Dx() || height != bounds.Dy() {
+		upscaleDetections(dets, float64(bounds.Dx())/float64(width), float64(bounds.Dy())/float64(height))
+	}
+
+	dets = clipDetections(dets, bounds)
+	fr.stats.recordDetection(len(dets))
+
+	return dets
+}
+
+// downscaleGray shrinks a row-major grayscale buffer by scale (< 1),
+// averaging the source pixels covered by each destination pixel
+func downscaleGray(pixels []uint8, width, height int, scale float64) ([]uint8, int, int) {
+	dw := max(1, int(float64(width)*scale+0.5))
+	dh := max(1, int(float64(height)*scale+0.5))
+
+	out := make([]uint8, dw*dh)
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*height/dh, (dy+1)*height/dh
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*width/dw, (dx+1)*width/dw
+
+			sum := 0
+			for y := y0; y < y1; y++ {
+				row := pixels[y*width : (y+1)*width]
+				for _, v := range row[x0:x1] {
+					sum += int(v)
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			out[dy*dw+dx] = uint8((sum + n/2) / n)
+		}
+	}
+
+	return out, dw, dh
+}
+
+// upscaleDetections maps detections found on a downscaled image back to the
+// original, sx and sy being the original/downscaled size ratios per axis
+func upscaleDetections(dets []rotatedDetection, sx, sy float64) {
+	for i := range dets {
+		dets[i].Row = int(float64(dets[i].Row)*sy + 0.5)
+		dets[i].Col = int(float64(dets[i].Col)*sx + 0.5)
+		dets[i].Scale = int(float64(dets[i].Scale)*math.Max(sx, sy) + 0.5)
+	}
+}
+
+// clipDetections sets the bounding box of each detection, clipped to bounds,
+// and drops detections left without any area (e.g. centered on the image
+// edge), so cropping a box never fails
+func clipDetections(dets []rotatedDetection, bounds image.Rectangle) []rotatedDetection {
+	clipped := dets[:0]
 	for _, det := range dets {
-		if det.Q > fr.pigoParams.QualityThreshold {
-			x := det.Col - det.Scale/2
-			y := det.Row - det.Scale/2
-			faces = append(faces, image.Rect(x, y, x+det.Scale, y+det.Scale))
+		det.rect = rotatedBounds(det.Row, det.Col, det.Scale, det.angle).Intersect(bounds)
+		if det.rect.Empty() {
+			continue
 		}
+		clipped = append(clipped, det)
+	}
+	return clipped
+}
+
+// toGrayscale converts an image to a row-major grayscale pixel buffer with
+// the configured converter. An image.Gray whose pixels are already laid out
+// that way is used without copying.
+func (fr *FaceRecognizer) toGrayscale(img image.Image) ([]uint8, int, int) {
+	if gray, ok := img.(*image.Gray); ok && gray.Rect.Min == (image.Point{}) && gray.Stride == gray.Rect.Dx() {
+		width, height := gray.Rect.Dx(), gray.Rect.Dy()
+		return gray.Pix[:width*height], width, height
 	}
 
-	return faces
+	converter := fr.grayscale
+	if converter == nil {
+		converter = GrayscaleBT601
+	}
+	return converter.Grayscale(img)
 }
 
-// ExtractFeature extracts face feature vector using the configured model
-func (fr *FaceRecognizer) ExtractFeature(faceImg gocv.Mat) ([]float32, error) {
-	if faceImg.Empty() {
-		return nil, errors.New("input image is empty")
+// matGrayscale converts a color (in the configured color order) or grayscale
+// Mat to a row-major grayscale pixel buffer. BT.601 conversion is done by
+// OpenCV; other converters run on the image.Image form of the Mat.
+func (fr *FaceRecognizer) matGrayscale(img gocv.Mat) ([]uint8, int, int, error) {
+	if fr.grayscale != nil && fr.grayscale != GrayscaleConverter(GrayscaleBT601) && img.Channels() != 1 && !img.Empty() {
+		// ToImage expects BGR(A)
+		if fr.colorOrder == ColorOrderRGB {
+			bgr := gocv.NewMat()
+			defer bgr.Close()
+
+			code := gocv.ColorRGBToBGR
+			if img.Channels() == 4 {
+				code = gocv.ColorRGBAToBGRA
+			}
+			if err := gocv.CvtColor(img, &bgr, code); err != nil {
+				return nil, 0, 0, err
+			}
+			img = bgr
+		}
+
+		goImg, err := img.ToImage()
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		pixels, width, height := fr.grayscale.Grayscale(goImg)
+		return pixels, width, height, nil
 	}
 
-	// Resize to model's input size
-	resized := gocv.NewMat()
-	defer resized.Close()
-	gocv.Resize(faceImg, &resized, fr.modelConfig.InputSize, 0, 0, gocv.InterpolationLinear)
+	gray := gocv.NewMat()
+	defer gray.Close()
 
-	// Create blob with model-specific parameters
-	blob := gocv.BlobFromImage(
-		resized,
-		fr.modelConfig.ScaleFactor,
-		fr.modelConfig.InputSize,
-		fr.modelConfig.MeanValues,
-		fr.modelConfig.SwapRB,
-		fr.modelConfig.Crop,
-	)
-	defer blob.Close()
+	if err := grayMat(img, &gray, fr.colorOrder); err != nil {
+		return nil, 0, 0, err
+	}
 
-	// Forward pass
-	fr.faceEncoder.SetInput(blob, "")
-	output := fr.faceEncoder.Forward("")
-	defer output.Close()
+	return gray.ToBytes(), gray.Cols(), gray.Rows(), nil
+}
 
-	// Convert to float32 slice
-	feature := make([]float32, output.Total())
-	for i := 0; i < output.Total(); i++ {
-		feature[i] = output.GetFloatAt(0, i)
+// grayMat converts a color Mat in the given channel order, or a grayscale
+// Mat, into a continuous single-channel dst
+func grayMat(img gocv.Mat, dst *gocv.Mat, order ColorOrder) error {
+	if img.Empty() {
+		return errors.New("empty image")
 	}
 
-	// L2 normalization
-	return normalizeFeature(feature), nil
+	rgb := order == ColorOrderRGB
+	switch img.Channels() {
+	case 1:
+		// Copy so the buffer is continuous even for ROIs
+		return img.CopyTo(dst)
+	case 4:
+		if rgb {
+			return gocv.CvtColor(img, dst, gocv.ColorRGBAToGray)
+		}
+		return gocv.CvtColor(img, dst, gocv.ColorBGRAToGray)
+	default:
+		if rgb {
+			return gocv.CvtColor(img, dst, gocv.ColorRGBToGray)
+		}
+		return gocv.CvtColor(img, dst, gocv.ColorBGRToGray)
+	}
 }
 
-// AddPerson adds a new person to the recognition database
-func (fr *FaceRecognizer) AddPerson(id, name string) error {
-	fr.mu.Lock()
-	defer fr.mu.Unlock()
+// laplacianVariance measures image sharpness as the variance of the
+// Laplacian of its grayscale version; blurred images score low
+func laplacianVariance(img gocv.Mat, order ColorOrder) (float64, error) {
+	gray := gocv.NewMat()
+	defer gray.Close()
+	if err := grayMat(img, &gray, order); err != nil {
+		return 0, err
+	}
 
-	if _, exists := fr.persons[id]; exists {
-		return fmt.Errorf("person ID %s already exists", id)
+	laplacian := gocv.NewMat()
+	defer laplacian.Close()
+	if err := gocv.Laplacian(gray, &laplacian, gocv.MatTypeCV64F, 1, 1, 0, gocv.BorderDefault); err != nil {
+		return 0, err
 	}
 
-	person := &Person{
-		ID:       id,
-		Name:     name,
-		Features: make([]FaceFeature, 0),
+	mean := gocv.NewMat()
+	defer mean.Close()
+	stdDev := gocv.NewMat()
+	defer stdDev.Close()
+	if err := gocv.MeanStdDev(laplacian, &mean, &stdDev); err != nil {
+		return 0, err
 	}
 
-	fr.persons[id] = person
+	sd := stdDev.GetDoubleAt(0, 0)
+	return sd * sd, nil
+}
+
+// checkEnrollmentQuality rejects face crops that are smaller or blurrier
+// than allowed by WithEnrollmentQuality, or more occluded than allowed by
+// WithMaxOcclusion
+func (fr *FaceRecognizer) checkEnrollmentQuality(face gocv.Mat) error {
+	if fr.minFacePixels > 0 {
+		if size := min(face.Cols(), face.Rows()); size < fr.minFacePixels {
+			return fmt.Errorf("%w: face is %dpx, minimum is %dpx", ErrLowQualityFace, size, fr.minFacePixels)
+		}
+	}
+
+	if fr.minSharpness > 0 {
+		sharpness, err := laplacianVariance(face, fr.colorOrder)
+		if err != nil {
+			return fmt.Errorf("failed to measure sharpness: %v", err)
+		}
+		if sharpness < fr.minSharpness {
+			return fmt.Errorf("%w: sharpness %.1f is below %.1f", ErrLowQualityFace, sharpness, fr.minSharpness)
+		}
+	}
+
+	if occlusion, occluded := fr.occluded(face); occluded {
+		return fmt.Errorf("%w: occlusion %.2f is above %.2f", ErrOccludedFace, occlusion, fr.maxOcclusion)
+	}
+
+	return nil
+}
+
+// occluded estimates the occlusion of a face crop if WithMaxOcclusion is
+// enabled and reports whether it is above the maximum
+func (fr *FaceRecognizer) occluded(face gocv.Mat) (float64, bool) {
+	if fr.maxOcclusion <= 0 {
+		return 0, false
+	}
+
+	occlusion := fr.EstimateOcclusion(face)
+	return occlusion, occlusion > fr.maxOcclusion
+}
+
+// pigoAngle converts degrees to Pigo's angle unit (fraction of a full turn in [0, 1])
+func pigoAngle(degrees float64) float64 {
+	turn := math.Mod(degrees, 360) / 360
+	if turn < 0 {
+		turn += 1
+	}
+	return turn
+}
+
+// rotatedBounds returns the axis-aligned rectangle bounding a square detection
+// of the given size centred at (row, col) and rotated by angle degrees
+func rotatedBounds(row, col, scale int, angle float64) image.Rectangle {
+	rad := angle * math.Pi / 180
+	side := int(math.Round(float64(scale) * (math.Abs(math.Cos(rad)) + math.Abs(math.Sin(rad)))))
+
+	x := col - side/2
+	y := row - side/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// mergeRotatedDetections merges detections of the same face found at different
+// angles. Overlapping detections are suppressed in favour of the strongest one.
+func mergeRotatedDetections(dets []rotatedDetection, iouThreshold float64) []rotatedDetection {
+	sort.SliceStable(dets, func(i, j int) bool {
+		return dets[i].Q > dets[j].Q
+	})
+
+	merged := make([]rotatedDetection, 0, len(dets))
+	for _, det := range dets {
+		duplicate := false
+		for _, kept := range merged {
+			if detectionIoU(det.Detection, kept.Detection) > iouThreshold {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			merged = append(merged, det)
+		}
+	}
+
+	return merged
+}
+
+// detectionIoU computes the intersection over union of two square Pigo detections
+func detectionIoU(a, b pigo.Detection) float64 {
+	r1, c1, s1 := float64(a.Row), float64(a.Col), float64(a.Scale)
+	r2, c2, s2 := float64(b.Row), float64(b.Col), float64(b.Scale)
+
+	overRow := math.Max(0, math.Min(r1+s1/2, r2+s2/2)-math.Max(r1-s1/2, r2-s2/2))
+	overCol := math.Max(0, math.Min(c1+s1/2, c2+s2/2)-math.Max(c1-s1/2, c2-s2/2))
+
+	return overRow * overCol / (s1*s1 + s2*s2 - overRow*overCol)
+}
+
+// ExtractFeature extracts face feature vector using the configured model.
+// With WithFeatureCache, a crop seen before is answered from the cache.
+func (fr *FaceRecognizer) ExtractFeature(faceImg gocv.Mat) ([]float32, error) {
+	feature, err := fr.cachedFeature(faceImg)
+	fr.stats.recordExtractions(1, err)
+	return feature, err
+}
+
+// cachedFeature extracts a feature with the primary encoder, using the
+// feature cache if enabled
+func (fr *FaceRecognizer) cachedFeature(faceImg gocv.Mat) ([]float32, error) {
+	if err := fr.checkEncoder(); err != nil {
+		return nil, err
+	}
+
+	fr.modelMu.RLock()
+	defer fr.modelMu.RUnlock()
+	if fr.featureCache == nil || faceImg.Empty() {
+		return fr.extractFeature(faceImg, fr.modelConfig, fr.forward)
+	}
+
+	key := featureCacheKey(faceImg)
+	if feature, ok := fr.featureCache.get(key); ok {
+		return feature, nil
+	}
+
+	feature, err := fr.extractFeature(faceImg, fr.modelConfig, fr.forward)
+	if err != nil {
+		return nil, err
+	}
+	fr.featureCache.put(key, feature)
+
+	return feature, nil
+}
+
+// extractFeature preprocesses a face crop for a model and runs it through
+// forward
+func (fr *FaceRecognizer) extractFeature(faceImg gocv.Mat, config ModelConfig, forward func(gocv.Mat) gocv.Mat) ([]float32, error) {
+	if faceImg.Empty() {
+		return nil, errors.New("input image is empty")
+	}
+
+	// Resize to model's input size
+	resized := gocv.NewMat()
+	defer resized.Close()
+	if err := fr.resizeForModel(faceImg, &resized, config); err != nil {
+		return nil, err
+	}
+
+	// Create blob with model-specific parameters
+	blob := gocv.BlobFromImage(
+		resized,
+		config.ScaleFactor,
+		config.InputSize,
+		config.MeanValues,
+		fr.swapRB(config),
+		config.Crop,
+	)
+	defer blob.Close()
+
+	// Forward pass
+	output := forward(blob)
+	defer output.Close()
+
+	// Convert to float32 slice
+	feature := make([]float32, output.Total())
+	for i := 0; i < output.Total(); i++ {
+		feature[i] = output.GetFloatAt(0, i)
+	}
+
+	return config.Normalization.normalize(feature), nil
+}
+
+// resizeForModel resizes a face crop to a model's input size into dst,
+// converting it to grayscale first for single-channel models
+func (fr *FaceRecognizer) resizeForModel(faceImg gocv.Mat, dst *gocv.Mat, config ModelConfig) error {
+	if config.channels() == 1 {
+		gray := gocv.NewMat()
+		defer gray.Close()
+		if err := grayMat(faceImg, &gray, fr.colorOrder); err != nil {
+			return fmt.Errorf("failed to convert to grayscale: %v", err)
+		}
+		faceImg = gray
+	}
+
+	gocv.Resize(faceImg, dst, config.InputSize, 0, 0, fr.interpolation)
+	return nil
+}
+
+// Warmup runs one forward pass on a blank face so the encoder's lazy layer
+// initialization happens now rather than during the first recognition, and
+// checks that the model produces features of the configured dimension.
+// Calling it is optional and safe at any time, even concurrently with
+// recognition.
+func (fr *FaceRecognizer) Warmup() error {
+	if err := fr.checkEncoder(); err != nil {
+		return err
+	}
+
+	fr.modelMu.RLock()
+	defer fr.modelMu.RUnlock()
+
+	size := fr.modelConfig.InputSize
+	blank := gocv.NewMatWithSize(size.Y, size.X, gocv.MatTypeCV8UC3)
+	defer blank.Close()
+
+	// Bypass the feature cache so inference always runs
+	feature, err := fr.extractFeature(blank, fr.modelConfig, fr.forward)
+	if err != nil {
+		return fmt.Errorf("warm-up failed: %v", err)
+	}
+	if dim := fr.modelConfig.FeatureDim; dim > 0 && len(feature) != dim {
+		return fmt.Errorf("warm-up produced a %d-dim feature, expected %d for model %s", len(feature), dim, fr.modelConfig.Type)
+	}
+
+	return nil
+}
+
+// ExtractFeatures extracts feature vectors for multiple face crops using a
+// single batched forward pass
+func (fr *FaceRecognizer) ExtractFeatures(faces []gocv.Mat) ([][]float32, error) {
+	features, err := fr.extractFeatures(faces)
+	fr.stats.recordExtractions(len(faces), err)
+	return features, err
+}
+
+// extractFeatures implements ExtractFeatures
+func (fr *FaceRecognizer) extractFeatures(faces []gocv.Mat) ([][]float32, error) {
+	if err := fr.checkEncoder(); err != nil {
+		return nil, err
+	}
+
+	fr.modelMu.RLock()
+	defer fr.modelMu.RUnlock()
+	if len(faces) == 0 {
+		return [][]float32{}, nil
+	}
+
+	for i, face := range faces {
+		if face.Empty() {
+			return nil, fmt.Errorf("input image %d is empty", i)
+		}
+	}
+
+	// Resize every crop to model's input size
+	resized := make([]gocv.Mat, len(faces))
+	for i, face := range faces {
+		resized[i] = gocv.NewMat()
+		defer resized[i].Close()
+		if err := fr.resizeForModel(face, &resized[i], fr.modelConfig); err != nil {
+			return nil, fmt.Errorf("input image %d: %v", i, err)
+		}
+	}
+
+	// Stack all crops into one (N, C, H, W) blob
+	blob := gocv.NewMat()
+	defer blob.Close()
+	gocv.BlobFromImages(
+		resized,
+		&blob,
+		fr.modelConfig.ScaleFactor,
+		fr.modelConfig.InputSize,
+		fr.modelConfig.MeanValues,
+		fr.swapRB(fr.modelConfig),
+		fr.modelConfig.Crop,
+		gocv.MatTypeCV32F,
+	)
+
+	// Forward pass
+	output := fr.forward(blob)
+	defer output.Close()
+
+	n := len(faces)
+	if output.Total()%n != 0 {
+		return nil, fmt.Errorf("unexpected output size %d for batch of %d", output.Total(), n)
+	}
+	dim := output.Total() / n
+
+	// View the output as (N, FeatureDim), one row per face
+	rows := output.Reshape(1, n)
+	defer rows.Close()
+
+	features := make([][]float32, n)
+	for i := 0; i < n; i++ {
+		feature := make([]float32, dim)
+		for j := 0; j < dim; j++ {
+			feature[j] = rows.GetFloatAt(i, j)
+		}
+
+		features[i] = fr.modelConfig.Normalization.normalize(feature)
+	}
+
+	return features, nil
+}
+
+// extractFaceFeature extracts the feature of a detected face crop, aligning
+// it by the pupils first when alignment is enabled. If the pupils can't be
+// located the unaligned crop is used.
+func (fr *FaceRecognizer) extractFaceFeature(face gocv.Mat) ([]float32, error) {
+	if aligned, ok := fr.alignFace(face); ok {
+		defer aligned.Close()
+		return fr.ExtractFeature(aligned)
+	}
+
+	return fr.ExtractFeature(face)
+}
+
+// alignFace returns a face crop rotated level by its pupils when alignment is
+// enabled and the pupils can be located. The caller must close it.
+func (fr *FaceRecognizer) alignFace(face gocv.Mat) (gocv.Mat, bool) {
+	if !fr.alignment || fr.puplocCascade == nil {
+		return gocv.Mat{}, false
+	}
+
+	leftEye, rightEye, ok := fr.locatePupils(face)
+	if !ok {
+		return gocv.Mat{}, false
+	}
+	return AlignFace(face, leftEye, rightEye), true
+}
+
+// locatePupils finds the left and right pupils in a face crop
+func (fr *FaceRecognizer) locatePupils(face gocv.Mat) (image.Point, image.Point, bool) {
+	if face.Empty() {
+		return image.Point{}, image.Point{}, false
+	}
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	if err := grayMat(face, &gray, fr.colorOrder); err != nil {
+		return image.Point{}, image.Point{}, false
+	}
+
+	rows, cols := gray.Rows(), gray.Cols()
+	imgParams := pigo.ImageParams{
+		Pixels: gray.ToBytes(),
+		Rows:   rows,
+		Cols:   cols,
+		Dim:    cols,
+	}
+
+	return fr.pupilsAt(imgParams, rows/2, cols/2, float32(min(rows, cols)), 0)
+}
+
+// pupilsAt finds the left and right pupils of a face of size scale centred
+// at (row, col) and rotated by angle degrees
+func (fr *FaceRecognizer) pupilsAt(imgParams pigo.ImageParams, row, col int, scale float32, angle float64) (image.Point, image.Point, bool) {
+	// Eye search windows relative to the face, as used by Pigo's examples,
+	// rotated with the face
+	rad := angle * math.Pi / 180
+	sin, cos := math.Sincos(rad)
+	locate := func(dx, dy float64) *pigo.Puploc {
+		return fr.puplocCascade.RunDetector(pigo.Puploc{
+			Row:      row + int(float64(scale)*(dy*cos-dx*sin)),
+			Col:      col + int(float64(scale)*(dx*cos+dy*sin)),
+			Scale:    scale * 0.25,
+			Perturbs: 63,
+		}, imgParams, pigoAngle(angle), false)
+	}
+
+	left := locate(-0.175, -0.075)
+	right := locate(0.185, -0.075)
+
+	if left.Row <= 0 || left.Col <= 0 || right.Row <= 0 || right.Col <= 0 || left.Col >= right.Col {
+		return image.Point{}, image.Point{}, false
+	}
+
+	return image.Pt(left.Col, left.Row), image.Pt(right.Col, right.Row), true
+}
+
+// flplocPoints are the Pigo facial landmark cascades in the order of
+// FaceLandmarks.Points, as run by Pigo's examples. Mirrored cascades are
+// flipped to locate the same point on the other side of the face.
+var flplocPoints = []struct {
+	cascade  string
+	mirrored bool
+}{
+	{"lp46", false}, {"lp46", true},
+	{"lp44", false}, {"lp44", true},
+	{"lp42", false}, {"lp42", true},
+	{"lp38", false}, {"lp38", true},
+	{"lp312", false}, {"lp312", true},
+	{"lp93", false},
+	{"lp84", false}, {"lp84", true},
+	{"lp82", false},
+	{"lp81", false},
+}
+
+// loadFlplocCascades loads the facial landmark cascades of flplocPoints from
+// a directory such as Pigo's cascade/lps
+func loadFlplocCascades(dir string) (map[string]*pigo.PuplocCascade, error) {
+	cascades := make(map[string]*pigo.PuplocCascade)
+	for _, point := range flplocPoints {
+		if _, loaded := cascades[point.cascade]; loaded {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, point.cascade))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Pigo facial landmark cascade %s: %v", point.cascade, err)
+		}
+
+		flpc, err := pigo.NewPuplocCascade().UnpackCascade(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unpack Pigo facial landmark cascade %s: %v", point.cascade, err)
+		}
+		cascades[point.cascade] = flpc
+	}
+
+	return cascades, nil
+}
+
+// landmarkPoints locates the facial landmarks of flplocPoints from the
+// pupils, or returns nil if no landmark cascades are loaded
+func (fr *FaceRecognizer) landmarkPoints(imgParams pigo.ImageParams, leftEye, rightEye image.Point) []image.Point {
+	if fr.flplocCascades == nil {
+		return nil
+	}
+
+	left := &pigo.Puploc{Row: leftEye.Y, Col: leftEye.X}
+	right := &pigo.Puploc{Row: rightEye.Y, Col: rightEye.X}
+
+	points := make([]image.Point, len(flplocPoints))
+	for i, point := range flplocPoints {
+		flp := fr.flplocCascades[point.cascade].GetLandmarkPoint(left, right, imgParams, 63, point.mirrored)
+		if flp.Row > 0 && flp.Col > 0 {
+			points[i] = image.Pt(flp.Col, flp.Row)
+		}
+	}
+
+	return points
+}
+
+// eyeOpenContrast is the minimum relative brightness difference between the
+// band beside a pupil and the pupil itself for EyesOpen to count the eye as
+// open. Open eyes show a dark iris against the sclera; a closed lid is
+// nearly uniform skin.
+const eyeOpenContrast = 0.15
+
+// EyesOpen reports whether both eyes of the face at faceRect (in img
+// coordinates, e.g. from DetectFaces) appear open. It locates the pupils
+// with the pupil localization cascade (Config.PuplocCascadeFile) and compares
+// the brightness of each pupil with the band beside it. This is a cheap
+// first gate against photo spoofing on a single frame, not full liveness
+// detection. ErrPupilsNotFound is returned when the pupils can't be located.
+func (fr *FaceRecognizer) EyesOpen(img gocv.Mat, faceRect image.Rectangle) (bool, error) {
+	if fr.puplocCascade == nil {
+		return false, errors.New("eye detection requires a pupil localization cascade (Config.PuplocCascadeFile)")
+	}
+
+	region := faceRect.Intersect(image.Rect(0, 0, img.Cols(), img.Rows()))
+	if region.Empty() {
+		return false, fmt.Errorf("face rectangle %v is outside the image", faceRect)
+	}
+
+	face := img.Region(region)
+	defer face.Close()
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	if err := grayMat(face, &gray, fr.colorOrder); err != nil {
+		return false, fmt.Errorf("failed to convert image: %v", err)
+	}
+
+	leftEye, rightEye, ok := fr.locatePupils(gray)
+	if !ok {
+		return false, ErrPupilsNotFound
+	}
+
+	pixels, width, height := gray.ToBytes(), gray.Cols(), gray.Rows()
+	radius := max(2, min(width, height)/20)
+	left := eyeOpenness(pixels, width, height, leftEye, radius)
+	right := eyeOpenness(pixels, width, height, rightEye, radius)
+
+	return left >= eyeOpenContrast && right >= eyeOpenContrast, nil
+}
+
+// eyeOpenness returns how much brighter the horizontal band beside a pupil
+// (up to 2*radius away) is than the pupil itself (within radius/2), relative
+// to the band's brightness. Pixels outside the buffer are ignored.
+func eyeOpenness(pixels []uint8, width, height int, pupil image.Point, radius int) float64 {
+	mean := func(x0, x1, y0, y1 int, skip func(x int) bool) (float64, bool) {
+		sum, n := 0, 0
+		for y := max(0, y0); y <= min(height-1, y1); y++ {
+			for x := max(0, x0); x <= min(width-1, x1); x++ {
+				if skip != nil && skip(x) {
+					continue
+				}
+				sum += int(pixels[y*width+x])
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, false
+		}
+		return float64(sum) / float64(n), true
+	}
+
+	core := radius / 2
+	pupilMean, ok := mean(pupil.X-core, pupil.X+core, pupil.Y-core, pupil.Y+core, nil)
+	if !ok {
+		return 0
+	}
+	bandMean, ok := mean(pupil.X-2*radius, pupil.X+2*radius, pupil.Y-core, pupil.Y+core, func(x int) bool {
+		return x >= pupil.X-radius && x <= pupil.X+radius
+	})
+	if !ok || bandMean == 0 {
+		return 0
+	}
+
+	return (bandMean - pupilMean) / bandMean
+}
+
+// AlignFace rotates a face image around the midpoint between the eyes so
+// that the eyes lie on a horizontal line. The caller must close the result.
+func AlignFace(img gocv.Mat, leftEye, rightEye image.Point) gocv.Mat {
+	dx := float64(rightEye.X - leftEye.X)
+	dy := float64(rightEye.Y - leftEye.Y)
+	angle := math.Atan2(dy, dx) * 180 / math.Pi
+
+	center := image.Pt((leftEye.X+rightEye.X)/2, (leftEye.Y+rightEye.Y)/2)
+	rotation := gocv.GetRotationMatrix2D(center, angle, 1.0)
+	defer rotation.Close()
+
+	aligned := gocv.NewMat()
+	gocv.WarpAffine(img, &aligned, rotation, image.Pt(img.Cols(), img.Rows()))
+
+	return aligned
+}
+
+// AddPerson adds a new person to the recognition database
+func (fr *FaceRecognizer) AddPerson(id, name string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if _, exists := fr.persons[id]; exists {
+		return fmt.Errorf("%w: %s", ErrPersonExists, id)
+	}
+
+	person := &Person{
+		ID:       id,
+		Name:     name,
+		Features: make([]FaceFeature, 0),
+	}
+
+	fr.persons[id] = person
+
+	// Save to storage
+	if err := fr.persistPerson(person); err != nil {
+		// Rollback in-memory change if storage fails
+		delete(fr.persons, id)
+		return fmt.Errorf("failed to save person to storage: %v", err)
+	}
+
+	return nil
+}
+
+// AddFaceSample adds a face sample for a specific person. If the image
+// contains several faces, the primary one is used (see WithPrimaryFace).
+func (fr *FaceRecognizer) AddFaceSample(personID string, img gocv.Mat) error {
+	return fr.AddFaceSampleContext(context.Background(), personID, img)
+}
+
+// AddFaceSampleContext is like AddFaceSample but returns ctx.Err() if the
+// context is done before the sample is stored
+func (fr *FaceRecognizer) AddFaceSampleContext(ctx context.Context, personID string, img gocv.Mat) error {
+	return fr.addFaceSample(ctx, personID, img, "")
+}
+
+// AddFaceSampleFromFile is like AddFaceSample for an image file (see
+// LoadImage), recording its path as the sample's SourceImage
+func (fr *FaceRecognizer) AddFaceSampleFromFile(personID, path string) error {
+	img, err := LoadImage(path)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	return fr.addFaceSample(context.Background(), personID, img, path)
+}
+
+// addFaceSample enrolls the primary face of img for a person, recording
+// source as the sample's SourceImage
+func (fr *FaceRecognizer) addFaceSample(ctx context.Context, personID string, img gocv.Mat, source string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fr.mu.RLock()
+	person, exists := fr.persons[personID]
+	fr.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, personID)
+	}
+
+	sample, err := fr.enrollmentFeature(img)
+	if err != nil {
+		return err
+	}
+	sample.PersonID = personID
+	sample.SourceImage = source
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Add feature to person
+	person.mu.Lock()
+	previous := person.Features
+	features := make([]FaceFeature, 0, len(previous)+1)
+	features = append(features, previous...)
+	person.Features = fr.retainSamples(append(features, sample))
+	person.mu.Unlock()
+
+	// Save updated person to storage, rolling back if it fails
+	return fr.replaceFeatures(person, previous)
+}
+
+// AddFaceSamples enrolls the primary face of every image for a person and
+// persists the person once at the end. Images that fail (no face, low
+// quality, extraction error) are reported in errs, each prefixed with its
+// index in imgs, while the others are still enrolled. added is the number of
+// samples stored; it is 0 if the person does not exist or storage fails.
+func (fr *FaceRecognizer) AddFaceSamples(personID string, imgs []gocv.Mat) (added int, errs []error) {
+	if err := fr.checkEncoder(); err != nil {
+		return 0, []error{err}
+	}
+
+	fr.mu.RLock()
+	person, exists := fr.persons[personID]
+	fr.mu.RUnlock()
+
+	if !exists {
+		return 0, []error{fmt.Errorf("%w: %s", ErrPersonNotFound, personID)}
+	}
+
+	samples := make([]FaceFeature, 0, len(imgs))
+	for i, img := range imgs {
+		sample, err := fr.enrollmentFeature(img)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("image %d: %w", i, err))
+			continue
+		}
+		sample.PersonID = personID
+		samples = append(samples, sample)
+	}
+
+	if len(samples) == 0 {
+		return 0, errs
+	}
+
+	person.mu.Lock()
+	previous := person.Features
+	features := make([]FaceFeature, 0, len(previous)+len(samples))
+	features = append(features, previous...)
+	person.Features = fr.retainSamples(append(features, samples...))
+	person.mu.Unlock()
+
+	if err := fr.replaceFeatures(person, previous); err != nil {
+		return 0, append(errs, err)
+	}
+
+	return len(samples), errs
+}
+
+// retainSamples trims features (oldest first) to the configured sample limit
+// according to the retention strategy
+func (fr *FaceRecognizer) retainSamples(features []FaceFeature) []FaceFeature {
+	if fr.maxSamples <= 0 || len(features) <= fr.maxSamples {
+		return features
+	}
+
+	if fr.retention != RetainMostRepresentative {
+		return features[len(features)-fr.maxSamples:]
+	}
+
+	for len(features) > fr.maxSamples {
+		// Removing sample x from n samples moves the mean by (mean-x)/(n-1),
+		// so the sample closest to the centroid changes it least
+		centroid := computeCentroid(features)
+		drop := 0
+		for i := range features {
+			if euclideanDistance(features[i].Feature, centroid) < euclideanDistance(features[drop].Feature, centroid) {
+				drop = i
+			}
+		}
+		features = append(features[:drop], features[drop+1:]...)
+	}
+
+	return features
+}
+
+// enrollmentFeature detects the primary face of an image, checks its quality
+// and extracts its feature. The sample has its creation time and detection
+// quality set; the caller sets PersonID.
+func (fr *FaceRecognizer) enrollmentFeature(img gocv.Mat) (FaceFeature, error) {
+	if err := fr.checkEncoder(); err != nil {
+		return FaceFeature{}, err
+	}
+
+	primary, err := fr.locatePrimaryFace(img)
+	if err != nil {
+		return FaceFeature{}, err
+	}
+
+	faceRegion := fr.faceRegion(img, primary.Rect)
+	defer faceRegion.Close()
+
+	if err := fr.checkEnrollmentQuality(faceRegion); err != nil {
+		return FaceFeature{}, err
+	}
+
+	feature, err := fr.extractFaceFeature(faceRegion)
+	if err != nil {
+		return FaceFeature{}, fmt.Errorf("failed to extract feature: %v", err)
+	}
+
+	return FaceFeature{
+		Feature:   feature,
+		CreatedAt: time.Now().UTC(),
+		Quality:   primary.Quality,
+	}, nil
+}
+
+// RemoveFaceSample removes the sample at index from a person
+func (fr *FaceRecognizer) RemoveFaceSample(personID string, index int) error {
+	fr.mu.RLock()
+	person, exists := fr.persons[personID]
+	fr.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, personID)
+	}
+
+	person.mu.Lock()
+	previous := person.Features
+	if index < 0 || index >= len(previous) {
+		person.mu.Unlock()
+		return fmt.Errorf("sample index %d out of range for person %s (%d samples)", index, personID, len(previous))
+	}
+	features := make([]FaceFeature, 0, len(previous)-1)
+	features = append(features, previous[:index]...)
+	person.Features = append(features, previous[index+1:]...)
+	person.mu.Unlock()
+
+	return fr.replaceFeatures(person, previous)
+}
+
+// ClearFaceSamples removes all samples from a person, keeping the person enrolled
+func (fr *FaceRecognizer) ClearFaceSamples(personID string) error {
+	fr.mu.RLock()
+	person, exists := fr.persons[personID]
+	fr.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, personID)
+	}
+
+	person.mu.Lock()
+	previous := person.Features
+	person.Features = []FaceFeature{}
+	person.mu.Unlock()
+
+	return fr.replaceFeatures(person, previous)
+}
+
+// replaceFeatures persists a person whose samples were just replaced,
+// restoring the previous samples if storage fails
+func (fr *FaceRecognizer) replaceFeatures(person *Person, previous []FaceFeature) error {
+	fr.invalidateMatchCache(person.ID)
+
+	if err := fr.persistPerson(person); err != nil {
+		person.mu.Lock()
+		person.Features = previous
+		person.mu.Unlock()
+		fr.invalidateMatchCache(person.ID)
+		return fmt.Errorf("failed to save person to storage: %v", err)
+	}
+
+	return nil
+}
+
+// Recognize recognizes faces in an image
+func (fr *FaceRecognizer) Recognize(img gocv.Mat) ([]RecognizeResult, error) {
+	return fr.RecognizeContext(context.Background(), img)
+}
+
+// RecognizeContext is like Recognize but checks ctx between faces and
+// returns ctx.Err() once the context is done
+func (fr *FaceRecognizer) RecognizeContext(ctx context.Context, img gocv.Mat) ([]RecognizeResult, error) {
+	faces, err := fr.extractFaces(ctx, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return fr.recognizeFaces(ctx, faces)
+}
+
+// RecognizeRegions recognizes faces in caller-supplied regions (e.g. from a
+// tracker) without running detection. Regions are clamped to the image;
+// regions that end up empty are skipped, and the results for the remaining
+// regions are returned together with an error listing the skipped indices.
+// Regions whose feature extraction fails yield a result with Error set.
+func (fr *FaceRecognizer) RecognizeRegions(img gocv.Mat, regions []image.Rectangle) ([]RecognizeResult, error) {
+	if err := fr.checkEncoder(); err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, img.Cols(), img.Rows())
+
+	faces := make([]extractedFace, 0, len(regions))
+	var skipped []int
+
+	for i, region := range regions {
+		region = region.Intersect(bounds)
+		if region.Empty() {
+			skipped = append(skipped, i)
+			continue
+		}
+
+		faces = append(faces, fr.extractFace(img, Detection{Rect: region}))
+	}
+
+	results, err := fr.recognizeFaces(context.Background(), faces)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(skipped) > 0 {
+		return results, fmt.Errorf("skipped %d of %d region(s) (empty after clamping): %v", len(skipped), len(regions), skipped)
+	}
+
+	return results, nil
+}
+
+// recognizeFaces matches extracted faces against the database
+func (fr *FaceRecognizer) recognizeFaces(ctx context.Context, faces []extractedFace) ([]RecognizeResult, error) {
+	results := make([]RecognizeResult, 0, len(faces))
+
+	// Recognize each detected face
+	for _, face := range faces {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result := fr.resolveFace(face)
+		results = append(results, result)
+
+		if fr.recognizeHook != nil {
+			fr.recognizeHook(result)
+		}
+	}
+
+	if fr.ordering == OrderConfidence {
+		fr.sortByConfidence(results)
+	}
+
+	return results, nil
+}
+
+// resolveFace matches an extracted face against the database
+func (fr *FaceRecognizer) resolveFace(face extractedFace) RecognizeResult {
+	if face.err != nil {
+		return RecognizeResult{
+			PersonID:         "error",
+			PersonName:       "Error",
+			BoundingBox:      face.Rect,
+			DetectionQuality: face.Quality,
+			Error:            face.err,
+		}
+	}
+
+	if face.occluded {
+		return RecognizeResult{
+			PersonID:         fr.unknownID,
+			PersonName:       fr.unknownName,
+			BoundingBox:      face.Rect,
+			DetectionQuality: face.Quality,
+			Occlusion:        face.occlusion,
+		}
+	}
+
+	// Match person
+	match := fr.bestMatch(face.feature)
+	confidence := match.confidence
+
+	result := RecognizeResult{
+		PersonID:         fr.unknownID,
+		PersonName:       fr.unknownName,
+		Confidence:       fr.scaleConfidence(confidence),
+		BoundingBox:      face.Rect,
+		DetectionQuality: face.Quality,
+		Occlusion:        face.occlusion,
+	}
+	if fr.isMatch(confidence) && fr.isAmbiguous(match) {
+		result.PersonID, result.PersonName = "ambiguous", "Ambiguous"
+	} else if fr.isMatch(confidence) {
+		result.PersonID, result.PersonName = match.personID, match.personName
+	}
+
+	return result
+}
+
+// sortByConfidence orders results for OrderConfidence. The sort is stable,
+// so equal results keep their detection order.
+func (fr *FaceRecognizer) sortByConfidence(results []RecognizeResult) {
+	rank := func(r RecognizeResult) int {
+		switch {
+		case r.Error != nil:
+			return 3
+		case r.PersonID == fr.unknownID:
+			return 2
+		case r.PersonID == "ambiguous":
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if ri, rj := rank(results[i]), rank(results[j]); ri != rj {
+			return ri < rj
+		}
+		return results[i].Confidence > results[j].Confidence
+	})
+}
+
+// RecognizeTopK returns, for each detected face, the k best matching persons
+// sorted by confidence (descending). Candidates are returned regardless of the
+// similarity threshold so near-misses can be inspected. Faces whose feature
+// extraction fails get a single result with Error set.
+func (fr *FaceRecognizer) RecognizeTopK(img gocv.Mat, k int) ([][]RecognizeResult, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be positive, got %d", k)
+	}
+
+	faces, err := fr.extractFaces(context.Background(), img)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]RecognizeResult, 0, len(faces))
+	for _, face := range faces {
+		if face.err != nil {
+			results = append(results, []RecognizeResult{{
+				PersonID:         "error",
+				PersonName:       "Error",
+				BoundingBox:      face.Rect,
+				DetectionQuality: face.Quality,
+				Error:            face.err,
+			}})
+			continue
+		}
+		if face.occluded {
+			results = append(results, []RecognizeResult{{
+				PersonID:         fr.unknownID,
+				PersonName:       fr.unknownName,
+				BoundingBox:      face.Rect,
+				DetectionQuality: face.Quality,
+				Occlusion:        face.occlusion,
+			}})
+			continue
+		}
+
+		candidates := fr.rankPersons(face.feature, k)
+		for i := range candidates {
+			candidates[i].Confidence = fr.scaleConfidence(candidates[i].Confidence)
+			candidates[i].BoundingBox = face.Rect
+			candidates[i].DetectionQuality = face.Quality
+			candidates[i].Occlusion = face.occlusion
+		}
+		results = append(results, candidates)
+	}
+
+	return results, nil
+}
+
+// extractedFace is a detected face together with its feature vector, or
+// the error that prevented extracting it. Occluded faces (see
+// WithMaxOcclusion) have no feature.
+type extractedFace struct {
+	Detection
+	feature   []float32
+	err       error
+	occlusion float64
+	occluded  bool
+}
+
+// extractFaces detects all faces in an image and extracts their features.
+// Faces whose feature extraction fails are kept with their error set.
+func (fr *FaceRecognizer) extractFaces(ctx context.Context, img gocv.Mat) ([]extractedFace, error) {
+	if err := fr.checkEncoder(); err != nil {
+		return nil, err
+	}
+
+	// Detect faces
+	detections, err := fr.detectMatWithScores(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert image: %v", err)
+	}
+	if err := fr.checkFaceCount(len(detections)); err != nil {
+		return nil, err
+	}
+
+	faces := make([]extractedFace, 0, len(detections))
+
+	for _, det := range detections {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		faces = append(faces, fr.extractFace(img, det))
+	}
+
+	return faces, nil
+}
+
+// extractFace extracts the feature of a single detected face, skipping
+// faces that are too occluded
+func (fr *FaceRecognizer) extractFace(img gocv.Mat, det Detection) extractedFace {
+	faceRegion := fr.faceRegion(img, det.Rect)
+	defer faceRegion.Close()
+
+	occlusion, occluded := fr.occluded(faceRegion)
+	if occluded {
+		return extractedFace{Detection: det, occlusion: occlusion, occluded: true}
+	}
+
+	feature, err := fr.extractFaceFeature(faceRegion)
+	if err != nil {
+		return extractedFace{Detection: det, occlusion: occlusion, err: fmt.Errorf("failed to extract feature: %v", err)}
+	}
+
+	return extractedFace{Detection: det, feature: feature, occlusion: occlusion}
+}
+
+// Verify compares the primary face (see WithPrimaryFace) in each image and reports whether both
+// belong to the same person, along with their similarity
+func (fr *FaceRecognizer) Verify(imgA, imgB gocv.Mat) (bool, float32, error) {
+	if err := fr.checkEncoder(); err != nil {
+		return false, 0, err
+	}
+
+	featureA, err := fr.extractPrimaryFace(imgA)
+	if err != nil {
+		return false, 0, fmt.Errorf("first image: %w", err)
+	}
+
+	featureB, err := fr.extractPrimaryFace(imgB)
+	if err != nil {
+		return false, 0, fmt.Errorf("second image: %w", err)
+	}
+
+	match, similarity := fr.VerifyFeatures(featureA, featureB)
+	return match, similarity, nil
+}
+
+// VerifyAgainst reports whether the primary face in img (see WithPrimaryFace)
+// belongs to the given person, e.g. to check a badge scan. Only that person's
+// samples are compared, using the configured match strategy, so the cost does
+// not grow with the size of the database.
+func (fr *FaceRecognizer) VerifyAgainst(personID string, img gocv.Mat) (bool, float32, error) {
+	fr.mu.RLock()
+	person, exists := fr.persons[personID]
+	fr.mu.RUnlock()
+
+	if !exists {
+		return false, 0, fmt.Errorf("%w: %s", ErrPersonNotFound, personID)
+	}
+
+	feature, err := fr.extractPrimaryFace(img)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return fr.verifyPerson(feature, person)
+}
+
+// verifyPerson compares a feature against a single person's samples
+func (fr *FaceRecognizer) verifyPerson(feature []float32, person *Person) (bool, float32, error) {
+	person.mu.RLock()
+	disabled := person.Disabled
+	similarity, ok := fr.scorePerson(feature, person)
+	person.mu.RUnlock()
+
+	if disabled {
+		return false, 0, fmt.Errorf("person %s is disabled", person.ID)
+	}
+	if !ok {
+		return false, 0, fmt.Errorf("person %s has no face samples", person.ID)
+	}
+
+	return fr.isMatch(similarity), similarity, nil
+}
+
+// VerifyFeatures compares two feature vectors against the similarity threshold
+func (fr *FaceRecognizer) VerifyFeatures(a, b []float32) (bool, float32) {
+	similarity := fr.similarity(a, b)
+	return fr.isMatch(similarity), similarity
+}
+
+// locatePrimaryFace detects faces in an image and returns the primary one
+func (fr *FaceRecognizer) locatePrimaryFace(img gocv.Mat) (Detection, error) {
+	dets, err := fr.detectMat(img)
+	if err != nil {
+		return Detection{}, fmt.Errorf("failed to convert image: %v", err)
+	}
+	if err := fr.checkFaceCount(len(dets)); err != nil {
+		return Detection{}, err
+	}
+
+	faces := make([]image.Rectangle, 0, len(dets))
+	for _, det := range dets {
+		faces = append(faces, det.rect)
+	}
+	primary, ok := fr.selectPrimaryFace(faces, image.Rect(0, 0, img.Cols(), img.Rows()))
+	if !ok {
+		return Detection{}, ErrNoFaceDetected
+	}
+
+	for _, det := range dets {
+		if det.rect == primary {
+			return Detection{Rect: primary, Quality: det.Q}, nil
+		}
+	}
+	return Detection{Rect: primary}, nil
+}
+
+// checkFaceCount enforces the face count bounds (see WithFaceCountBounds)
+func (fr *FaceRecognizer) checkFaceCount(n int) error {
+	if fr.minFaces > 0 && n < fr.minFaces {
+		return fmt.Errorf("%w: detected %d, minimum is %d", ErrTooFewFaces, n, fr.minFaces)
+	}
+	if fr.maxFaces > 0 && n > fr.maxFaces {
+		return fmt.Errorf("%w: detected %d, maximum is %d", ErrTooManyFaces, n, fr.maxFaces)
+	}
+	return nil
+}
+
+// faceRegion returns the crop of img used to extract the feature of the face
+// in rect (see WithCropMargin). The caller must close it.
+func (fr *FaceRecognizer) faceRegion(img gocv.Mat, rect image.Rectangle) gocv.Mat {
+	return img.Region(fr.cropRect(rect, image.Rect(0, 0, img.Cols(), img.Rows())))
+}
+
+// cropRect expands a face box by the crop margin, clamped to bounds
+func (fr *FaceRecognizer) cropRect(rect, bounds image.Rectangle) image.Rectangle {
+	if fr.cropMargin <= 0 {
+		return rect
+	}
+
+	dx := int(math.Round(float64(rect.Dx()) * fr.cropMargin))
+	dy := int(math.Round(float64(rect.Dy()) * fr.cropMargin))
+	return image.Rect(rect.Min.X-dx, rect.Min.Y-dy, rect.Max.X+dx, rect.Max.Y+dy).Intersect(bounds)
+}
+
+// extractPrimaryFace detects the primary face in an image and extracts its feature
+func (fr *FaceRecognizer) extractPrimaryFace(img gocv.Mat) ([]float32, error) {
+	if err := fr.checkEncoder(); err != nil {
+		return nil, err
+	}
+
+	primary, err := fr.locatePrimaryFace(img)
+	if err != nil {
+		return nil, err
+	}
+
+	faceRegion := fr.faceRegion(img, primary.Rect)
+	defer faceRegion.Close()
+
+	feature, err := fr.extractFaceFeature(faceRegion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract feature: %v", err)
+	}
+
+	return feature, nil
+}
+
+// minPersonsPerWorker keeps small databases from being split into shards
+// whose goroutine overhead outweighs the scan itself
+const minPersonsPerWorker = 64
+
+// personMatch is the best match found within a shard of persons
+type personMatch struct {
+	personID   string
+	personName string
+	confidence float32
+	runnerUp   float32 // Confidence of the second-best person
+}
+
+// betterThan reports whether m outranks other; ties are broken by person ID
+// so the result does not depend on map order or worker count
+func (m personMatch) betterThan(other personMatch) bool {
+	if m.confidence != other.confidence {
+		return m.confidence > other.confidence
+	}
+	return m.personID < other.personID
+}
+
+// matchPerson finds the best matching person for a feature vector
+func (fr *FaceRecognizer) matchPerson(feature []float32) (string, string, float32) {
+	best := fr.bestMatch(feature)
+	return best.personID, best.personName, best.confidence
+}
+
+// bestMatch finds the best matching person and the runner-up confidence,
+// counting the search in the recognizer stats
+func (fr *FaceRecognizer) bestMatch(feature []float32) personMatch {
+	start := time.Now()
+	match := fr.searchBestMatch(feature)
+	fr.stats.recordMatch(fr.isMatch(match.confidence), time.Since(start))
+	return match
+}
+
+// searchBestMatch implements bestMatch. With the ANN index enabled only the
+// candidate persons it returns are scored, so the runner-up is the best
+// among those candidates. Otherwise the packed gallery is scanned (see
+// galleryMatrix).
+func (fr *FaceRecognizer) searchBestMatch(feature []float32) personMatch {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	persons := fr.annCandidates(feature)
+	if persons == nil {
+		if gallery := fr.packedGallery(); gallery.fits(feature) {
+			return fr.matchSharded(gallery.persons(), func(lo, hi int) personMatch {
+				return fr.matchGallery(feature, gallery, lo, hi)
+			})
+		}
+
+		persons = make([]*Person, 0, len(fr.persons))
+		for _, person := range fr.persons {
+			persons = append(persons, person)
+		}
+	}
+
+	return fr.matchSharded(len(persons), func(lo, hi int) personMatch {
+		return fr.matchShard(feature, persons[lo:hi])
+	})
+}
+
+// matchSharded splits n persons into contiguous shards matched by match on
+// up to fr.workers goroutines and merges the results. The caller must hold
+// fr.mu until it returns.
+func (fr *FaceRecognizer) matchSharded(n int, match func(lo, hi int) personMatch) personMatch {
+	workers := min(fr.workers, (n+minPersonsPerWorker-1)/minPersonsPerWorker)
+	if workers <= 1 {
+		return match(0, n)
+	}
+
+	results := make([]personMatch, workers)
+	shardSize := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo := w * shardSize
+		hi := min(lo+shardSize, n)
+		if lo >= hi {
+			continue
+		}
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			results[w] = match(lo, hi)
+		}(w, lo, hi)
+	}
+	wg.Wait()
+
+	var best personMatch
+	var runnerUp float32
+	for _, result := range results {
+		if result.confidence > 0 && result.betterThan(best) {
+			runnerUp = max(runnerUp, best.confidence)
+			best = result
+		} else {
+			runnerUp = max(runnerUp, result.confidence)
+		}
+		runnerUp = max(runnerUp, result.runnerUp)
+	}
+	best.runnerUp = runnerUp
+
+	return best
+}
+
+// annCandidates returns the persons the ANN index selects for feature, or
+// nil when the index is disabled or cannot narrow the search. The caller must
+// hold fr.mu.
+func (fr *FaceRecognizer) annCandidates(feature []float32) []*Person {
+	if fr.ann == nil {
+		return nil
+	}
+	fr.syncANN()
+
+	ids, ok := fr.ann.candidates(feature)
+	if !ok {
+		return nil
+	}
+
+	persons := make([]*Person, 0, len(ids))
+	for _, id := range ids {
+		if person, exists := fr.persons[id]; exists {
+			persons = append(persons, person)
+		}
+	}
+	if len(persons) == 0 {
+		return nil
+	}
+
+	return persons
+}
+
+// syncANN builds the ANN index or refreshes the persons whose samples
+// changed. The caller must hold fr.mu.
+func (fr *FaceRecognizer) syncANN() {
+	fr.ann.syncMu.Lock()
+	defer fr.ann.syncMu.Unlock()
+
+	// Persons marked from here on are refreshed by the next sync
+	dirty := fr.ann.takeDirty()
+
+	if fr.ann.needsBuild() {
+		ids := make([]string, 0, len(fr.persons))
+		for id := range fr.persons {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		var entries []annEntry
+		for _, id := range ids {
+			entries = append(entries, fr.annEntries(fr.persons[id])...)
+		}
+		fr.ann.build(entries)
+		return
+	}
+
+	for _, id := range dirty {
+		var entries []annEntry
+		if person, exists := fr.persons[id]; exists {
+			entries = fr.annEntries(person)
+		}
+		fr.ann.replace(id, entries)
+	}
+}
+
+// annEntries returns the vectors indexed for a person: its centroid under
+// StrategyCentroid, otherwise every sample
+func (fr *FaceRecognizer) annEntries(person *Person) []annEntry {
+	person.mu.RLock()
+	defer person.mu.RUnlock()
+
+	if len(person.Features) == 0 {
+		return nil
+	}
+
+	vectors := make([][]float32, 0, len(person.Features))
+	if fr.strategy == StrategyCentroid {
+		vectors = append(vectors, fr.centroid(person))
+	} else {
+		for _, sample := range person.Features {
+			vectors = append(vectors, sample.Feature)
+		}
+	}
+
+	entries := make([]annEntry, 0, len(vectors))
+	for _, v := range vectors {
+		if normalized := normalizeFeature(v); dotProduct(normalized, normalized) > 0 {
+			entries = append(entries, annEntry{personID: person.ID, vector: normalized})
+		}
+	}
+
+	return entries
+}
+
+// matchShard returns the best match among persons. The caller must hold fr.mu.
+func (fr *FaceRecognizer) matchShard(feature []float32, persons []*Person) personMatch {
+	var best personMatch
+	var runnerUp float32
+
+	for _, person := range persons {
+		person.mu.RLock()
+		if similarity, ok := fr.scorePerson(feature, person); ok && similarity > 0 {
+			candidate := personMatch{
+				personID:   person.ID,
+				personName: person.Name,
+				confidence: similarity,
+			}
+			if candidate.betterThan(best) {
+				runnerUp = max(runnerUp, best.confidence)
+				best = candidate
+			} else {
+				runnerUp = max(runnerUp, similarity)
+			}
+		}
+		person.mu.RUnlock()
+	}
+	best.runnerUp = runnerUp
+
+	return best
+}
+
+// rankPersons scores every person against a feature and returns the top k,
+// sorted by confidence descending with ties broken by person ID
+func (fr *FaceRecognizer) rankPersons(feature []float32, k int) []RecognizeResult {
+	fr.mu.RLock()
+	candidates := make([]RecognizeResult, 0, len(fr.persons))
+	for _, person := range fr.persons {
+		person.mu.RLock()
+		if similarity, ok := fr.scorePerson(feature, person); ok {
+			candidates = append(candidates, RecognizeResult{
+				PersonID:   person.ID,
+				PersonName: person.Name,
+				Confidence: similarity,
+			})
+		}
+		person.mu.RUnlock()
+	}
+	fr.mu.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Confidence != candidates[j].Confidence {
+			return candidates[i].Confidence > candidates[j].Confidence
+		}
+		return candidates[i].PersonID < candidates[j].PersonID
+	})
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	return candidates
+}
+
+// scorePerson returns how well a feature matches a person under the match
+// strategy; ok is false when the person has no samples or is disabled. The
+// caller must hold person.mu.
+func (fr *FaceRecognizer) scorePerson(feature []float32, person *Person) (float32, bool) {
+	if person.Disabled || len(person.Features) == 0 {
+		return 0, false
+	}
+
+	if fr.strategy == StrategyCentroid {
+		return fr.normalizedSimilarity(feature, fr.centroid(person)), true
+	}
+
+	best := fr.normalizedSimilarity(feature, person.Features[0].Feature)
+	for _, sample := range person.Features[1:] {
+		if similarity := fr.normalizedSimilarity(feature, sample.Feature); similarity > best {
+			best = similarity
+		}
+	}
+	return best, true
+}
+
+// centroid returns the cached centroid embedding of a person, computing it
+// on first use. The caller must hold person.mu.
+func (fr *FaceRecognizer) centroid(person *Person) []float32 {
+	fr.centroidMu.Lock()
+	defer fr.centroidMu.Unlock()
+
+	if centroid, ok := fr.centroids[person.ID]; ok {
+		return centroid
+	}
+
+	if fr.centroids == nil {
+		fr.centroids = make(map[string][]float32)
+	}
+	centroid := fr.normalization.normalize(meanFeature(person.Features))
+	fr.centroids[person.ID] = centroid
+	return centroid
+}
+
+// invalidateMatchCache drops the cached centroid, packed gallery and ANN
+// entries of a person whose samples changed or who was removed
+func (fr *FaceRecognizer) invalidateMatchCache(personID string) {
+	fr.centroidMu.Lock()
+	delete(fr.centroids, personID)
+	fr.centroidMu.Unlock()
+
+	fr.gallery.invalidate()
+
+	if fr.ann != nil {
+		fr.ann.markDirty(personID)
+	}
+}
+
+// resetMatchCache drops all cached centroids, the packed gallery and the
+// ANN index
+func (fr *FaceRecognizer) resetMatchCache() {
+	fr.centroidMu.Lock()
+	fr.centroids = make(map[string][]float32)
+	fr.centroidMu.Unlock()
+
+	fr.gallery.invalidate()
+
+	if fr.ann != nil {
+		fr.ann.reset()
+	}
+}
+
+// similarity compares two feature vectors using the configured metric.
+// The result is always "higher is better".
+func (fr *FaceRecognizer) similarity(a, b []float32) float32 {
+	if fr.metric == MetricEuclidean {
+		return distanceToConfidence(euclideanDistance(a, b))
+	}
+	return cosineSimilarity(a, b)
+}
+
+// normalizedSimilarity is similarity for features normalized like the
+// model's output. With NormL2 (the default) extracted features, centroids
+// and stored samples (normalized on load) all have unit length, so the
+// cosine similarity reduces to a dot product. Matching uses it.
+func (fr *FaceRecognizer) normalizedSimilarity(a, b []float32) float32 {
+	if fr.metric == MetricEuclidean {
+		return distanceToConfidence(euclideanDistance(a, b))
+	}
+	if fr.normalization != NormL2 {
+		return cosineSimilarity(a, b)
+	}
+	return dotProduct(a, b)
+}
+
+// scaleConfidence converts a similarity returned by fr.similarity to the
+// reported confidence scale (see WithConfidenceScaling)
+func (fr *FaceRecognizer) scaleConfidence(similarity float32) float32 {
+	if fr.confidence == ScaleRaw {
+		return similarity
+	}
+
+	scaled := similarity
+	if fr.metric != MetricEuclidean {
+		scaled = (similarity + 1) / 2
+	}
+	// Rounding can push a dot product of unit vectors slightly past 1
+	scaled = max(0, min(scaled, 1))
+
+	if fr.confidence == ScalePercent {
+		return scaled * 100
+	}
+	return scaled
+}
+
+// isMatch reports whether a similarity returned by fr.similarity passes the threshold
+func (fr *FaceRecognizer) isMatch(similarity float32) bool {
+	threshold := fr.GetThreshold()
+	if fr.metric == MetricEuclidean {
+		// Threshold is a maximum distance; compare in confidence space
+		return similarity >= distanceToConfidence(threshold)
+	}
+	return similarity >= threshold
+}
+
+// isAmbiguous reports whether the runner-up is too close to the best match
+// to name the best one confidently
+func (fr *FaceRecognizer) isAmbiguous(match personMatch) bool {
+	return fr.margin > 0 && match.confidence-match.runnerUp < fr.margin
+}
+
+// GetPerson retrieves a person by ID
+func (fr *FaceRecognizer) GetPerson(id string) (*Person, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	person, exists := fr.persons[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+	}
+
+	return person, nil
+}
+
+// HasPerson reports whether a person is registered, without the error
+// allocation of a failed GetPerson
+func (fr *FaceRecognizer) HasPerson(id string) bool {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	_, exists := fr.persons[id]
+	return exists
+}
+
+// PersonCount returns the number of registered persons
+func (fr *FaceRecognizer) PersonCount() int {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	return len(fr.persons)
+}
+
+// ListPersons returns all registered persons
+func (fr *FaceRecognizer) ListPersons() []*Person {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	persons := make([]*Person, 0, len(fr.persons))
+	for _, person := range fr.persons {
+		persons = append(persons, person)
+	}
+
+	return persons
+}
+
+// UpdatePerson changes the name of an existing person, keeping their samples
+func (fr *FaceRecognizer) UpdatePerson(id, name string) error {
+	fr.mu.RLock()
+	person, exists := fr.persons[id]
+	fr.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+	}
+
+	person.mu.Lock()
+	previous := person.Name
+	person.Name = name
+	person.mu.Unlock()
+	fr.gallery.invalidate()
+
+	if err := fr.persistPerson(person); err != nil {
+		person.mu.Lock()
+		person.Name = previous
+		person.mu.Unlock()
+		fr.gallery.invalidate()
+		return fmt.Errorf("failed to save person to storage: %v", err)
+	}
+
+	return nil
+}
+
+// SetPersonMetadata replaces the metadata of an existing person (nil clears it)
+func (fr *FaceRecognizer) SetPersonMetadata(id string, metadata map[string]string) error {
+	fr.mu.RLock()
+	person, exists := fr.persons[id]
+	fr.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+	}
+
+	var updated map[string]string
+	if len(metadata) > 0 {
+		updated = maps.Clone(metadata)
+	}
+
+	person.mu.Lock()
+	previous := person.Metadata
+	person.Metadata = updated
+	person.mu.Unlock()
+
+	if err := fr.persistPerson(person); err != nil {
+		person.mu.Lock()
+		person.Metadata = previous
+		person.mu.Unlock()
+		return fmt.Errorf("failed to save person to storage: %v", err)
+	}
+
+	return nil
+}
+
+// SetPersonEnabled includes or excludes an existing person from matching.
+// Disabled persons keep their samples and are still returned by ListPersons
+// and GetPerson, with Disabled set.
+func (fr *FaceRecognizer) SetPersonEnabled(id string, enabled bool) error {
+	fr.mu.RLock()
+	person, exists := fr.persons[id]
+	fr.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+	}
+
+	person.mu.Lock()
+	previous := person.Disabled
+	person.Disabled = !enabled
+	person.mu.Unlock()
+	fr.gallery.invalidate()
+
+	if err := fr.persistPerson(person); err != nil {
+		person.mu.Lock()
+		person.Disabled = previous
+		person.mu.Unlock()
+		fr.gallery.invalidate()
+		return fmt.Errorf("failed to save person to storage: %v", err)
+	}
+
+	return nil
+}
+
+// RemovePerson removes a person from the database. Like every change, the
+// removal reaches the storage backend right away with auto-persist, and
+// with the next SaveDirty or SyncToStorage otherwise.
+func (fr *FaceRecognizer) RemovePerson(id string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	person, exists := fr.persons[id]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+	}
+
+	delete(fr.persons, id)
+	fr.invalidateMatchCache(id)
+
+	// Delete from storage
+	if err := fr.unpersistPerson(id); err != nil {
+		// Rollback in-memory change if storage fails
+		fr.persons[id] = person
+		return fmt.Errorf("failed to delete person from storage: %v", err)
+	}
+
+	return nil
+}
+
+// MergePersons moves the samples of mergeID onto keepID, e.g. when one
+// individual was enrolled under two IDs, and removes mergeID. keepID keeps
+// its name, metadata and enabled state; the combined samples are trimmed to
+// the WithMaxSamplesPerPerson limit.
+func (fr *FaceRecognizer) MergePersons(keepID, mergeID string) error {
+	if keepID == mergeID {
+		return fmt.Errorf("cannot merge person %s into itself", keepID)
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	keep, exists := fr.persons[keepID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, keepID)
+	}
+	merge, exists := fr.persons[mergeID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, mergeID)
+	}
+
+	merge.mu.RLock()
+	samples := make([]FaceFeature, len(merge.Features))
+	copy(samples, merge.Features)
+	merge.mu.RUnlock()
+
+	keep.mu.Lock()
+	previous := keep.Features
+	features := make([]FaceFeature, 0, len(previous)+len(samples))
+	features = append(features, previous...)
+
+	// Validate against keepID's samples, or the first merged one
+	var dim int
+	if len(features) > 0 {
+		dim = len(features[0].Feature)
+	} else if len(samples) > 0 {
+		dim = len(samples[0].Feature)
+	}
+	for i, sample := range samples {
+		if len(sample.Feature) != dim {
+			keep.mu.Unlock()
+			return fmt.Errorf("person %s sample %d has dimension %d, expected %d", mergeID, i, len(sample.Feature), dim)
+		}
+		sample.PersonID = keepID
+		features = append(features, sample)
+	}
+
+	keep.Features = fr.retainSamples(features)
+	keep.mu.Unlock()
+
+	if err := fr.replaceFeatures(keep, previous); err != nil {
+		return err
+	}
+
+	delete(fr.persons, mergeID)
+	fr.invalidateMatchCache(mergeID)
+
+	if err := fr.unpersistPerson(mergeID); err != nil {
+		// Undo both changes; keepID was already saved with the merged samples
+		fr.persons[mergeID] = merge
+		keep.mu.Lock()
+		keep.Features = previous
+		keep.mu.Unlock()
+		fr.invalidateMatchCache(keepID)
+		fr.persistPerson(keep)
+		return fmt.Errorf("failed to delete person from storage: %v", err)
+	}
+
+	return nil
+}
+
+// ClearAll removes every person. With auto-persist the storage backend is
+// cleared first; if that fails the in-memory database is left untouched.
+func (fr *FaceRecognizer) ClearAll() error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.autoPersist {
+		if err := fr.storage.Clear(); err != nil {
+			return fmt.Errorf("failed to clear storage: %v", err)
+		}
+	} else {
+		for id := range fr.persons {
+			fr.markDirty(id)
+		}
+	}
+
+	fr.persons = make(map[string]*Person)
+	fr.resetMatchCache()
+
+	return nil
+}
+
+// persistPerson writes a person through to storage when auto-persist is
+// enabled, or marks them for SaveDirty otherwise
+func (fr *FaceRecognizer) persistPerson(person *Person) error {
+	if !fr.autoPersist {
+		fr.markDirty(person.ID)
+		return nil
+	}
+
+	return fr.writePerson(person)
+}
+
+// writePerson saves a person to storage
+func (fr *FaceRecognizer) writePerson(person *Person) error {
+	person.mu.RLock()
+	defer person.mu.RUnlock()
+
+	if fr.storeQuantized {
+		return fr.storage.SavePerson(quantizedPerson(person))
+	}
+	return fr.storage.SavePerson(person)
+}
+
+// unpersistPerson deletes a person from storage when auto-persist is
+// enabled, or marks them for SaveDirty otherwise
+func (fr *FaceRecognizer) unpersistPerson(id string) error {
+	if !fr.autoPersist {
+		fr.markDirty(id)
+		return nil
+	}
+
+	return fr.erasePerson(id)
+}
+
+// erasePerson deletes a person from storage. Persons that were never written
+// to storage are ignored.
+func (fr *FaceRecognizer) erasePerson(id string) error {
+	exists, err := fr.storage.PersonExists(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	return fr.storage.DeletePerson(id)
+}
+
+// markDirty records that a person changed since the last SaveDirty
+func (fr *FaceRecognizer) markDirty(id string) {
+	fr.dirtyMu.Lock()
+	defer fr.dirtyMu.Unlock()
+
+	if fr.dirty == nil {
+		fr.dirty = make(map[string]struct{})
+	}
+	fr.dirty[id] = struct{}{}
+}
+
+// SaveDirty writes the persons changed since the last SaveDirty to the
+// storage backend and deletes the removed ones, e.g. to save a large gallery
+// periodically with WithAutoPersist(false). With FileStorage only the changed
+// <id>.json files are rewritten. Persons that fail to save stay pending.
+func (fr *FaceRecognizer) SaveDirty() error {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	// Take the pending set up front so changes made while saving are
+	// recorded for the next call
+	fr.dirtyMu.Lock()
+	pending := fr.dirty
+	fr.dirty = nil
+	fr.dirtyMu.Unlock()
+
+	for id := range pending {
+		var err error
+		if person, exists := fr.persons[id]; exists {
+			err = fr.writePerson(person)
+		} else {
+			err = fr.erasePerson(id)
+		}
+
+		if err != nil {
+			for unsaved := range pending {
+				fr.markDirty(unsaved)
+			}
+			return fmt.Errorf("failed to save person %s to storage: %v", id, err)
+		}
+		delete(pending, id)
+	}
+
+	return nil
+}
+
+// SyncToStorage makes the storage backend mirror the in-memory database:
+// every person is written and stored persons missing from memory are
+// deleted. This also settles the changes pending for SaveDirty. Use it after
+// LoadDatabase, which only replaces the in-memory database, or to repair a
+// backend that was changed behind the recognizer's back. If a write fails,
+// the pending changes are kept for the next SaveDirty.
+func (fr *FaceRecognizer) SyncToStorage() error {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	// Take the pending set up front so changes made while syncing are
+	// recorded for the next call
+	fr.dirtyMu.Lock()
+	pending := fr.dirty
+	fr.dirty = nil
+	fr.dirtyMu.Unlock()
+
+	restore := func() {
+		for id := range pending {
+			fr.markDirty(id)
+		}
+	}
+
+	var stale []string
+	err := fr.storage.IterPersons(func(person *Person) error {
+		if _, exists := fr.persons[person.ID]; !exists {
+			stale = append(stale, person.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		restore()
+		return fmt.Errorf("failed to list stored persons: %v", err)
+	}
+
+	for _, id := range stale {
+		if err := fr.storage.DeletePerson(id); err != nil {
+			restore()
+			return fmt.Errorf("failed to delete person %s from storage: %v", id, err)
+		}
+	}
+
+	ids := make([]string, 0, len(fr.persons))
+	for id := range fr.persons {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := fr.writePerson(fr.persons[id]); err != nil {
+			restore()
+			return fmt.Errorf("failed to save person %s to storage: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// SyncFromStorage replaces the in-memory database with the persons in the
+// storage backend, e.g. to pick up changes another process made to a shared
+// Redis or SQLite backend. In-memory changes not yet written (see SaveDirty)
+// are discarded.
+func (fr *FaceRecognizer) SyncFromStorage() error {
+	persons, err := fr.storedPersons()
+	if err != nil {
+		return fmt.Errorf("failed to load persons from storage: %v", err)
+	}
 
-	// Save to storage
-	if err := fr.storage.SavePerson(person); err != nil {
-		// Rollback in-memory change if storage fails
-		delete(fr.persons, id)
-		return fmt.Errorf("failed to save person to storage: %v", err)
+	loaded := make(map[string]*Person, len(persons))
+	for _, person := range persons {
+		loaded[person.ID] = person
 	}
 
+	fr.mu.Lock()
+	fr.persons = loaded
+	fr.dirtyMu.Lock()
+	fr.dirty = nil
+	fr.dirtyMu.Unlock()
+	fr.mu.Unlock()
+	fr.resetMatchCache()
+
 	return nil
 }
 
-// AddFaceSample adds a face sample for a specific person
-func (fr *FaceRecognizer) AddFaceSample(personID string, img gocv.Mat) error {
+// SaveDatabase saves the face database to a JSON file
+func (fr *FaceRecognizer) SaveDatabase(filepath string) error {
 	fr.mu.RLock()
-	person, exists := fr.persons[personID]
-	fr.mu.RUnlock()
+	defer fr.mu.RUnlock()
 
-	if !exists {
-		return fmt.Errorf("person ID %s does not exist", personID)
+	persons := fr.persons
+	if fr.storeQuantized {
+		persons = make(map[string]*Person, len(fr.persons))
+		for id, person := range fr.persons {
+			person.mu.RLock()
+			persons[id] = quantizedPerson(person)
+			person.mu.RUnlock()
+		}
 	}
 
-	// Detect faces
-	goImg, err := img.ToImage()
+	data, err := json.MarshalIndent(persons, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to convert image: %v", err)
-	}
-
-	faces := fr.DetectFaces(goImg)
-	if len(faces) == 0 {
-		return errors.New("no face detected in image")
+		return fmt.Errorf("failed to marshal database: %v", err)
 	}
 
-	// Use the first detected face
-	faceRegion := img.Region(faces[0])
-	defer faceRegion.Close()
+	return writeFileAtomic(filepath, data, 0644)
+}
 
-	// Extract feature
-	feature, err := fr.ExtractFeature(faceRegion)
+// LoadDatabase loads the face database from a JSON file
+func (fr *FaceRecognizer) LoadDatabase(filepath string) error {
+	data, err := ioutil.ReadFile(filepath)
 	if err != nil {
-		return fmt.Errorf("failed to extract feature: %v", err)
+		return fmt.Errorf("failed to read database file: %v", err)
 	}
 
-	// Add feature to person
-	person.mu.Lock()
-	person.Features = append(person.Features, FaceFeature{
-		PersonID: personID,
-		Feature:  feature,
-	})
-	person.mu.Unlock()
+	persons := make(map[string]*Person)
+	if err := json.Unmarshal(data, &persons); err != nil {
+		return fmt.Errorf("failed to unmarshal database: %v", err)
+	}
 
-	// Save updated person to storage
-	if err := fr.storage.SavePerson(person); err != nil {
-		// Rollback in-memory change if storage fails
-		person.mu.Lock()
-		person.Features = person.Features[:len(person.Features)-1]
-		person.mu.Unlock()
-		return fmt.Errorf("failed to save person to storage: %v", err)
+	for _, person := range persons {
+		if err := fr.checkDimensions(person); err != nil {
+			return err
+		}
+		fr.normalizeSamples(person)
 	}
 
+	fr.mu.Lock()
+	fr.persons = persons
+	fr.mu.Unlock()
+	fr.resetMatchCache()
+
 	return nil
 }
 
-// Recognize recognizes faces in an image
-func (fr *FaceRecognizer) Recognize(img gocv.Mat) ([]RecognizeResult, error) {
-	// Detect faces
-	goImg, err := img.ToImage()
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert image: %v", err)
-	}
-
-	faces := fr.DetectFaces(goImg)
-	if len(faces) == 0 {
-		return []RecognizeResult{}, nil
+// checkDimensions reports a loaded person whose samples do not match the
+// model's feature dimension (see WithStrictDimensionCheck)
+func (fr *FaceRecognizer) checkDimensions(person *Person) error {
+	model := fr.GetModelConfig()
+	dim := model.FeatureDim
+	if !fr.strictDims || dim <= 0 {
+		return nil
 	}
 
-	results := make([]RecognizeResult, 0, len(faces))
-
-	// Recognize each detected face
-	for _, faceRect := range faces {
-		faceRegion := img.Region(faceRect)
-		feature, err := fr.ExtractFeature(faceRegion)
-		faceRegion.Close()
-
-		if err != nil {
-			continue
+	for i, feature := range person.Features {
+		if len(feature.Feature) != dim {
+			return fmt.Errorf("person %s sample %d has dimension %d, expected %d for model %s",
+				person.ID, i, len(feature.Feature), dim, model.Type)
 		}
+	}
 
-		// Match person
-		personID, personName, confidence := fr.matchPerson(feature)
+	return nil
+}
 
-		if confidence >= fr.threshold {
-			results = append(results, RecognizeResult{
-				PersonID:    personID,
-				PersonName:  personName,
-				Confidence:  confidence,
-				BoundingBox: faceRect,
-			})
-		} else {
-			results = append(results, RecognizeResult{
-				PersonID:    "unknown",
-				PersonName:  "Unknown",
-				Confidence:  confidence,
-				BoundingBox: faceRect,
-			})
-		}
+// normalizeSamples normalizes the samples of a loaded person like the
+// model's output (L2 by default), as they may come from an older version,
+// another tool or lossy (quantized) storage, so that matching can compare
+// them with a plain dot product
+func (fr *FaceRecognizer) normalizeSamples(person *Person) {
+	for i := range person.Features {
+		person.Features[i].Feature = fr.normalization.normalize(person.Features[i].Feature)
 	}
-
-	return results, nil
 }
 
-// matchPerson finds the best matching person for a feature vector
-func (fr *FaceRecognizer) matchPerson(feature []float32) (string, string, float32) {
-	fr.mu.RLock()
-	defer fr.mu.RUnlock()
+// databaseArchiveVersion is the schema version written by ExportDatabase
+const databaseArchiveVersion = 1
+
+// DatabaseArchive is the portable envelope written by ExportDatabase
+type DatabaseArchive struct {
+	Version    int       `json:"version"`
+	ModelType  ModelType `json:"model_type"`
+	FeatureDim int       `json:"feature_dim"`
+	ExportedAt time.Time `json:"exported_at"`
+	Persons    []*Person `json:"persons"`
+}
 
-	var bestPersonID, bestPersonName string
-	var bestConfidence float32 = 0
+// ExportDatabase writes all persons to w as a versioned JSON archive, e.g.
+// for backups or to move a database to another storage backend
+func (fr *FaceRecognizer) ExportDatabase(w io.Writer) error {
+	model := fr.GetModelConfig()
 
+	fr.mu.RLock()
+	archive := DatabaseArchive{
+		Version:    databaseArchiveVersion,
+		ModelType:  model.Type,
+		FeatureDim: model.FeatureDim,
+		ExportedAt: time.Now().UTC(),
+		Persons:    make([]*Person, 0, len(fr.persons)),
+	}
 	for _, person := range fr.persons {
 		person.mu.RLock()
-		for _, sample := range person.Features {
-			similarity := cosineSimilarity(feature, sample.Feature)
-			if similarity > bestConfidence {
-				bestConfidence = similarity
-				bestPersonID = person.ID
-				bestPersonName = person.Name
-			}
+		if fr.storeQuantized {
+			archive.Persons = append(archive.Persons, quantizedPerson(person))
+		} else {
+			archive.Persons = append(archive.Persons, &Person{
+				ID:       person.ID,
+				Name:     person.Name,
+				Features: append([]FaceFeature(nil), person.Features...),
+				Metadata: maps.Clone(person.Metadata),
+				Disabled: person.Disabled,
+			})
 		}
 		person.mu.RUnlock()
 	}
+	fr.mu.RUnlock()
 
-	return bestPersonID, bestPersonName, bestConfidence
-}
-
-// GetPerson retrieves a person by ID
-func (fr *FaceRecognizer) GetPerson(id string) (*Person, error) {
-	fr.mu.RLock()
-	defer fr.mu.RUnlock()
+	sort.Slice(archive.Persons, func(i, j int) bool {
+		return archive.Persons[i].ID < archive.Persons[j].ID
+	})
 
-	person, exists := fr.persons[id]
-	if !exists {
-		return nil, fmt.Errorf("person ID %s does not exist", id)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(archive); err != nil {
+		return fmt.Errorf("failed to write archive: %v", err)
 	}
 
-	return person, nil
+	return nil
 }
 
-// ListPersons returns all registered persons
-func (fr *FaceRecognizer) ListPersons() []*Person {
+// ExportPersonsCSV writes a report of the enrolled persons to w, one row per
+// person sorted by ID, with the columns id, name, sample_count and
+// feature_dim (0 for persons without samples). It contains no embeddings.
+func (fr *FaceRecognizer) ExportPersonsCSV(w io.Writer) error {
 	fr.mu.RLock()
-	defer fr.mu.RUnlock()
-
 	persons := make([]*Person, 0, len(fr.persons))
 	for _, person := range fr.persons {
 		persons = append(persons, person)
 	}
+	fr.mu.RUnlock()
 
-	return persons
-}
+	sort.Slice(persons, func(i, j int) bool { return persons[i].ID < persons[j].ID })
 
-// RemovePerson removes a person from the database
-func (fr *FaceRecognizer) RemovePerson(id string) error {
-	fr.mu.Lock()
-	defer fr.mu.Unlock()
+	// Rows are written as each person is read, without copying their samples
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "name", "sample_count", "feature_dim"}); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	for _, person := range persons {
+		person.mu.RLock()
+		dim := 0
+		if len(person.Features) > 0 {
+			dim = len(person.Features[0].Feature)
+		}
+		row := []string{person.ID, person.Name, strconv.Itoa(len(person.Features)), strconv.Itoa(dim)}
+		person.mu.RUnlock()
 
-	if _, exists := fr.persons[id]; !exists {
-		return fmt.Errorf("person ID %s does not exist", id)
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV: %v", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
 	}
 
-	delete(fr.persons, id)
 	return nil
 }
 
-// SaveDatabase saves the face database to a JSON file
-func (fr *FaceRecognizer) SaveDatabase(filepath string) error {
-	fr.mu.RLock()
-	defer fr.mu.RUnlock()
+// ImportDatabase replaces all persons with those of an archive written by
+// ExportDatabase. Archives from another model type or feature dimension are
+// rejected, the former with ErrIncompatibleModel. With auto-persist, imported
+// persons are written to storage and persons missing from the archive are
+// deleted from it. If a storage write fails, the changes already made to
+// storage are rolled back and the in-memory database is left untouched;
+// should the rollback itself fail, the backend can hold a mix of both.
+func (fr *FaceRecognizer) ImportDatabase(r io.Reader) error {
+	var archive DatabaseArchive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return fmt.Errorf("failed to read archive: %v", err)
+	}
 
-	data, err := json.MarshalIndent(fr.persons, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal database: %v", err)
+	if archive.Version < 1 || archive.Version > databaseArchiveVersion {
+		return fmt.Errorf("unsupported archive version %d (supported: 1-%d)", archive.Version, databaseArchiveVersion)
 	}
 
-	return ioutil.WriteFile(filepath, data, 0644)
-}
+	model := fr.GetModelConfig()
+	if archive.FeatureDim != model.FeatureDim {
+		return fmt.Errorf("archive feature dimension %d does not match model %s (%d-dim)",
+			archive.FeatureDim, model.Type, model.FeatureDim)
+	}
 
-// LoadDatabase loads the face database from a JSON file
-func (fr *FaceRecognizer) LoadDatabase(filepath string) error {
-	data, err := ioutil.ReadFile(filepath)
-	if err != nil {
-		return fmt.Errorf("failed to read database file: %v", err)
+	persons := make(map[string]*Person, len(archive.Persons))
+	for _, person := range archive.Persons {
+		if person == nil || person.ID == "" {
+			return errors.New("archive contains a person without an ID")
+		}
+		if _, exists := persons[person.ID]; exists {
+			return fmt.Errorf("archive contains duplicate person ID %s", person.ID)
+		}
+		for i, feature := range person.Features {
+			if len(feature.Feature) != archive.FeatureDim {
+				return fmt.Errorf("person %s sample %d has dimension %d, expected %d",
+					person.ID, i, len(feature.Feature), archive.FeatureDim)
+			}
+		}
+		if person.Features == nil {
+			person.Features = make([]FaceFeature, 0)
+		}
+		fr.normalizeSamples(person)
+		persons[person.ID] = person
 	}
 
-	persons := make(map[string]*Person)
-	if err := json.Unmarshal(data, &persons); err != nil {
-		return fmt.Errorf("failed to unmarshal database: %v", err)
+	if archive.ModelType != model.Type {
+		return fmt.Errorf("%w: archive was exported with model %s, current model is %s", ErrIncompatibleModel, archive.ModelType, model.Type)
 	}
 
 	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	// rollback restores the storage state of the persons changed so far
+	var written, deleted []string
+	rollback := func() {
+		for _, id := range written {
+			if previous, exists := fr.persons[id]; exists {
+				fr.persistPerson(previous)
+			} else {
+				fr.unpersistPerson(id)
+			}
+		}
+		for _, id := range deleted {
+			fr.persistPerson(fr.persons[id])
+		}
+	}
+
+	for _, person := range archive.Persons {
+		if err := fr.persistPerson(person); err != nil {
+			rollback()
+			return fmt.Errorf("failed to save person %s to storage: %v", person.ID, err)
+		}
+		written = append(written, person.ID)
+	}
+	for id := range fr.persons {
+		if _, kept := persons[id]; kept {
+			continue
+		}
+		if err := fr.unpersistPerson(id); err != nil {
+			rollback()
+			return fmt.Errorf("failed to delete person %s from storage: %v", id, err)
+		}
+		deleted = append(deleted, id)
+	}
+
 	fr.persons = persons
-	fr.mu.Unlock()
+	fr.resetMatchCache()
 
 	return nil
 }
 
 // SetThreshold sets the similarity threshold
 func (fr *FaceRecognizer) SetThreshold(threshold float32) {
+	fr.mu.Lock()
 	fr.threshold = threshold
+	fr.mu.Unlock()
 }
 
 // GetThreshold returns the current similarity threshold
 func (fr *FaceRecognizer) GetThreshold() float32 {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
 	return fr.threshold
 }
 
+// GetDistanceMetric returns the metric used to compare feature vectors
+func (fr *FaceRecognizer) GetDistanceMetric() DistanceMetric {
+	return fr.metric
+}
+
+// GetBackend returns the DNN backend and target used by the face encoder
+func (fr *FaceRecognizer) GetBackend() (gocv.NetBackendType, gocv.NetTargetType) {
+	fr.modelMu.RLock()
+	defer fr.modelMu.RUnlock()
+
+	return fr.backend, fr.target
+}
+
 // GetModelConfig returns the current model configuration
 func (fr *FaceRecognizer) GetModelConfig() ModelConfig {
+	fr.modelMu.RLock()
+	defer fr.modelMu.RUnlock()
+
 	return fr.modelConfig
 }
 
@@ -608,7 +3858,7 @@ func (fr *FaceRecognizer) GetSampleCount(personID string) (int, error) {
 	fr.mu.RUnlock()
 
 	if !exists {
-		return 0, fmt.Errorf("person ID %s does not exist", personID)
+		return 0, fmt.Errorf("%w: %s", ErrPersonNotFound, personID)
 	}
 
 	person.mu.RLock()
@@ -618,6 +3868,28 @@ func (fr *FaceRecognizer) GetSampleCount(personID string) (int, error) {
 	return count, nil
 }
 
+// GetPersonFeatures returns a copy of a person's feature vectors in
+// enrollment order, safe to use while samples are being added
+func (fr *FaceRecognizer) GetPersonFeatures(personID string) ([][]float32, error) {
+	fr.mu.RLock()
+	person, exists := fr.persons[personID]
+	fr.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrPersonNotFound, personID)
+	}
+
+	person.mu.RLock()
+	defer person.mu.RUnlock()
+
+	features := make([][]float32, len(person.Features))
+	for i, sample := range person.Features {
+		features[i] = append([]float32(nil), sample.Feature...)
+	}
+
+	return features, nil
+}
+
 // Utility functions
 
 // cosineSimilarity calculates the cosine similarity between two vectors
@@ -640,6 +3912,144 @@ func cosineSimilarity(a, b []float32) float32 {
 	return dotProduct / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
 }
 
+// dotProduct returns the dot product of two vectors, or 0 if their lengths
+// differ. For L2-normalized vectors this is their cosine similarity. The
+// loop is unrolled into four independent sums, which lets the CPU overlap the
+// multiply-adds instead of waiting on a single accumulator.
+func dotProduct(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	b = b[:len(a)] // Bounds check elimination
+
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+
+	return (s0 + s1) + (s2 + s3)
+}
+
+// SimilarityMatrix returns the pairwise cosine similarity of features.
+// The matrix is symmetric; the diagonal holds each vector's self-similarity
+// (1 for any non-zero vector).
+func SimilarityMatrix(features [][]float32) [][]float32 {
+	matrix := make([][]float32, len(features))
+	for i := range matrix {
+		matrix[i] = make([]float32, len(features))
+	}
+
+	for i := range features {
+		for j := i; j < len(features); j++ {
+			similarity := cosineSimilarity(features[i], features[j])
+			matrix[i][j] = similarity
+			matrix[j][i] = similarity
+		}
+	}
+
+	return matrix
+}
+
+// FindDuplicates groups the indices of features whose cosine similarity is
+// at least threshold. Grouping is transitive: if a matches b and b matches c,
+// all three share a group even when a and c fall below the threshold.
+// Only groups with two or more members are returned, each sorted ascending
+// and ordered by their first index.
+func FindDuplicates(features [][]float32, threshold float32) [][]int {
+	// Union-find over indices
+	parent := make([]int, len(features))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range features {
+		for j := i + 1; j < len(features); j++ {
+			if cosineSimilarity(features[i], features[j]) >= threshold {
+				ri, rj := find(i), find(j)
+				// Keep the smallest index as root so groups order by first index
+				if ri < rj {
+					parent[rj] = ri
+				} else if rj < ri {
+					parent[ri] = rj
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for i := range features {
+		root := find(i)
+		if root == i {
+			roots = append(roots, i)
+		}
+		members[root] = append(members[root], i)
+	}
+
+	var groups [][]int
+	for _, root := range roots {
+		if len(members[root]) > 1 {
+			groups = append(groups, members[root])
+		}
+	}
+
+	return groups
+}
+
+// selectPrimaryFace picks the subject among faces detected in an image with
+// the given bounds
+func (fr *FaceRecognizer) selectPrimaryFace(faces []image.Rectangle, bounds image.Rectangle) (image.Rectangle, bool) {
+	if len(faces) == 0 {
+		return image.Rectangle{}, false
+	}
+
+	if fr.primaryFace == PrimaryCentral {
+		return centralFace(faces, bounds), true
+	}
+	return largestFace(faces), true
+}
+
+// centralFace returns the face rectangle whose center is nearest the center
+// of bounds
+func centralFace(faces []image.Rectangle, bounds image.Rectangle) image.Rectangle {
+	center := bounds.Min.Add(bounds.Max).Div(2)
+
+	var central image.Rectangle
+	best := -1
+	for _, face := range faces {
+		d := face.Min.Add(face.Max).Div(2).Sub(center)
+		if dist := d.X*d.X + d.Y*d.Y; best < 0 || dist < best {
+			central, best = face, dist
+		}
+	}
+	return central
+}
+
+// largestFace returns the face rectangle with the largest area
+func largestFace(faces []image.Rectangle) image.Rectangle {
+	var largest image.Rectangle
+	for _, face := range faces {
+		if face.Dx()*face.Dy() > largest.Dx()*largest.Dy() {
+			largest = face
+		}
+	}
+	return largest
+}
+
 // euclideanDistance calculates the Euclidean distance between two vectors
 func euclideanDistance(a, b []float32) float32 {
 	if len(a) != len(b) {
@@ -654,6 +4064,58 @@ func euclideanDistance(a, b []float32) float32 {
 	return float32(math.Sqrt(float64(sum)))
 }
 
+// distanceToConfidence maps a distance in [0, +inf) to a confidence in (0, 1]
+func distanceToConfidence(distance float32) float32 {
+	return 1 / (1 + distance)
+}
+
+// computeCentroid averages the samples of a person and L2-normalizes the result.
+// Samples whose dimension differs from the first one are ignored.
+func computeCentroid(features []FaceFeature) []float32 {
+	return normalizeFeature(meanFeature(features))
+}
+
+// meanFeature averages the samples of a person. Samples whose dimension
+// differs from the first one are ignored.
+func meanFeature(features []FaceFeature) []float32 {
+	if len(features) == 0 {
+		return nil
+	}
+
+	centroid := make([]float32, len(features[0].Feature))
+	count := 0
+	for _, sample := range features {
+		if len(sample.Feature) != len(centroid) {
+			continue
+		}
+		for i, v := range sample.Feature {
+			centroid[i] += v
+		}
+		count++
+	}
+
+	for i := range centroid {
+		centroid[i] /= float32(count)
+	}
+
+	return centroid
+}
+
+// forward runs the encoder on a blob. The net keeps per-call state and its
+// output may alias internal buffers, so inference is serialized and the
+// output is copied before the lock is released. The caller must hold
+// fr.modelMu.
+func (fr *FaceRecognizer) forward(blob gocv.Mat) gocv.Mat {
+	fr.encoderMu.Lock()
+	defer fr.encoderMu.Unlock()
+
+	fr.faceEncoder.SetInput(blob, fr.modelConfig.InputLayerName)
+	output := fr.faceEncoder.Forward(fr.modelConfig.OutputLayerName)
+	defer output.Close()
+
+	return output.Clone()
+}
+
 // normalizeFeature performs L2 normalization on a feature vector
 func normalizeFeature(feature []float32) []float32 {
 	var norm float32
@@ -673,3 +4135,35 @@ func normalizeFeature(feature []float32) []float32 {
 
 	return normalized
 }
+
+// standardizeFeature subtracts the mean of a feature vector from every
+// component and divides by the standard deviation. Constant vectors become
+// all zeros.
+func standardizeFeature(feature []float32) []float32 {
+	if len(feature) == 0 {
+		return feature
+	}
+
+	var mean float64
+	for _, v := range feature {
+		mean += float64(v)
+	}
+	mean /= float64(len(feature))
+
+	var variance float64
+	for _, v := range feature {
+		d := float64(v) - mean
+		variance += d * d
+	}
+	std := math.Sqrt(variance / float64(len(feature)))
+
+	standardized := make([]float32, len(feature))
+	for i, v := range feature {
+		standardized[i] = float32(float64(v) - mean)
+		if std > 0 {
+			standardized[i] = float32((float64(v) - mean) / std)
+		}
+	}
+
+	return standardized
+}
@@ -1,12 +1,23 @@
 package face
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	pigo "github.com/esimov/pigo/core"
 	"gocv.io/x/gocv"
 )
 
@@ -66,6 +77,10 @@ func TestNewFaceRecognizer_DefaultOptions(t *testing.T) {
 	if modelConfig.Type != ModelOpenFace {
 		t.Errorf("Expected default model OpenFace, got %s", modelConfig.Type)
 	}
+
+	if recognizer.interpolation != gocv.InterpolationLinear {
+		t.Errorf("Expected default linear interpolation, got %v", recognizer.interpolation)
+	}
 }
 
 func TestNewFaceRecognizer_WithOptions(t *testing.T) {
@@ -113,6 +128,9 @@ func TestNewFaceRecognizer_CustomModelConfig(t *testing.T) {
 		ScaleFactor: 1.0 / 127.5,
 		SwapRB:      true,
 		Crop:        false,
+
+		InputLayerName:  "input.1",
+		OutputLayerName: "embedding",
 	}
 
 	config := Config{
@@ -140,6 +158,32 @@ func TestNewFaceRecognizer_CustomModelConfig(t *testing.T) {
 	if modelConfig.FeatureDim != 256 {
 		t.Errorf("Expected feature dim 256, got %d", modelConfig.FeatureDim)
 	}
+
+	if modelConfig.InputLayerName != "input.1" || modelConfig.OutputLayerName != "embedding" {
+		t.Errorf("Expected layer names input.1/embedding, got %s/%s",
+			modelConfig.InputLayerName, modelConfig.OutputLayerName)
+	}
+}
+
+func TestNewFaceRecognizer_WithBackend(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config, WithBackend(gocv.NetBackendOpenCV, gocv.NetTargetCPU))
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	backend, target := recognizer.GetBackend()
+	if backend != gocv.NetBackendOpenCV || target != gocv.NetTargetCPU {
+		t.Errorf("Expected OpenCV backend on CPU, got backend %d target %d", backend, target)
+	}
 }
 
 // Test: Person management
@@ -167,8 +211,8 @@ func TestAddPerson(t *testing.T) {
 
 	// Test duplicate ID
 	err = recognizer.AddPerson("001", "Bob")
-	if err == nil {
-		t.Error("Expected error when adding duplicate ID, got nil")
+	if !errors.Is(err, ErrPersonExists) {
+		t.Errorf("Expected ErrPersonExists when adding duplicate ID, got %v", err)
 	}
 
 	// Verify person was added
@@ -304,6 +348,546 @@ func TestGetSampleCount(t *testing.T) {
 	}
 }
 
+func TestGetPersonFeatures(t *testing.T) {
+	fr := &FaceRecognizer{persons: make(map[string]*Person)}
+	fr.persons["001"] = &Person{ID: "001", Name: "Alice", Features: []FaceFeature{
+		{PersonID: "001", Feature: []float32{1, 0}},
+		{PersonID: "001", Feature: []float32{0, 1}},
+	}}
+
+	features, err := fr.GetPersonFeatures("001")
+	if err != nil {
+		t.Fatalf("GetPersonFeatures failed: %v", err)
+	}
+	if !reflect.DeepEqual(features, [][]float32{{1, 0}, {0, 1}}) {
+		t.Errorf("Expected [[1 0] [0 1]], got %v", features)
+	}
+
+	// The copy is independent of the stored samples
+	features[0][0] = 5
+	if fr.persons["001"].Features[0].Feature[0] != 1 {
+		t.Error("Expected stored feature to be unchanged")
+	}
+
+	if _, err := fr.GetPersonFeatures("999"); err == nil {
+		t.Error("Expected error for non-existent person, got nil")
+	}
+}
+
+func TestRemoveFaceSample(t *testing.T) {
+	storage := NewMemoryStorage()
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(true)(fr)
+
+	fr.AddPerson("001", "Alice")
+	fr.persons["001"].Features = []FaceFeature{
+		{PersonID: "001", Feature: []float32{1, 0}},
+		{PersonID: "001", Feature: []float32{0, 1}},
+		{PersonID: "001", Feature: []float32{-1, 0}},
+	}
+
+	tests := []struct {
+		name        string
+		personID    string
+		index       int
+		expectError bool
+	}{
+		{"Unknown person", "999", 0, true},
+		{"Negative index", "001", -1, true},
+		{"Index out of range", "001", 3, true},
+		{"Middle sample", "001", 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fr.RemoveFaceSample(tt.personID, tt.index)
+			if (err != nil) != tt.expectError {
+				t.Errorf("Expected error=%v, got %v", tt.expectError, err)
+			}
+		})
+	}
+
+	person, _ := storage.LoadPerson("001")
+	if len(person.Features) != 2 {
+		t.Fatalf("Expected 2 persisted samples, got %d", len(person.Features))
+	}
+	if person.Features[0].Feature[0] != 1 || person.Features[1].Feature[0] != -1 {
+		t.Errorf("Expected the middle sample to be removed, got %v", person.Features)
+	}
+}
+
+func TestClearFaceSamples(t *testing.T) {
+	storage := &failingStorage{NewMemoryStorage()}
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+
+	fr.persons["001"] = &Person{ID: "001", Name: "Alice", Features: []FaceFeature{
+		{PersonID: "001", Feature: []float32{1, 0}},
+	}}
+
+	if err := fr.ClearFaceSamples("999"); err == nil {
+		t.Error("Expected error for non-existent person, got nil")
+	}
+
+	// Storage failure rolls the samples back
+	WithAutoPersist(true)(fr)
+	if err := fr.ClearFaceSamples("001"); err == nil {
+		t.Error("Expected error when storage write fails")
+	}
+	if count, _ := fr.GetSampleCount("001"); count != 1 {
+		t.Errorf("Expected samples to be restored, got %d", count)
+	}
+
+	WithAutoPersist(false)(fr)
+	if err := fr.ClearFaceSamples("001"); err != nil {
+		t.Fatalf("Failed to clear samples: %v", err)
+	}
+	if count, _ := fr.GetSampleCount("001"); count != 0 {
+		t.Errorf("Expected 0 samples after clear, got %d", count)
+	}
+	if _, err := fr.GetPerson("001"); err != nil {
+		t.Error("Expected person to remain enrolled after clear")
+	}
+}
+
+func TestHasPerson_PersonCount(t *testing.T) {
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: NewMemoryStorage()}
+
+	if fr.HasPerson("001") || fr.PersonCount() != 0 {
+		t.Error("Expected an empty database")
+	}
+
+	fr.AddPerson("001", "Alice")
+	fr.AddPerson("002", "Bob")
+	if !fr.HasPerson("001") || fr.HasPerson("003") {
+		t.Error("Expected HasPerson to report only registered persons")
+	}
+	if count := fr.PersonCount(); count != 2 {
+		t.Errorf("Expected 2 persons, got %d", count)
+	}
+
+	fr.RemovePerson("001")
+	if fr.HasPerson("001") || fr.PersonCount() != 1 {
+		t.Error("Expected removed person to be gone")
+	}
+}
+
+func BenchmarkHasPerson(b *testing.B) {
+	fr := &FaceRecognizer{persons: map[string]*Person{"001": {ID: "001"}}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fr.HasPerson("002")
+	}
+}
+
+func TestUpdatePerson(t *testing.T) {
+	storage := NewMemoryStorage()
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(true)(fr)
+
+	fr.AddPerson("001", "Alcie")
+	fr.persons["001"].Features = []FaceFeature{{PersonID: "001", Feature: []float32{1, 0}}}
+
+	if err := fr.UpdatePerson("999", "Nobody"); err == nil {
+		t.Error("Expected error for non-existent person, got nil")
+	}
+
+	if err := fr.UpdatePerson("001", "Alice"); err != nil {
+		t.Fatalf("Failed to update person: %v", err)
+	}
+
+	stored, _ := storage.LoadPerson("001")
+	if stored.Name != "Alice" {
+		t.Errorf("Expected stored name 'Alice', got '%s'", stored.Name)
+	}
+	if len(stored.Features) != 1 {
+		t.Errorf("Expected samples to be kept, got %d", len(stored.Features))
+	}
+
+	// Storage failure rolls the rename back
+	fr.storage = &failingStorage{storage}
+	if err := fr.UpdatePerson("001", "Alicia"); err == nil {
+		t.Error("Expected error when storage write fails")
+	}
+	if person, _ := fr.GetPerson("001"); person.Name != "Alice" {
+		t.Errorf("Expected name to be rolled back, got '%s'", person.Name)
+	}
+}
+
+func TestSetPersonMetadata(t *testing.T) {
+	storage := NewMemoryStorage()
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(true)(fr)
+
+	fr.AddPerson("001", "Alice")
+
+	if err := fr.SetPersonMetadata("999", map[string]string{"team": "x"}); err == nil {
+		t.Error("Expected error for non-existent person, got nil")
+	}
+
+	metadata := map[string]string{"employee_id": "E42"}
+	if err := fr.SetPersonMetadata("001", metadata); err != nil {
+		t.Fatalf("Failed to set metadata: %v", err)
+	}
+	metadata["employee_id"] = "changed"
+
+	stored, _ := storage.LoadPerson("001")
+	if stored.Metadata["employee_id"] != "E42" {
+		t.Errorf("Expected stored metadata E42, got %v", stored.Metadata)
+	}
+
+	if err := fr.SetPersonMetadata("001", nil); err != nil {
+		t.Fatalf("Failed to clear metadata: %v", err)
+	}
+	if person, _ := fr.GetPerson("001"); person.Metadata != nil {
+		t.Errorf("Expected metadata to be cleared, got %v", person.Metadata)
+	}
+}
+
+func TestSetPersonEnabled(t *testing.T) {
+	storage := NewMemoryStorage()
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(true)(fr)
+
+	fr.AddPerson("001", "Alice")
+	fr.AddPerson("002", "Bob")
+	fr.persons["001"].Features = []FaceFeature{{PersonID: "001", Feature: normalizeFeature([]float32{1, 0})}}
+	fr.persons["002"].Features = []FaceFeature{{PersonID: "002", Feature: normalizeFeature([]float32{0.8, 0.6})}}
+	query := []float32{1, 0}
+
+	if err := fr.SetPersonEnabled("999", false); err == nil {
+		t.Error("Expected error for non-existent person, got nil")
+	}
+
+	if err := fr.SetPersonEnabled("001", false); err != nil {
+		t.Fatalf("Failed to disable person: %v", err)
+	}
+
+	if id, _, _ := fr.matchPerson(query); id != "002" {
+		t.Errorf("Expected disabled person to be skipped, got %s", id)
+	}
+	if ranked := fr.rankPersons(query, 2); len(ranked) != 1 || ranked[0].PersonID != "002" {
+		t.Errorf("Expected only 002 to be ranked, got %+v", ranked)
+	}
+	if _, _, err := fr.verifyPerson(query, fr.persons["001"]); err == nil {
+		t.Error("Expected error verifying against a disabled person")
+	}
+
+	// Still listed, and flagged
+	var listed *Person
+	for _, person := range fr.ListPersons() {
+		if person.ID == "001" {
+			listed = person
+		}
+	}
+	if listed == nil || !listed.Disabled {
+		t.Errorf("Expected disabled person to be listed with Disabled set, got %+v", listed)
+	}
+	if stored, _ := storage.LoadPerson("001"); !stored.Disabled {
+		t.Error("Expected disabled flag to be persisted")
+	}
+
+	if err := fr.SetPersonEnabled("001", true); err != nil {
+		t.Fatalf("Failed to enable person: %v", err)
+	}
+	if id, _, _ := fr.matchPerson(query); id != "001" {
+		t.Errorf("Expected re-enabled person to match, got %s", id)
+	}
+
+	// Storage failure rolls the change back
+	fr.storage = &failingStorage{storage}
+	if err := fr.SetPersonEnabled("001", false); err == nil {
+		t.Error("Expected error when storage write fails")
+	}
+	if person, _ := fr.GetPerson("001"); person.Disabled {
+		t.Error("Expected disabled flag to be rolled back")
+	}
+}
+
+func TestMergePersons(t *testing.T) {
+	sample := func(id string, v ...float32) FaceFeature {
+		return FaceFeature{PersonID: id, Feature: normalizeFeature(v)}
+	}
+
+	setup := func() (*FaceRecognizer, *MemoryStorage) {
+		storage := NewMemoryStorage()
+		fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+		WithAutoPersist(true)(fr)
+		WithMaxSamplesPerPerson(3)(fr)
+
+		fr.AddPerson("001", "Alice")
+		fr.AddPerson("002", "Alice (duplicate)")
+		fr.AddPerson("003", "Carol")
+		fr.persons["001"].Features = []FaceFeature{sample("001", 1, 0), sample("001", 0.9, 0.1)}
+		fr.persons["002"].Features = []FaceFeature{sample("002", 0.8, 0.2), sample("002", 0.7, 0.3)}
+		fr.persons["003"].Features = []FaceFeature{sample("003", 0, 1, 0)}
+		return fr, storage
+	}
+
+	t.Run("Invalid IDs", func(t *testing.T) {
+		fr, _ := setup()
+		for _, ids := range [][2]string{{"001", "001"}, {"999", "002"}, {"001", "999"}} {
+			if err := fr.MergePersons(ids[0], ids[1]); err == nil {
+				t.Errorf("Expected error merging %s into %s", ids[1], ids[0])
+			}
+		}
+	})
+
+	t.Run("Dimension mismatch", func(t *testing.T) {
+		fr, _ := setup()
+		if err := fr.MergePersons("001", "003"); err == nil {
+			t.Error("Expected error merging samples of a different dimension")
+		}
+		if len(fr.persons["001"].Features) != 2 || fr.persons["003"] == nil {
+			t.Error("Expected failed merge to leave both persons untouched")
+		}
+	})
+
+	t.Run("Merges and removes", func(t *testing.T) {
+		fr, storage := setup()
+		if err := fr.MergePersons("001", "002"); err != nil {
+			t.Fatalf("Failed to merge: %v", err)
+		}
+
+		person, _ := fr.GetPerson("001")
+		if person.Name != "Alice" {
+			t.Errorf("Expected kept name Alice, got %s", person.Name)
+		}
+		// Trimmed to the newest 3 of the 4 samples
+		if len(person.Features) != 3 {
+			t.Fatalf("Expected 3 samples after trimming, got %d", len(person.Features))
+		}
+		for i, feature := range person.Features {
+			if feature.PersonID != "001" {
+				t.Errorf("Sample %d: expected PersonID 001, got %s", i, feature.PersonID)
+			}
+		}
+
+		if _, err := fr.GetPerson("002"); err == nil {
+			t.Error("Expected merged person to be removed")
+		}
+		if exists, _ := storage.PersonExists("002"); exists {
+			t.Error("Expected merged person to be deleted from storage")
+		}
+		if stored, _ := storage.LoadPerson("001"); len(stored.Features) != 3 {
+			t.Errorf("Expected 3 stored samples, got %d", len(stored.Features))
+		}
+	})
+
+	t.Run("Storage failure", func(t *testing.T) {
+		fr, storage := setup()
+		fr.storage = &failingStorage{storage}
+		if err := fr.MergePersons("001", "002"); err == nil {
+			t.Fatal("Expected error when storage write fails")
+		}
+		if len(fr.persons["001"].Features) != 2 || fr.persons["002"] == nil {
+			t.Error("Expected failed merge to be rolled back")
+		}
+	})
+}
+
+func TestPerson_JSONWithoutMetadata(t *testing.T) {
+	// Databases written before metadata existed must still load
+	data := []byte(`{"001":{"id":"001","name":"Alice","features":[{"person_id":"001","feature":[1,0]}]}}`)
+
+	persons := make(map[string]*Person)
+	if err := json.Unmarshal(data, &persons); err != nil {
+		t.Fatalf("Failed to unmarshal database: %v", err)
+	}
+	if persons["001"].Name != "Alice" || persons["001"].Metadata != nil {
+		t.Errorf("Unexpected person: %+v", persons["001"])
+	}
+
+	out, _ := json.Marshal(persons["001"])
+	if strings.Contains(string(out), "metadata") {
+		t.Errorf("Expected empty metadata to be omitted, got %s", out)
+	}
+
+	// Persons without the flag are enabled
+	if persons["001"].Disabled {
+		t.Error("Expected person without disabled flag to be enabled")
+	}
+	if strings.Contains(string(out), "disabled") {
+		t.Errorf("Expected enabled flag to be omitted, got %s", out)
+	}
+}
+
+// Test: Database export/import
+
+func TestExportImportDatabase(t *testing.T) {
+	src := &FaceRecognizer{persons: make(map[string]*Person), modelConfig: modelConfigs[ModelOpenFace]}
+	src.persons["001"] = &Person{ID: "001", Name: "Alice", Metadata: map[string]string{"team": "a"}, Features: []FaceFeature{
+		{PersonID: "001", Feature: make([]float32, 128)},
+	}}
+	src.persons["002"] = &Person{ID: "002", Name: "Bob", Features: []FaceFeature{}}
+
+	var buf bytes.Buffer
+	if err := src.ExportDatabase(&buf); err != nil {
+		t.Fatalf("Failed to export database: %v", err)
+	}
+
+	// Import into a recognizer backed by another storage
+	storage := NewMemoryStorage()
+	storage.SavePerson(&Person{ID: "stale", Name: "Stale"})
+	dst := &FaceRecognizer{persons: make(map[string]*Person), storage: storage, modelConfig: modelConfigs[ModelOpenFace]}
+	dst.persons["stale"] = &Person{ID: "stale", Name: "Stale"}
+	WithAutoPersist(true)(dst)
+
+	if err := dst.ImportDatabase(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Failed to import database: %v", err)
+	}
+
+	if len(dst.ListPersons()) != 2 {
+		t.Errorf("Expected 2 persons after import, got %d", len(dst.ListPersons()))
+	}
+	alice, err := storage.LoadPerson("001")
+	if err != nil {
+		t.Fatalf("Expected imported person in storage: %v", err)
+	}
+	if alice.Metadata["team"] != "a" || len(alice.Features) != 1 {
+		t.Errorf("Unexpected imported person: %+v", alice)
+	}
+	if exists, _ := storage.PersonExists("stale"); exists {
+		t.Error("Expected person missing from the archive to be deleted from storage")
+	}
+}
+
+// partialStorage is a FaceStorage whose writes fail for one person
+type partialStorage struct {
+	*MemoryStorage
+	failID string
+}
+
+func (s *partialStorage) SavePerson(person *Person) error {
+	if person.ID == s.failID {
+		return errors.New("storage unavailable")
+	}
+	return s.MemoryStorage.SavePerson(person)
+}
+
+func TestImportDatabase_RollsBackStorage(t *testing.T) {
+	src := &FaceRecognizer{persons: make(map[string]*Person), modelConfig: modelConfigs[ModelOpenFace]}
+	src.persons["001"] = &Person{ID: "001", Name: "Alice (imported)", Features: []FaceFeature{}}
+	src.persons["002"] = &Person{ID: "002", Name: "Bob", Features: []FaceFeature{}}
+
+	var buf bytes.Buffer
+	if err := src.ExportDatabase(&buf); err != nil {
+		t.Fatalf("Failed to export database: %v", err)
+	}
+
+	storage := &partialStorage{NewMemoryStorage(), "002"}
+	dst := &FaceRecognizer{persons: make(map[string]*Person), storage: storage, modelConfig: modelConfigs[ModelOpenFace]}
+	WithAutoPersist(true)(dst)
+	dst.AddPerson("000", "Zoe")
+	dst.AddPerson("001", "Alice")
+
+	if err := dst.ImportDatabase(&buf); err == nil {
+		t.Fatal("Expected the failed save to fail the import")
+	}
+
+	// Alice was written before Bob failed and is restored
+	alice, err := storage.LoadPerson("001")
+	if err != nil || alice.Name != "Alice" {
+		t.Errorf("Expected Alice to be restored in storage, got %+v, %v", alice, err)
+	}
+	if exists, _ := storage.PersonExists("000"); !exists {
+		t.Error("Expected Zoe to stay in storage")
+	}
+	if person, _ := dst.GetPerson("001"); person == nil || person.Name != "Alice" || dst.PersonCount() != 2 {
+		t.Error("Expected the in-memory database to be untouched")
+	}
+}
+
+func TestExportPersonsCSV(t *testing.T) {
+	fr := &FaceRecognizer{persons: map[string]*Person{
+		"002": {ID: "002", Name: "Bob, Jr.", Features: []FaceFeature{}},
+		"001": {ID: "001", Name: "Alice", Features: []FaceFeature{
+			{PersonID: "001", Feature: []float32{1, 0, 0}},
+			{PersonID: "001", Feature: []float32{0, 1, 0}},
+		}},
+	}}
+
+	var buf bytes.Buffer
+	if err := fr.ExportPersonsCSV(&buf); err != nil {
+		t.Fatalf("ExportPersonsCSV failed: %v", err)
+	}
+
+	expected := "id,name,sample_count,feature_dim\n001,Alice,2,3\n002,\"Bob, Jr.\",0,0\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestImportDatabase_Rejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		errText string
+	}{
+		{"Dimension mismatch", `{"version":1,"model_type":"arcface","feature_dim":512,"persons":[]}`, "feature dimension 512"},
+		{"Unsupported version", `{"version":99,"model_type":"openface","feature_dim":128,"persons":[]}`, "unsupported archive version"},
+		{"Sample dimension mismatch", `{"version":1,"model_type":"openface","feature_dim":128,"persons":[{"id":"001","name":"A","features":[{"person_id":"001","feature":[1,0]}]}]}`, "dimension 2"},
+		{"Duplicate ID", `{"version":1,"model_type":"openface","feature_dim":128,"persons":[{"id":"001","name":"A"},{"id":"001","name":"B"}]}`, "duplicate"},
+		{"Model mismatch", `{"version":1,"model_type":"facenet","feature_dim":128,"persons":[]}`, "incompatible model"},
+		{"Malformed", `{"version":`, "failed to read archive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{persons: map[string]*Person{"keep": {ID: "keep"}}, modelConfig: modelConfigs[ModelOpenFace]}
+
+			err := fr.ImportDatabase(strings.NewReader(tt.archive))
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+			}
+			if _, err := fr.GetPerson("keep"); err != nil {
+				t.Error("Expected existing persons to be untouched after a rejected import")
+			}
+		})
+	}
+}
+
+func TestLoadDatabase_DimensionCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arcface.json")
+	arcface := &FaceRecognizer{persons: map[string]*Person{
+		"001": {ID: "001", Name: "Alice", Features: []FaceFeature{{PersonID: "001", Feature: make([]float32, 512)}}},
+	}}
+	if err := arcface.SaveDatabase(path); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		strict  bool
+		wantErr bool
+	}{
+		{"Strict", true, true},
+		{"Lenient", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{persons: map[string]*Person{"keep": {ID: "keep"}}, modelConfig: modelConfigs[ModelOpenFace]}
+			WithStrictDimensionCheck(tt.strict)(fr)
+
+			err := fr.LoadDatabase(path)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Failed to load database: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "person 001") || !strings.Contains(err.Error(), "dimension 512, expected 128") {
+				t.Errorf("Expected error naming person 001 and the mismatch, got %v", err)
+			}
+			if _, err := fr.GetPerson("keep"); err != nil {
+				t.Error("Expected existing persons to be untouched after a rejected load")
+			}
+		})
+	}
+}
+
 // Test: Threshold management
 
 func TestSetGetThreshold(t *testing.T) {
@@ -404,33 +988,145 @@ func TestCosineSimilarity(t *testing.T) {
 	}
 }
 
-func TestEuclideanDistance(t *testing.T) {
+func TestDotProduct(t *testing.T) {
 	tests := []struct {
-		name      string
-		a         []float32
-		b         []float32
-		expected  float32
-		tolerance float32
+		name     string
+		a        []float32
+		b        []float32
+		expected float32
 	}{
-		{
-			name:      "Identical vectors",
-			a:         []float32{1, 2, 3},
-			b:         []float32{1, 2, 3},
-			expected:  0.0,
-			tolerance: 0.0001,
-		},
-		{
-			name:      "Unit distance",
-			a:         []float32{0, 0},
-			b:         []float32{1, 0},
-			expected:  1.0,
-			tolerance: 0.0001,
-		},
-		{
-			name:      "3-4-5 triangle",
-			a:         []float32{0, 0},
-			b:         []float32{3, 4},
-			expected:  5.0,
+		{"Unrolled with tail", []float32{1, 2, 3, 4, 5, 6}, []float32{6, 5, 4, 3, 2, 1}, 56},
+		{"Shorter than unroll", []float32{1, 2}, []float32{3, 4}, 11},
+		{"Different lengths", []float32{1, 2}, []float32{1, 2, 3}, 0},
+		{"Empty vectors", []float32{}, []float32{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dotProduct(tt.a, tt.b); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// On normalized vectors it agrees with cosineSimilarity
+	a := normalizeFeature([]float32{0.3, -1.2, 0.8, 2.1, 0.05})
+	b := normalizeFeature([]float32{1.1, 0.4, -0.6, 1.9, 0.7})
+	if diff := math.Abs(float64(dotProduct(a, b) - cosineSimilarity(a, b))); diff > 1e-6 {
+		t.Errorf("Expected dot product to match cosine similarity, diff %g", diff)
+	}
+}
+
+func TestLoadDatabase_NormalizesSamples(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	data := `{"001": {"id": "001", "name": "Alice", "features": [{"person_id": "001", "feature": [3, 4]}]}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+
+	fr := &FaceRecognizer{persons: make(map[string]*Person)}
+	if err := fr.LoadDatabase(path); err != nil {
+		t.Fatalf("LoadDatabase failed: %v", err)
+	}
+
+	got := fr.persons["001"].Features[0].Feature
+	if !reflect.DeepEqual(got, []float32{0.6, 0.8}) {
+		t.Errorf("Expected normalized sample [0.6 0.8], got %v", got)
+	}
+}
+
+func TestSimilarityMatrix(t *testing.T) {
+	features := [][]float32{
+		{1, 0},
+		{0, 1},
+		{1, 1},
+	}
+
+	matrix := SimilarityMatrix(features)
+	if len(matrix) != 3 {
+		t.Fatalf("Expected 3x3 matrix, got %d rows", len(matrix))
+	}
+
+	for i := range features {
+		if math.Abs(float64(matrix[i][i]-1)) > 1e-6 {
+			t.Errorf("Expected diagonal [%d][%d] = 1, got %v", i, i, matrix[i][i])
+		}
+		for j := range features {
+			if matrix[i][j] != matrix[j][i] {
+				t.Errorf("Expected symmetric matrix, [%d][%d]=%v [%d][%d]=%v", i, j, matrix[i][j], j, i, matrix[j][i])
+			}
+		}
+	}
+
+	if matrix[0][1] != 0 {
+		t.Errorf("Expected orthogonal vectors to score 0, got %v", matrix[0][1])
+	}
+	if math.Abs(float64(matrix[0][2])-math.Sqrt2/2) > 1e-6 {
+		t.Errorf("Expected %v, got %v", math.Sqrt2/2, matrix[0][2])
+	}
+
+	if len(SimilarityMatrix(nil)) != 0 {
+		t.Error("Expected empty matrix for no features")
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	features := [][]float32{
+		{1, 0, 0},      // 0
+		{0, 1, 0},      // 1
+		{0.99, 0.1, 0}, // 2: near-duplicate of 0
+		{0, 0, 1},      // 3
+		{0, 0.98, 0.2}, // 4: near-duplicate of 1
+		{1, 0, 0},      // 5: exact duplicate of 0
+	}
+
+	tests := []struct {
+		name      string
+		threshold float32
+		expected  [][]int
+	}{
+		{"Near duplicates", 0.95, [][]int{{0, 2, 5}, {1, 4}}},
+		{"Exact only", 0.9999, [][]int{{0, 5}}},
+		{"Nothing above threshold", 1.1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := FindDuplicates(features, tt.threshold)
+			if !reflect.DeepEqual(groups, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, groups)
+			}
+		})
+	}
+}
+
+func TestEuclideanDistance(t *testing.T) {
+	tests := []struct {
+		name      string
+		a         []float32
+		b         []float32
+		expected  float32
+		tolerance float32
+	}{
+		{
+			name:      "Identical vectors",
+			a:         []float32{1, 2, 3},
+			b:         []float32{1, 2, 3},
+			expected:  0.0,
+			tolerance: 0.0001,
+		},
+		{
+			name:      "Unit distance",
+			a:         []float32{0, 0},
+			b:         []float32{1, 0},
+			expected:  1.0,
+			tolerance: 0.0001,
+		},
+		{
+			name:      "3-4-5 triangle",
+			a:         []float32{0, 0},
+			b:         []float32{3, 4},
+			expected:  5.0,
 			tolerance: 0.0001,
 		},
 		{
@@ -444,67 +1140,2232 @@ func TestEuclideanDistance(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := euclideanDistance(tt.a, tt.b)
+			result := euclideanDistance(tt.a, tt.b)
+
+			if math.Abs(float64(result-tt.expected)) > float64(tt.tolerance) {
+				t.Errorf("Expected %.4f, got %.4f", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNormalizeFeature(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     []float32
+		expectLen float32 // Expected L2 norm (should be 1.0 after normalization)
+	}{
+		{
+			name:      "Simple vector",
+			input:     []float32{3, 4},
+			expectLen: 1.0,
+		},
+		{
+			name:      "Already normalized",
+			input:     []float32{1, 0, 0},
+			expectLen: 1.0,
+		},
+		{
+			name:      "Multi-dimensional",
+			input:     []float32{1, 2, 3, 4},
+			expectLen: 1.0,
+		},
+		{
+			name:      "Negative values",
+			input:     []float32{-3, 4},
+			expectLen: 1.0,
+		},
+		{
+			name:      "Zero vector",
+			input:     []float32{0, 0, 0},
+			expectLen: 0.0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized := normalizeFeature(tt.input)
+
+			// Calculate L2 norm
+			var sumSquares float32
+			for _, v := range normalized {
+				sumSquares += v * v
+			}
+			length := float32(math.Sqrt(float64(sumSquares)))
+
+			tolerance := float32(0.0001)
+			if math.Abs(float64(length-tt.expectLen)) > float64(tolerance) {
+				t.Errorf("Expected L2 norm %.4f, got %.4f", tt.expectLen, length)
+			}
+		})
+	}
+}
+
+// Test: Verification
+
+func TestVerifyFeatures(t *testing.T) {
+	fr := &FaceRecognizer{threshold: 0.6}
+
+	tests := []struct {
+		name        string
+		a           []float32
+		b           []float32
+		expectMatch bool
+	}{
+		{"Identical vectors", []float32{1, 0, 0}, []float32{1, 0, 0}, true},
+		{"Orthogonal vectors", []float32{1, 0, 0}, []float32{0, 1, 0}, false},
+		{"Close vectors", []float32{1, 0.2, 0}, []float32{1, 0, 0.2}, true},
+		{"Different length vectors", []float32{1, 0}, []float32{1, 0, 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, similarity := fr.VerifyFeatures(tt.a, tt.b)
+			if match != tt.expectMatch {
+				t.Errorf("Expected match=%v, got %v (similarity %.4f)", tt.expectMatch, match, similarity)
+			}
+		})
+	}
+}
+
+func TestVerifyPerson(t *testing.T) {
+	alice := &Person{ID: "001", Name: "Alice", Features: []FaceFeature{
+		{PersonID: "001", Feature: normalizeFeature([]float32{1, 0, 0})},
+		{PersonID: "001", Feature: normalizeFeature([]float32{0.8, 0.6, 0})},
+	}}
+	empty := &Person{ID: "002", Name: "Bob"}
+	disabled := &Person{ID: "003", Name: "Carol", Disabled: true, Features: alice.Features}
+
+	tests := []struct {
+		name     string
+		strategy MatchStrategy
+		person   *Person
+		feature  []float32
+		match    bool
+		wantErr  bool
+	}{
+		{"Max sample match", StrategyMaxSample, alice, normalizeFeature([]float32{0.8, 0.6, 0.05}), true, false},
+		{"Centroid match", StrategyCentroid, alice, normalizeFeature([]float32{0.9, 0.3, 0}), true, false},
+		{"Different face", StrategyMaxSample, alice, []float32{0, 0, 1}, false, false},
+		{"No samples", StrategyMaxSample, empty, []float32{1, 0, 0}, false, true},
+		{"Disabled", StrategyMaxSample, disabled, normalizeFeature([]float32{1, 0, 0}), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{threshold: 0.9, centroids: make(map[string][]float32)}
+			WithMatchStrategy(tt.strategy)(fr)
+
+			match, similarity, err := fr.verifyPerson(tt.feature, tt.person)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if match != tt.match {
+				t.Errorf("Expected match %v, got %v (similarity %.3f)", tt.match, match, similarity)
+			}
+		})
+	}
+}
+
+func TestPersonErrors(t *testing.T) {
+	fr := &FaceRecognizer{persons: map[string]*Person{"001": {ID: "001", Name: "Alice"}}}
+	storage := NewMemoryStorage()
+
+	tests := []struct {
+		name     string
+		call     func() error
+		expected error
+	}{
+		{"GetPerson", func() error { _, err := fr.GetPerson("999"); return err }, ErrPersonNotFound},
+		{"UpdatePerson", func() error { return fr.UpdatePerson("999", "Bob") }, ErrPersonNotFound},
+		{"RemovePerson", func() error { return fr.RemovePerson("999") }, ErrPersonNotFound},
+		{"MergePersons", func() error { return fr.MergePersons("001", "999") }, ErrPersonNotFound},
+		{"AddPerson duplicate", func() error { return fr.AddPerson("001", "Bob") }, ErrPersonExists},
+		{"Storage LoadPerson", func() error { _, err := storage.LoadPerson("999"); return err }, ErrPersonNotFound},
+		{"Storage DeletePerson", func() error { return storage.DeletePerson("999") }, ErrPersonNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestVerifyAgainst_UnknownPerson(t *testing.T) {
+	fr := &FaceRecognizer{persons: make(map[string]*Person)}
+	img := gocv.NewMat()
+	defer img.Close()
+
+	_, _, err := fr.VerifyAgainst("missing", img)
+	if !errors.Is(err, ErrPersonNotFound) {
+		t.Errorf("Expected unknown person error, got %v", err)
+	}
+}
+
+func TestLargestFace(t *testing.T) {
+	faces := []image.Rectangle{
+		image.Rect(0, 0, 50, 50),
+		image.Rect(100, 100, 220, 220),
+		image.Rect(300, 300, 380, 380),
+	}
+
+	if got := largestFace(faces); got != faces[1] {
+		t.Errorf("Expected %v, got %v", faces[1], got)
+	}
+
+	if got := largestFace(nil); !got.Empty() {
+		t.Errorf("Expected empty rectangle for no faces, got %v", got)
+	}
+}
+
+func TestSelectPrimaryFace(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 400)
+	faces := []image.Rectangle{
+		image.Rect(0, 0, 120, 120),     // Largest, in the corner
+		image.Rect(170, 170, 230, 230), // Centered
+		image.Rect(300, 300, 380, 380),
+	}
+
+	tests := []struct {
+		name     string
+		mode     PrimaryFaceMode
+		expected image.Rectangle
+	}{
+		{"Largest", PrimaryLargest, faces[0]},
+		{"Central", PrimaryCentral, faces[1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{primaryFace: tt.mode}
+
+			got, ok := fr.selectPrimaryFace(faces, bounds)
+			if !ok || got != tt.expected {
+				t.Errorf("Expected %v, got %v (ok=%v)", tt.expected, got, ok)
+			}
+
+			if _, ok := fr.selectPrimaryFace(nil, bounds); ok {
+				t.Error("Expected no primary face when none are detected")
+			}
+		})
+	}
+}
+
+// Test: Region recognition
+
+func TestCropRect(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 200)
+
+	tests := []struct {
+		name     string
+		margin   float64
+		rect     image.Rectangle
+		expected image.Rectangle
+	}{
+		{"No margin", 0, image.Rect(50, 50, 150, 130), image.Rect(50, 50, 150, 130)},
+		{"Expanded", 0.1, image.Rect(50, 50, 150, 130), image.Rect(40, 42, 160, 138)},
+		{"Clamped to image", 0.25, image.Rect(10, 120, 90, 200), image.Rect(0, 100, 110, 200)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{}
+			WithCropMargin(tt.margin)(fr)
+
+			if got := fr.cropRect(tt.rect, bounds); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDetectFacesInROI_EmptyROI(t *testing.T) {
+	fr := &FaceRecognizer{}
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+
+	tests := []struct {
+		name string
+		roi  image.Rectangle
+	}{
+		{"Empty", image.Rectangle{}},
+		{"Outside image", image.Rect(150, 150, 200, 200)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if faces := fr.DetectFacesInROI(img, tt.roi); faces != nil {
+				t.Errorf("Expected nil, got %v", faces)
+			}
+		})
+	}
+}
+
+func TestROIImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 80))
+	img.Set(30, 20, color.RGBA{255, 0, 0, 255})
+
+	roi := roiImage{Image: img, rect: image.Rect(30, 20, 70, 60)}
+	if got := roi.Bounds(); got != image.Rect(0, 0, 40, 40) {
+		t.Errorf("Expected bounds (0,0)-(40,40), got %v", got)
+	}
+	if got := roi.At(0, 0); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("Expected ROI origin to map to (30,20), got %v", got)
+	}
+}
+
+func TestRecognizeRegions_SkipsEmptyRegions(t *testing.T) {
+	fr := &FaceRecognizer{persons: make(map[string]*Person)}
+
+	img := createTestImage(100, 100)
+	defer img.Close()
+
+	regions := []image.Rectangle{
+		image.Rect(150, 150, 200, 200), // Entirely outside
+		image.Rect(-50, -50, 0, 0),     // Empty after clamping
+		image.Rect(40, 40, 40, 80),     // Zero width
+	}
+
+	results, err := fr.RecognizeRegions(img, regions)
+	if err == nil {
+		t.Fatal("Expected error listing skipped regions")
+	}
+	if !strings.Contains(err.Error(), "[0 1 2]") {
+		t.Errorf("Expected skipped indices in error, got: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results, got %d", len(results))
+	}
+
+	results, err = fr.RecognizeRegions(img, nil)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected empty result without error, got %v, %v", results, err)
+	}
+}
+
+func TestRecognizeRegions_ClampsToImage(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config)
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	img := createTestImage(200, 200)
+	defer img.Close()
+
+	results, err := recognizer.RecognizeRegions(img, []image.Rectangle{
+		image.Rect(150, 150, 300, 300),
+		image.Rect(300, 300, 400, 400),
+	})
+	if err == nil {
+		t.Error("Expected partial-result error for the region outside the image")
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if results[0].BoundingBox != image.Rect(150, 150, 200, 200) {
+		t.Errorf("Expected region clamped to image, got %v", results[0].BoundingBox)
+	}
+	if results[0].PersonID != DefaultUnknownID {
+		t.Errorf("Expected unknown person with empty database, got %s", results[0].PersonID)
+	}
+}
+
+// Test: Top-K ranking
+
+func TestRankPersons(t *testing.T) {
+	fr := &FaceRecognizer{
+		persons: map[string]*Person{
+			"003": {ID: "003", Name: "Charlie", Features: []FaceFeature{
+				{PersonID: "003", Feature: []float32{1, 0, 0}},
+			}},
+			"001": {ID: "001", Name: "Alice", Features: []FaceFeature{
+				{PersonID: "001", Feature: []float32{0, 1, 0}},
+				{PersonID: "001", Feature: []float32{1, 0, 0}},
+			}},
+			"002": {ID: "002", Name: "Bob", Features: []FaceFeature{
+				{PersonID: "002", Feature: []float32{0, 0, 1}},
+			}},
+			"004": {ID: "004", Name: "Dave"}, // No samples
+		},
+	}
+
+	results := fr.rankPersons([]float32{1, 0, 0}, 2)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 candidates, got %d", len(results))
+	}
+
+	// Alice and Charlie tie at 1.0; the tie is broken by person ID
+	if results[0].PersonID != "001" || results[1].PersonID != "003" {
+		t.Errorf("Expected candidates [001 003], got [%s %s]", results[0].PersonID, results[1].PersonID)
+	}
+
+	all := fr.rankPersons([]float32{1, 0, 0}, 10)
+	if len(all) != 3 {
+		t.Errorf("Expected 3 candidates (persons without samples excluded), got %d", len(all))
+	}
+	if all[2].PersonID != "002" {
+		t.Errorf("Expected lowest candidate 002, got %s", all[2].PersonID)
+	}
+}
+
+// Test: Distance metrics
+
+func TestVerifyFeatures_Euclidean(t *testing.T) {
+	fr := &FaceRecognizer{threshold: 0.5}
+	WithDistanceMetric(MetricEuclidean)(fr)
+
+	tests := []struct {
+		name        string
+		a           []float32
+		b           []float32
+		expectMatch bool
+	}{
+		{"Identical vectors", []float32{1, 2}, []float32{1, 2}, true},
+		{"Within max distance", []float32{0, 0}, []float32{0.25, 0}, true},
+		{"Beyond max distance", []float32{0, 0}, []float32{3, 4}, false},
+		{"Different length vectors", []float32{1, 0}, []float32{1, 0, 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, confidence := fr.VerifyFeatures(tt.a, tt.b)
+			if match != tt.expectMatch {
+				t.Errorf("Expected match=%v, got %v (confidence %.4f)", tt.expectMatch, match, confidence)
+			}
+			if confidence < 0 || confidence > 1 {
+				t.Errorf("Expected confidence in [0, 1], got %.4f", confidence)
+			}
+		})
+	}
+}
+
+func TestMatchPerson_Euclidean(t *testing.T) {
+	fr := &FaceRecognizer{
+		metric: MetricEuclidean,
+		persons: map[string]*Person{
+			"001": {ID: "001", Name: "Alice", Features: []FaceFeature{
+				{PersonID: "001", Feature: []float32{0, 0}},
+			}},
+			"002": {ID: "002", Name: "Bob", Features: []FaceFeature{
+				{PersonID: "002", Feature: []float32{3, 4}},
+			}},
+		},
+	}
+
+	personID, _, confidence := fr.matchPerson([]float32{2.5, 3.5})
+	if personID != "002" {
+		t.Errorf("Expected nearest person 002, got %s", personID)
+	}
+
+	expected := distanceToConfidence(euclideanDistance([]float32{2.5, 3.5}, []float32{3, 4}))
+	if confidence != expected {
+		t.Errorf("Expected confidence %.4f, got %.4f", expected, confidence)
+	}
+}
+
+func TestDistanceMetric_String(t *testing.T) {
+	if MetricCosine.String() != "cosine" {
+		t.Errorf("Expected 'cosine', got '%s'", MetricCosine.String())
+	}
+	if MetricEuclidean.String() != "euclidean" {
+		t.Errorf("Expected 'euclidean', got '%s'", MetricEuclidean.String())
+	}
+}
+
+func TestFeatureNormalization(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     FeatureNormalization
+		input    []float32
+		expected []float32
+	}{
+		{"L2", NormL2, []float32{3, 4}, []float32{0.6, 0.8}},
+		{"None", NormNone, []float32{3, 4}, []float32{3, 4}},
+		{"Mean std", NormMeanStd, []float32{1, 3}, []float32{-1, 1}},
+		{"Mean std constant", NormMeanStd, []float32{2, 2, 2}, []float32{0, 0, 0}},
+		{"Mean std empty", NormMeanStd, []float32{}, []float32{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feature := tt.mode.normalize(tt.input)
+			if len(feature) != len(tt.expected) {
+				t.Fatalf("Expected length %d, got %d", len(tt.expected), len(feature))
+			}
+			for i := range feature {
+				if math.Abs(float64(feature[i]-tt.expected[i])) > 1e-6 {
+					t.Errorf("Index %d: expected %.6f, got %.6f", i, tt.expected[i], feature[i])
+				}
+			}
+		})
+	}
+
+	if NormMeanStd.String() != "mean-std" {
+		t.Errorf("Expected 'mean-std', got '%s'", NormMeanStd.String())
+	}
+}
+
+func TestMatchPerson_Unnormalized(t *testing.T) {
+	persons := map[string]*Person{
+		"001": {ID: "001", Name: "Alice", Features: []FaceFeature{{PersonID: "001", Feature: []float32{3, 0}}}},
+		"002": {ID: "002", Name: "Bob", Features: []FaceFeature{{PersonID: "002", Feature: []float32{0, 0.5}}}},
+	}
+
+	tests := []struct {
+		name       string
+		metric     DistanceMetric
+		strategy   MatchStrategy
+		query      []float32
+		expectedID string
+		confidence float32
+	}{
+		// Cosine ignores magnitudes
+		{"Cosine", MetricCosine, StrategyMaxSample, []float32{2, 0}, "001", 1},
+		{"Cosine centroid", MetricCosine, StrategyCentroid, []float32{0, 4}, "002", 1},
+		// Euclidean compares raw magnitudes: distance 1 from Alice
+		{"Euclidean", MetricEuclidean, StrategyMaxSample, []float32{2, 0}, "001", distanceToConfidence(1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{persons: persons, metric: tt.metric, strategy: tt.strategy, normalization: NormNone}
+			id, _, confidence := fr.matchPerson(tt.query)
+			if id != tt.expectedID {
+				t.Fatalf("Expected %s, got %s", tt.expectedID, id)
+			}
+			if math.Abs(float64(confidence-tt.confidence)) > 1e-6 {
+				t.Errorf("Expected confidence %.4f, got %.4f", tt.confidence, confidence)
+			}
+		})
+	}
+}
+
+// Test: Match strategy
+
+func TestComputeCentroid(t *testing.T) {
+	tests := []struct {
+		name     string
+		features []FaceFeature
+		expected []float32
+	}{
+		{"No samples", nil, nil},
+		{"Single sample", []FaceFeature{{Feature: []float32{3, 4}}}, []float32{0.6, 0.8}},
+		{"Averaged samples", []FaceFeature{
+			{Feature: []float32{1, 0}},
+			{Feature: []float32{0, 1}},
+		}, []float32{float32(1 / math.Sqrt2), float32(1 / math.Sqrt2)}},
+		{"Mismatched dimension ignored", []FaceFeature{
+			{Feature: []float32{1, 0}},
+			{Feature: []float32{0, 1, 0}},
+		}, []float32{1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			centroid := computeCentroid(tt.features)
+			if len(centroid) != len(tt.expected) {
+				t.Fatalf("Expected length %d, got %d", len(tt.expected), len(centroid))
+			}
+			for i := range centroid {
+				if math.Abs(float64(centroid[i]-tt.expected[i])) > 1e-6 {
+					t.Errorf("Index %d: expected %.6f, got %.6f", i, tt.expected[i], centroid[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMatchPerson_CentroidStrategy(t *testing.T) {
+	fr := &FaceRecognizer{
+		persons: map[string]*Person{
+			"001": {ID: "001", Name: "Alice", Features: []FaceFeature{
+				{PersonID: "001", Feature: []float32{1, 0}},
+				{PersonID: "001", Feature: []float32{0, 1}},
+			}},
+			"002": {ID: "002", Name: "Bob", Features: []FaceFeature{
+				{PersonID: "002", Feature: []float32{0.8, 0.6}},
+			}},
+		},
+	}
+	query := []float32{1, 0}
+
+	// Max-sample: Alice has an exact sample
+	if personID, _, _ := fr.matchPerson(query); personID != "001" {
+		t.Errorf("Expected max-sample match 001, got %s", personID)
+	}
+
+	// Centroid: Alice's average points at 45 degrees, Bob is closer
+	WithMatchStrategy(StrategyCentroid)(fr)
+	if personID, _, _ := fr.matchPerson(query); personID != "002" {
+		t.Errorf("Expected centroid match 002, got %s", personID)
+	}
+}
+
+func TestCentroidCache_Invalidation(t *testing.T) {
+	fr := &FaceRecognizer{
+		strategy: StrategyCentroid,
+		persons: map[string]*Person{
+			"001": {ID: "001", Name: "Alice", Features: []FaceFeature{
+				{PersonID: "001", Feature: []float32{1, 0}},
+			}},
+		},
+	}
+	person := fr.persons["001"]
+
+	if c := fr.centroid(person); c[0] != 1 {
+		t.Fatalf("Expected cached centroid [1 0], got %v", c)
+	}
+
+	person.Features = append(person.Features, FaceFeature{PersonID: "001", Feature: []float32{-1, 0}})
+	person.Features = append(person.Features, FaceFeature{PersonID: "001", Feature: []float32{0, 1}})
+	if c := fr.centroid(person); c[0] != 1 {
+		t.Errorf("Expected stale cached centroid before invalidation, got %v", c)
+	}
+
+	fr.invalidateMatchCache("001")
+	if c := fr.centroid(person); c[0] != 0 || c[1] != 1 {
+		t.Errorf("Expected recomputed centroid [0 1], got %v", c)
+	}
+}
+
+// Test: Ambiguous matches
+
+func TestRecognizeFaces_MinConfidenceMargin(t *testing.T) {
+	persons := map[string]*Person{
+		"001": {ID: "001", Name: "Alice", Features: []FaceFeature{
+			{PersonID: "001", Feature: normalizeFeature([]float32{1, 0.10})},
+		}},
+		"002": {ID: "002", Name: "Twin", Features: []FaceFeature{
+			{PersonID: "002", Feature: normalizeFeature([]float32{1, -0.12})},
+		}},
+	}
+	faces := []extractedFace{{feature: []float32{1, 0}}}
+
+	tests := []struct {
+		name     string
+		margin   float32
+		expected string
+	}{
+		{"Disabled", 0, "001"},
+		{"Gap above margin", 0.0001, "001"},
+		{"Gap below margin", 0.05, "ambiguous"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{persons: persons, threshold: 0.6}
+			WithMinConfidenceMargin(tt.margin)(fr)
+
+			results, err := fr.recognizeFaces(context.Background(), faces)
+			if err != nil {
+				t.Fatalf("Failed to recognize: %v", err)
+			}
+			if results[0].PersonID != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, results[0].PersonID)
+			}
+		})
+	}
+}
+
+func TestRecognizeFaces_UnknownLabel(t *testing.T) {
+	persons := map[string]*Person{
+		"unknown": {ID: "unknown", Name: "Enrolled Unknown", Features: []FaceFeature{
+			{PersonID: "unknown", Feature: []float32{1, 0}},
+		}},
+	}
+	faces := []extractedFace{{feature: []float32{0, 1}}}
+
+	fr := &FaceRecognizer{persons: persons, threshold: 0.6}
+	WithUnknownLabel("?", "Desconocido")(fr)
+
+	results, err := fr.recognizeFaces(context.Background(), faces)
+	if err != nil {
+		t.Fatalf("Failed to recognize: %v", err)
+	}
+	if results[0].PersonID != "?" || results[0].PersonName != "Desconocido" {
+		t.Errorf("Expected custom unknown label, got %s/%s", results[0].PersonID, results[0].PersonName)
+	}
+}
+
+func TestScaleConfidence(t *testing.T) {
+	tests := []struct {
+		name       string
+		metric     DistanceMetric
+		scale      ConfidenceScale
+		similarity float32
+		expected   float32
+	}{
+		{"Raw cosine", MetricCosine, ScaleRaw, -0.5, -0.5},
+		{"Zero-one cosine", MetricCosine, ScaleZeroOne, -0.5, 0.25},
+		{"Percent cosine", MetricCosine, ScalePercent, 0.5, 75},
+		{"Clamped past 1", MetricCosine, ScaleZeroOne, 1.0001, 1},
+		{"Zero-one Euclidean", MetricEuclidean, ScaleZeroOne, 0.4, 0.4},
+		{"Percent Euclidean", MetricEuclidean, ScalePercent, 0.4, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{metric: tt.metric}
+			WithConfidenceScaling(tt.scale)(fr)
+
+			if got := fr.scaleConfidence(tt.similarity); math.Abs(float64(got-tt.expected)) > 1e-4 {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRecognizeFaces_ConfidenceScaling(t *testing.T) {
+	fr := &FaceRecognizer{
+		persons: map[string]*Person{
+			"001": {ID: "001", Name: "Alice", Features: []FaceFeature{
+				{PersonID: "001", Feature: []float32{1, 0}},
+			}},
+		},
+		threshold:   0.6,
+		unknownID:   DefaultUnknownID,
+		unknownName: DefaultUnknownName,
+	}
+	WithConfidenceScaling(ScalePercent)(fr)
+
+	faces := []extractedFace{
+		{feature: []float32{1, 0}},
+		{feature: normalizeFeature([]float32{1, 1.7320508})}, // Cosine 0.5
+	}
+	results, err := fr.recognizeFaces(context.Background(), faces)
+	if err != nil {
+		t.Fatalf("Failed to recognize: %v", err)
+	}
+
+	if results[0].PersonID != "001" || math.Abs(float64(results[0].Confidence-100)) > 1e-3 {
+		t.Errorf("Expected 001 at 100%%, got %s at %v", results[0].PersonID, results[0].Confidence)
+	}
+
+	// 75% is above 0.6, but the threshold applies to the raw cosine of 0.5
+	if results[1].PersonID != DefaultUnknownID || math.Abs(float64(results[1].Confidence-75)) > 1e-3 {
+		t.Errorf("Expected unknown at 75%%, got %s at %v", results[1].PersonID, results[1].Confidence)
+	}
+}
+
+func TestRecognizeFaces_ExtractionError(t *testing.T) {
+	fr := &FaceRecognizer{
+		persons: map[string]*Person{
+			"001": {ID: "001", Name: "Alice", Features: []FaceFeature{
+				{PersonID: "001", Feature: []float32{1, 0}},
+			}},
+		},
+		threshold: 0.6,
+	}
+
+	failed := image.Rect(10, 10, 50, 50)
+	faces := []extractedFace{
+		{Detection: Detection{Rect: failed, Quality: 7}, err: errors.New("encoder failed")},
+		{Detection: Detection{Rect: image.Rect(60, 10, 100, 50)}, feature: []float32{1, 0}},
+	}
+
+	results, err := fr.recognizeFaces(context.Background(), faces)
+	if err != nil {
+		t.Fatalf("Failed to recognize: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected a result for every face, got %d", len(results))
+	}
+
+	if results[0].PersonID != "error" || results[0].Error == nil {
+		t.Errorf("Expected error result for failed face, got %+v", results[0])
+	}
+	if results[0].BoundingBox != failed || results[0].DetectionQuality != 7 {
+		t.Errorf("Expected detection of failed face to be kept, got %+v", results[0])
+	}
+	if results[1].PersonID != "001" || results[1].Error != nil {
+		t.Errorf("Expected healthy face to match Alice, got %+v", results[1])
+	}
+}
+
+func TestRecognizeFaces_Hook(t *testing.T) {
+	var hooked []RecognizeResult
+	fr := &FaceRecognizer{
+		persons: map[string]*Person{
+			"001": {ID: "001", Name: "Alice", Features: []FaceFeature{
+				{PersonID: "001", Feature: []float32{1, 0}},
+			}},
+		},
+		threshold:   0.6,
+		unknownID:   DefaultUnknownID,
+		unknownName: DefaultUnknownName,
+		ordering:    OrderConfidence,
+	}
+	WithRecognizeHook(func(result RecognizeResult) {
+		hooked = append(hooked, result)
+	})(fr)
+
+	faces := []extractedFace{
+		{feature: []float32{0, 1}},
+		{err: errors.New("encoder failed")},
+		{feature: []float32{1, 0}},
+	}
+	results, err := fr.recognizeFaces(context.Background(), faces)
+	if err != nil {
+		t.Fatalf("Failed to recognize: %v", err)
+	}
+
+	// Called in detection order, before results are sorted
+	expected := []string{DefaultUnknownID, "error", "001"}
+	if len(hooked) != len(expected) {
+		t.Fatalf("Expected %d hook calls, got %d", len(expected), len(hooked))
+	}
+	for i, id := range expected {
+		if hooked[i].PersonID != id {
+			t.Errorf("Call %d: expected %s, got %s", i, id, hooked[i].PersonID)
+		}
+	}
+	if results[0].PersonID != "001" {
+		t.Errorf("Expected sorted results to start with 001, got %s", results[0].PersonID)
+	}
+}
+
+func TestSwapRB(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    ColorOrder
+		modelRGB bool
+		expected bool
+	}{
+		{"BGR input, RGB model", ColorOrderBGR, true, true},
+		{"BGR input, BGR model", ColorOrderBGR, false, false},
+		{"RGB input, RGB model", ColorOrderRGB, true, false},
+		{"RGB input, BGR model", ColorOrderRGB, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{}
+			WithColorOrder(tt.order)(fr)
+
+			if got := fr.swapRB(ModelConfig{SwapRB: tt.modelRGB}); got != tt.expected {
+				t.Errorf("Expected swap %v, got %v", tt.expected, got)
+			}
+
+			// Grayscale models never swap
+			if fr.swapRB(ModelConfig{SwapRB: tt.modelRGB, Channels: 1}) {
+				t.Error("Expected no swap for a grayscale model")
+			}
+		})
+	}
+}
+
+// openFaceGray is an OpenFace-like model taking grayscale input. SwapRB is
+// set to check that it is ignored.
+var openFaceGray = ModelConfig{
+	Type:        ModelCustom,
+	InputSize:   image.Pt(96, 96),
+	FeatureDim:  128,
+	ScaleFactor: 1.0 / 255.0,
+	SwapRB:      true,
+	Channels:    1,
+}
+
+func TestModelConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ModelConfig
+		wantErr bool
+	}{
+		{"Predefined", modelConfigs[ModelOpenFace], false},
+		{"Grayscale", openFaceGray, false},
+		{"Explicit color", ModelConfig{InputSize: image.Pt(96, 96), Channels: 3}, false},
+		{"Two channels", ModelConfig{InputSize: image.Pt(96, 96), Channels: 2}, true},
+		{"No input size", ModelConfig{Channels: 1}, true},
+		{"Raw features", ModelConfig{InputSize: image.Pt(96, 96), Normalization: NormNone}, false},
+		{"Unknown normalization", ModelConfig{InputSize: image.Pt(96, 96), Normalization: 7}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestResizeForModel_Grayscale(t *testing.T) {
+	// Red face stand-in: grayscale value depends on the color order
+	face := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(0, 0, 255, 0), 120, 100, gocv.MatTypeCV8UC3)
+	defer face.Close()
+
+	tests := []struct {
+		name     string
+		order    ColorOrder
+		expected uint8
+	}{
+		{"BGR input", ColorOrderBGR, 76}, // 0.299 * 255
+		{"RGB input", ColorOrderRGB, 29}, // 0.114 * 255
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{interpolation: gocv.InterpolationLinear}
+			WithColorOrder(tt.order)(fr)
+
+			resized := gocv.NewMat()
+			defer resized.Close()
+			if err := fr.resizeForModel(face, &resized, openFaceGray); err != nil {
+				t.Fatalf("Failed to resize: %v", err)
+			}
+			if resized.Channels() != 1 || resized.Cols() != 96 || resized.Rows() != 96 {
+				t.Fatalf("Expected a 96x96 single-channel input, got %dx%d with %d channels", resized.Cols(), resized.Rows(), resized.Channels())
+			}
+			if got := resized.GetUCharAt(48, 48); got < tt.expected-1 || got > tt.expected+1 {
+				t.Errorf("Expected gray value %d, got %d", tt.expected, got)
+			}
+
+			// Single-channel blob: (1, 1, H, W)
+			blob := gocv.BlobFromImage(resized, openFaceGray.ScaleFactor, openFaceGray.InputSize, openFaceGray.MeanValues, fr.swapRB(openFaceGray), false)
+			defer blob.Close()
+			if size := blob.Size(); len(size) != 4 || size[1] != 1 {
+				t.Errorf("Expected a single-channel blob, got shape %v", size)
+			}
+		})
+	}
+}
+
+func TestExtractFeature_ColorOrder(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	bgrRecognizer, err := NewFaceRecognizer(config)
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer bgrRecognizer.Close()
+
+	rgbRecognizer, err := NewFaceRecognizer(config, WithColorOrder(ColorOrderRGB))
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	defer rgbRecognizer.Close()
+
+	bgr := createTestImage(96, 96)
+	defer bgr.Close()
+	rgb := gocv.NewMat()
+	defer rgb.Close()
+	gocv.CvtColor(bgr, &rgb, gocv.ColorBGRToRGB)
+
+	// The same picture in either order yields the same feature
+	expected, err := bgrRecognizer.ExtractFeature(bgr)
+	if err != nil {
+		t.Fatalf("Failed to extract BGR feature: %v", err)
+	}
+	got, err := rgbRecognizer.ExtractFeature(rgb)
+	if err != nil {
+		t.Fatalf("Failed to extract RGB feature: %v", err)
+	}
+
+	if similarity := cosineSimilarity(expected, got); similarity < 0.999 {
+		t.Errorf("Expected identical features, got similarity %.4f", similarity)
+	}
+}
+
+func TestRecognizeFaces_ResultOrdering(t *testing.T) {
+	persons := map[string]*Person{
+		"001": {ID: "001", Name: "Alice", Features: []FaceFeature{{PersonID: "001", Feature: []float32{1, 0, 0}}}},
+		"002": {ID: "002", Name: "Bob", Features: []FaceFeature{{PersonID: "002", Feature: []float32{0, 1, 0}}}},
+	}
+
+	// Faces in detection order, identified by the x offset of their box
+	faces := []extractedFace{
+		{Detection: Detection{Rect: image.Rect(0, 0, 10, 10)}, err: errors.New("encoder failed")},
+		{Detection: Detection{Rect: image.Rect(10, 0, 20, 10)}, feature: normalizeFeature([]float32{0.3, 0.3, 0.9})},
+		{Detection: Detection{Rect: image.Rect(20, 0, 30, 10)}, feature: normalizeFeature([]float32{0, 0.7, 0.5})},
+		{Detection: Detection{Rect: image.Rect(30, 0, 40, 10)}, feature: []float32{1, 0, 0}},
+	}
+
+	tests := []struct {
+		name     string
+		ordering ResultOrdering
+		expected []int
+	}{
+		{"Detection order", OrderDetection, []int{0, 10, 20, 30}},
+		{"Known first by confidence", OrderConfidence, []int{30, 20, 10, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{persons: persons, threshold: 0.6, unknownID: DefaultUnknownID}
+			WithResultOrdering(tt.ordering)(fr)
+
+			results, err := fr.recognizeFaces(context.Background(), faces)
+			if err != nil {
+				t.Fatalf("Failed to recognize: %v", err)
+			}
+
+			got := make([]int, len(results))
+			for i, result := range results {
+				got[i] = result.BoundingBox.Min.X
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected faces %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBestMatch_RunnerUp(t *testing.T) {
+	persons := make(map[string]*Person)
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("%03d", i)
+		persons[id] = &Person{ID: id, Name: id, Features: []FaceFeature{
+			{PersonID: id, Feature: normalizeFeature([]float32{1, float32(i) / 10})},
+		}}
+	}
+	query := []float32{1, 0}
+	expectedRunnerUp := dotProduct(query, persons["001"].Features[0].Feature)
+
+	for _, workers := range []int{1, 4} {
+		fr := &FaceRecognizer{persons: persons}
+		WithMatchConcurrency(workers)(fr)
+
+		match := fr.bestMatch(query)
+		if match.personID != "000" {
+			t.Errorf("%d workers: expected best 000, got %s", workers, match.personID)
+		}
+		if match.runnerUp != expectedRunnerUp {
+			t.Errorf("%d workers: expected runner-up %.6f, got %.6f", workers, expectedRunnerUp, match.runnerUp)
+		}
+	}
+}
+
+// Test: Parallel matching
+
+func TestMatchPerson_Concurrency(t *testing.T) {
+	persons := make(map[string]*Person)
+	for i := 0; i < 300; i++ {
+		id := fmt.Sprintf("%03d", i)
+		feature := []float32{float32(i % 7), 1}
+		persons[id] = &Person{ID: id, Name: "Person " + id, Features: []FaceFeature{
+			{PersonID: id, Feature: normalizeFeature(feature)},
+		}}
+	}
+	query := normalizeFeature([]float32{6, 1})
+
+	serial := &FaceRecognizer{persons: persons}
+	expectedID, _, expectedConfidence := serial.matchPerson(query)
+	if expectedID != "006" {
+		t.Fatalf("Expected lowest tied person ID 006, got %s", expectedID)
+	}
+
+	for _, workers := range []int{0, 2, 3, 4, 16} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			fr := &FaceRecognizer{persons: persons}
+			WithMatchConcurrency(workers)(fr)
+
+			for run := 0; run < 5; run++ {
+				personID, _, confidence := fr.matchPerson(query)
+				if personID != expectedID || confidence != expectedConfidence {
+					t.Fatalf("Expected %s (%.4f), got %s (%.4f)", expectedID, expectedConfidence, personID, confidence)
+				}
+			}
+		})
+	}
+}
+
+// Test: Concurrency (run with -race)
+
+func TestConcurrentMatchingAndUpdates(t *testing.T) {
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: NewMemoryStorage(), threshold: 0.6}
+	WithMatchConcurrency(4)(fr)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				id := fmt.Sprintf("%d-%d", g, i)
+				fr.AddPerson(id, "Person "+id)
+				fr.UpdatePerson(id, "Renamed "+id)
+				fr.matchPerson([]float32{1, 0})
+				fr.VerifyFeatures([]float32{1, 0}, []float32{0, 1})
+				fr.SetThreshold(0.5 + float32(i%2)/10)
+				fr.ListPersons()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if len(fr.ListPersons()) != 400 {
+		t.Errorf("Expected 400 persons, got %d", len(fr.ListPersons()))
+	}
+}
+
+func TestRecognize_Concurrent(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config)
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	face := createTestImage(200, 200)
+	defer face.Close()
+
+	expected, err := recognizer.ExtractFeature(face)
+	if err != nil {
+		t.Fatalf("Failed to extract feature: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			img := createTestImage(640, 480)
+			defer img.Close()
+
+			for i := 0; i < 5; i++ {
+				if _, err := recognizer.Recognize(img); err != nil {
+					errs <- err
+					return
+				}
+
+				// Concurrent inference must not corrupt the output
+				feature, err := recognizer.ExtractFeature(face)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if cosineSimilarity(feature, expected) < 0.9999 {
+					errs <- errors.New("feature changed under concurrent inference")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// Test: Storage write-through
+
+// failingStorage is a FaceStorage whose writes always fail
+type failingStorage struct {
+	*MemoryStorage
+}
+
+func (s *failingStorage) SavePerson(person *Person) error {
+	return errors.New("storage unavailable")
+}
+
+func (s *failingStorage) DeletePerson(id string) error {
+	return errors.New("storage unavailable")
+}
+
+func TestAutoPersist_WriteThrough(t *testing.T) {
+	storage := NewMemoryStorage()
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(true)(fr)
+
+	if err := fr.AddPerson("001", "Alice"); err != nil {
+		t.Fatalf("Failed to add person: %v", err)
+	}
+	if exists, _ := storage.PersonExists("001"); !exists {
+		t.Error("Expected person to be written through to storage")
+	}
+
+	if err := fr.RemovePerson("001"); err != nil {
+		t.Fatalf("Failed to remove person: %v", err)
+	}
+	if exists, _ := storage.PersonExists("001"); exists {
+		t.Error("Expected person to be deleted from storage")
+	}
+}
+
+func TestAutoPersist_Disabled(t *testing.T) {
+	storage := NewMemoryStorage()
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(false)(fr)
+
+	if err := fr.AddPerson("001", "Alice"); err != nil {
+		t.Fatalf("Failed to add person: %v", err)
+	}
+	if exists, _ := storage.PersonExists("001"); exists {
+		t.Error("Expected storage to be untouched when auto-persist is disabled")
+	}
+}
+
+func TestSaveDirty(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(false)(fr)
+
+	fr.AddPerson("001", "Alice")
+	fr.AddPerson("002", "Bob")
+	if err := fr.SaveDirty(); err != nil {
+		t.Fatalf("SaveDirty failed: %v", err)
+	}
+	for _, id := range []string{"001", "002"} {
+		if exists, _ := storage.PersonExists(id); !exists {
+			t.Errorf("Expected person %s to be saved", id)
+		}
+	}
+
+	// Only the changed person is rewritten
+	os.Remove(filepath.Join(dir, "002.json"))
+	fr.UpdatePerson("001", "Alicia")
+	if err := fr.SaveDirty(); err != nil {
+		t.Fatalf("SaveDirty failed: %v", err)
+	}
+	if person, _ := storage.LoadPerson("001"); person == nil || person.Name != "Alicia" {
+		t.Errorf("Expected renamed person to be saved, got %+v", person)
+	}
+	if exists, _ := storage.PersonExists("002"); exists {
+		t.Error("Expected unchanged person not to be rewritten")
+	}
+
+	// Removed persons are deleted
+	fr.RemovePerson("001")
+	if err := fr.SaveDirty(); err != nil {
+		t.Fatalf("SaveDirty failed: %v", err)
+	}
+	if exists, _ := storage.PersonExists("001"); exists {
+		t.Error("Expected removed person to be deleted")
+	}
+
+	// Failed writes stay pending for the next call
+	fr.UpdatePerson("002", "Robert")
+	fr.storage = &failingStorage{NewMemoryStorage()}
+	if err := fr.SaveDirty(); err == nil {
+		t.Error("Expected error when storage write fails")
+	}
+	fr.storage = storage
+	if err := fr.SaveDirty(); err != nil {
+		t.Fatalf("SaveDirty failed: %v", err)
+	}
+	if person, _ := storage.LoadPerson("002"); person == nil || person.Name != "Robert" {
+		t.Errorf("Expected pending person to be saved on retry, got %+v", person)
+	}
+}
+
+func TestSyncToStorage(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.SavePerson(&Person{ID: "002", Name: "Bob (stale)"})
+	storage.SavePerson(&Person{ID: "003", Name: "Carol"})
+
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(false)(fr)
+	fr.AddPerson("001", "Alice")
+	fr.AddPerson("002", "Bob")
+
+	// A failed sync keeps the pending changes
+	fr.storage = &failingStorage{NewMemoryStorage()}
+	if err := fr.SyncToStorage(); err == nil {
+		t.Error("Expected error when storage write fails")
+	}
+	if len(fr.dirty) != 2 {
+		t.Errorf("Expected 2 pending changes after a failed sync, got %d", len(fr.dirty))
+	}
+	fr.storage = storage
+
+	if err := fr.SyncToStorage(); err != nil {
+		t.Fatalf("SyncToStorage failed: %v", err)
+	}
+	if person, _ := storage.LoadPerson("002"); person == nil || person.Name != "Bob" {
+		t.Errorf("Expected stored person to be overwritten, got %+v", person)
+	}
+	if exists, _ := storage.PersonExists("001"); !exists {
+		t.Error("Expected in-memory person to be written")
+	}
+	if exists, _ := storage.PersonExists("003"); exists {
+		t.Error("Expected person missing from memory to be deleted")
+	}
+	if len(fr.dirty) != 0 {
+		t.Errorf("Expected no pending changes after sync, got %d", len(fr.dirty))
+	}
+}
+
+func TestSyncFromStorage(t *testing.T) {
+	storage := NewMemoryStorage()
+	storage.SavePerson(&Person{ID: "001", Name: "Alice", Features: []FaceFeature{
+		{PersonID: "001", Feature: []float32{1, 0}},
+	}})
+
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(false)(fr)
+	fr.AddPerson("002", "Bob")
+
+	if err := fr.SyncFromStorage(); err != nil {
+		t.Fatalf("SyncFromStorage failed: %v", err)
+	}
+	if fr.HasPerson("002") {
+		t.Error("Expected unsaved in-memory person to be discarded")
+	}
+	if len(fr.dirty) != 0 {
+		t.Errorf("Expected no pending changes, got %d", len(fr.dirty))
+	}
+	if id, _, _ := fr.matchPerson([]float32{1, 0}); id != "001" {
+		t.Errorf("Expected stored person to be matched, got %q", id)
+	}
+}
+
+func TestAutoPersist_FailuresSurface(t *testing.T) {
+	storage := &failingStorage{NewMemoryStorage()}
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(true)(fr)
+
+	if err := fr.AddPerson("001", "Alice"); err == nil {
+		t.Error("Expected error when storage write fails")
+	}
+	if _, err := fr.GetPerson("001"); err == nil {
+		t.Error("Expected in-memory add to be rolled back")
+	}
+
+	// Person already present in storage, but deletes fail
+	storage.MemoryStorage.SavePerson(&Person{ID: "002", Name: "Bob"})
+	fr.persons["002"] = &Person{ID: "002", Name: "Bob"}
+
+	if err := fr.RemovePerson("002"); err == nil {
+		t.Error("Expected error when storage delete fails")
+	}
+	if _, err := fr.GetPerson("002"); err != nil {
+		t.Error("Expected in-memory removal to be rolled back")
+	}
+}
+
+func TestDetectAndCrop(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config)
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	empty := gocv.NewMat()
+	defer empty.Close()
+	if _, _, err := recognizer.DetectAndCrop(empty); err == nil {
+		t.Error("Expected error for empty image")
+	}
+
+	img := createTestImage(640, 480)
+	crops, boxes, err := recognizer.DetectAndCrop(img)
+	if err != nil {
+		t.Fatalf("DetectAndCrop failed: %v", err)
+	}
+	img.Close() // Crops are copies and outlive the image
+
+	if len(crops) != len(boxes) {
+		t.Fatalf("Expected one box per crop, got %d crops and %d boxes", len(crops), len(boxes))
+	}
+	for i, crop := range crops {
+		if crop.Cols() != boxes[i].Dx() || crop.Rows() != boxes[i].Dy() {
+			t.Errorf("Crop %d is %dx%d, expected box size %v", i, crop.Cols(), crop.Rows(), boxes[i].Size())
+		}
+		crop.Close()
+	}
+}
+
+func TestDetectionOnly_ErrNoEncoder(t *testing.T) {
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: NewMemoryStorage(), noEncoder: true}
+	fr.AddPerson("001", "Alice")
+
+	img := gocv.NewMatWithSize(120, 120, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	calls := map[string]func() error{
+		"ExtractFeature": func() error { _, err := fr.ExtractFeature(img); return err },
+		"ExtractFeatures": func() error {
+			_, err := fr.ExtractFeatures([]gocv.Mat{img})
+			return err
+		},
+		"Warmup":        fr.Warmup,
+		"Recognize":     func() error { _, err := fr.Recognize(img); return err },
+		"RecognizeTopK": func() error { _, err := fr.RecognizeTopK(img, 3); return err },
+		"RecognizeRegions": func() error {
+			_, err := fr.RecognizeRegions(img, []image.Rectangle{image.Rect(0, 0, 60, 60)})
+			return err
+		},
+		"AddFaceSample": func() error { return fr.AddFaceSample("001", img) },
+		"AddFaceSamples": func() error {
+			_, errs := fr.AddFaceSamples("001", []gocv.Mat{img})
+			return errors.Join(errs...)
+		},
+		"Verify":        func() error { _, _, err := fr.Verify(img, img); return err },
+		"VerifyAgainst": func() error { _, _, err := fr.VerifyAgainst("001", img); return err },
+	}
+
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrNoEncoder) {
+			t.Errorf("%s: expected ErrNoEncoder, got %v", name, err)
+		}
+	}
+
+	if err := fr.Close(); err != nil {
+		t.Errorf("Expected Close to succeed without an encoder, got %v", err)
+	}
+}
+
+func TestNewFaceDetector(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	detector, err := NewFaceDetector("./testdata/facefinder", WithPigoParams(PigoParams{MinSize: 20, MaxSize: 500, ShiftFactor: 0.1, ScaleFactor: 1.1, QualityThreshold: 5}))
+	if err != nil {
+		t.Fatalf("Failed to create detector: %v", err)
+	}
+	defer detector.Close()
+
+	if faces := detector.DetectFaces(image.NewGray(image.Rect(0, 0, 100, 100))); len(faces) != 0 {
+		t.Errorf("Expected no faces in a blank image, got %d", len(faces))
+	}
+
+	img := gocv.NewMatWithSize(100, 100, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	if _, err := detector.Recognize(img); !errors.Is(err, ErrNoEncoder) {
+		t.Errorf("Expected ErrNoEncoder, got %v", err)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config)
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	// Safe to repeat
+	for i := 0; i < 2; i++ {
+		if err := recognizer.Warmup(); err != nil {
+			t.Fatalf("Warmup failed: %v", err)
+		}
+	}
+}
+
+func TestClose_FlushesStorage(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	path := filepath.Join(t.TempDir(), "faces.json")
+	storage, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create JSON storage: %v", err)
+	}
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config, WithStorage(storage))
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+
+	if err := recognizer.AddPerson("001", "Alice"); err != nil {
+		t.Fatalf("Failed to add person: %v", err)
+	}
+
+	// Remove the file so only the final save in Close can recreate it
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Failed to remove database file: %v", err)
+	}
+
+	if err := recognizer.Close(); err != nil {
+		t.Fatalf("Failed to close recognizer: %v", err)
+	}
+
+	reopened, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen JSON storage: %v", err)
+	}
+	if exists, _ := reopened.PersonExists("001"); !exists {
+		t.Error("Expected person to be flushed to disk on Close")
+	}
+}
+
+func TestClearAll(t *testing.T) {
+	storage := NewMemoryStorage()
+	fr := &FaceRecognizer{persons: make(map[string]*Person), storage: storage}
+	WithAutoPersist(true)(fr)
+
+	for _, id := range []string{"001", "002"} {
+		if err := fr.AddPerson(id, "Person "+id); err != nil {
+			t.Fatalf("Failed to add person: %v", err)
+		}
+	}
+
+	if err := fr.ClearAll(); err != nil {
+		t.Fatalf("Failed to clear: %v", err)
+	}
+	if persons := fr.ListPersons(); len(persons) != 0 {
+		t.Errorf("Expected no persons in memory, got %d", len(persons))
+	}
+	if persons, _ := storage.LoadAllPersons(); len(persons) != 0 {
+		t.Errorf("Expected no persons in storage, got %d", len(persons))
+	}
+
+	// IDs can be enrolled again
+	if err := fr.AddPerson("001", "Alice"); err != nil {
+		t.Errorf("Failed to re-add person after clear: %v", err)
+	}
+}
+
+// Test: Context cancellation
+
+func TestCheckFaceCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max int
+		count    int
+		expected error
+	}{
+		{"Unbounded", 0, 0, 7, nil},
+		{"Exactly one", 1, 1, 1, nil},
+		{"No face", 1, 1, 0, ErrTooFewFaces},
+		{"Two faces", 1, 1, 2, ErrTooManyFaces},
+		{"Negative min is unbounded", -1, 3, 0, nil},
+		{"At most three", 0, 3, 4, ErrTooManyFaces},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{}
+			WithFaceCountBounds(tt.min, tt.max)(fr)
+
+			err := fr.checkFaceCount(tt.count)
+			if tt.expected == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.expected != nil && !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestAddFaceSampleContext_Cancelled(t *testing.T) {
+	fr := &FaceRecognizer{persons: map[string]*Person{"001": {ID: "001", Name: "Alice"}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := fr.AddFaceSampleContext(ctx, "001", gocv.NewMat())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestAddFaceSampleFromFile_Missing(t *testing.T) {
+	fr := &FaceRecognizer{persons: map[string]*Person{"001": {ID: "001", Name: "Alice"}}}
+
+	if err := fr.AddFaceSampleFromFile("001", t.TempDir()+"/missing.jpg"); err == nil {
+		t.Error("Expected error for a missing image file")
+	}
+	if count, _ := fr.GetSampleCount("001"); count != 0 {
+		t.Errorf("Expected person to keep 0 samples, got %d", count)
+	}
+}
+
+func TestAddFaceSamples_PartialFailures(t *testing.T) {
+	fr := &FaceRecognizer{persons: map[string]*Person{"001": {ID: "001", Name: "Alice"}}}
+
+	added, errs := fr.AddFaceSamples("999", []gocv.Mat{gocv.NewMat()})
+	if added != 0 || len(errs) != 1 || !errors.Is(errs[0], ErrPersonNotFound) {
+		t.Errorf("Expected missing person error, got %d added, %v", added, errs)
+	}
+
+	// Empty images fail detection; each error names the image index
+	imgs := []gocv.Mat{gocv.NewMat(), gocv.NewMat()}
+	defer imgs[0].Close()
+	defer imgs[1].Close()
+
+	added, errs = fr.AddFaceSamples("001", imgs)
+	if added != 0 {
+		t.Errorf("Expected no samples added, got %d", added)
+	}
+	if len(errs) != 2 || !strings.HasPrefix(errs[1].Error(), "image 1:") {
+		t.Errorf("Expected one indexed error per image, got %v", errs)
+	}
+	if count, _ := fr.GetSampleCount("001"); count != 0 {
+		t.Errorf("Expected person to keep 0 samples, got %d", count)
+	}
+}
+
+// Test: Enrollment quality
+
+func TestRetainSamples(t *testing.T) {
+	sample := func(x, y float32) FaceFeature {
+		return FaceFeature{PersonID: "001", Feature: normalizeFeature([]float32{x, y})}
+	}
+	// The third sample sits between the others, closest to the centroid
+	features := []FaceFeature{sample(1, 0), sample(0, 1), sample(1, 1), sample(1, -0.2)}
+
+	tests := []struct {
+		name      string
+		max       int
+		retention RetentionStrategy
+		expected  []int // Indices into features that are kept
+	}{
+		{"Unlimited", 0, RetainNewest, []int{0, 1, 2, 3}},
+		{"Under limit", 4, RetainMostRepresentative, []int{0, 1, 2, 3}},
+		{"Newest", 2, RetainNewest, []int{2, 3}},
+		{"Most representative", 3, RetainMostRepresentative, []int{0, 1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{}
+			WithMaxSamplesPerPerson(tt.max)(fr)
+			WithRetentionStrategy(tt.retention)(fr)
+
+			got := fr.retainSamples(append([]FaceFeature(nil), features...))
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected %d samples, got %d", len(tt.expected), len(got))
+			}
+			for i, idx := range tt.expected {
+				if !reflect.DeepEqual(got[i].Feature, features[idx].Feature) {
+					t.Errorf("Sample %d: expected original sample %d, got %v", i, idx, got[i].Feature)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckEnrollmentQuality(t *testing.T) {
+	// Sharp face stand-in: a high-contrast checkerboard
+	sharp := gocv.NewMatWithSize(100, 100, gocv.MatTypeCV8UC3)
+	defer sharp.Close()
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if (x/5+y/5)%2 == 0 {
+				for c := 0; c < 3; c++ {
+					sharp.SetUCharAt(y, x*3+c, 255)
+				}
+			}
+		}
+	}
+
+	// The same pattern, heavily blurred
+	blurred := gocv.NewMat()
+	defer blurred.Close()
+	gocv.GaussianBlur(sharp, &blurred, image.Pt(31, 31), 0, 0, gocv.BorderDefault)
+
+	tests := []struct {
+		name         string
+		face         gocv.Mat
+		minSharpness float64
+		minPixels    int
+		expectReject bool
+	}{
+		{"Disabled", blurred, 0, 0, false},
+		{"Sharp face passes", sharp, 100, 80, false},
+		{"Blurred face rejected", blurred, 100, 0, true},
+		{"Small face rejected", sharp, 0, 120, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{}
+			WithEnrollmentQuality(tt.minSharpness, tt.minPixels)(fr)
+
+			err := fr.checkEnrollmentQuality(tt.face)
+			if tt.expectReject && !errors.Is(err, ErrLowQualityFace) {
+				t.Errorf("Expected ErrLowQualityFace, got %v", err)
+			}
+			if !tt.expectReject && err != nil {
+				t.Errorf("Expected face to pass, got %v", err)
+			}
+		})
+	}
+}
+
+// Test: Face alignment
+
+func TestAlignFace(t *testing.T) {
+	img := createTestImage(120, 100)
+	defer img.Close()
+
+	// Tilted eyes: output keeps the input size
+	aligned := AlignFace(img, image.Pt(30, 40), image.Pt(90, 60))
+	defer aligned.Close()
+
+	if aligned.Cols() != img.Cols() || aligned.Rows() != img.Rows() {
+		t.Errorf("Expected %dx%d aligned image, got %dx%d",
+			img.Cols(), img.Rows(), aligned.Cols(), aligned.Rows())
+	}
+
+	// Level eyes: alignment is the identity
+	level := AlignFace(img, image.Pt(30, 40), image.Pt(90, 40))
+	defer level.Close()
+
+	if level.GetUCharAt(50, 60*3) != img.GetUCharAt(50, 60*3) {
+		t.Error("Expected level eyes to leave the image unchanged")
+	}
+}
+
+func TestNewFaceRecognizer_AlignmentRequiresPuploc(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config, WithAlignment(true))
+	if err == nil {
+		recognizer.Close()
+		t.Error("Expected error when alignment is enabled without a puploc cascade")
+	}
+}
+
+func TestNewFaceRecognizer_FlplocRequiresPuploc(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+		FlplocCascadeDir: "./testdata/lps",
+	}
+
+	recognizer, err := NewFaceRecognizer(config)
+	if err == nil {
+		recognizer.Close()
+		t.Error("Expected error when landmark cascades are set without a puploc cascade")
+	}
+}
+
+func TestLoadFlplocCascades_Missing(t *testing.T) {
+	_, err := loadFlplocCascades(t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), flplocPoints[0].cascade) {
+		t.Errorf("Expected error naming cascade %s, got %v", flplocPoints[0].cascade, err)
+	}
+}
+
+func TestDetectFacesWithLandmarks_WithoutLocalizers(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	recognizer, err := NewFaceDetector("./testdata/facefinder")
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	testImg := createTestImage(640, 480)
+	defer testImg.Close()
+
+	goImg, err := testImg.ToImage()
+	if err != nil {
+		t.Fatalf("Failed to convert image: %v", err)
+	}
+
+	// Without localizers every face is kept with zero landmarks
+	boxes := recognizer.DetectFaces(goImg)
+	faces := recognizer.DetectFacesWithLandmarks(goImg)
+	if len(faces) != len(boxes) {
+		t.Fatalf("Expected %d faces, got %d", len(boxes), len(faces))
+	}
+	for i, face := range faces {
+		if face.Rect != boxes[i] {
+			t.Errorf("Face %d: expected box %v, got %v", i, boxes[i], face.Rect)
+		}
+		if face.LeftPupil != (image.Point{}) || face.RightPupil != (image.Point{}) || face.Points != nil {
+			t.Errorf("Face %d: expected zero landmarks, got %+v", i, face)
+		}
+	}
+}
+
+// Test: Batch extraction
+
+func TestExtractFeatures_EmptyInput(t *testing.T) {
+	fr := &FaceRecognizer{}
+
+	features, err := fr.ExtractFeatures(nil)
+	if err != nil || len(features) != 0 {
+		t.Errorf("Expected no features and no error for empty batch, got %d, %v", len(features), err)
+	}
+
+	valid := createTestImage(100, 100)
+	defer valid.Close()
+	empty := gocv.NewMat()
+	defer empty.Close()
+
+	if _, err := fr.ExtractFeatures([]gocv.Mat{valid, empty}); err == nil {
+		t.Error("Expected error for empty image in batch")
+	}
+}
+
+func TestExtractFeatures_MatchesSingle(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config)
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	imgA := createTestImage(200, 200)
+	defer imgA.Close()
+	imgB := createTestImage(150, 180)
+	defer imgB.Close()
+
+	batch, err := recognizer.ExtractFeatures([]gocv.Mat{imgA, imgB})
+	if err != nil {
+		t.Fatalf("Batch extraction failed: %v", err)
+	}
+	if len(batch) != 2 {
+		t.Fatalf("Expected 2 features, got %d", len(batch))
+	}
+
+	for i, img := range []gocv.Mat{imgA, imgB} {
+		single, err := recognizer.ExtractFeature(img)
+		if err != nil {
+			t.Fatalf("Single extraction failed: %v", err)
+		}
+		if similarity := cosineSimilarity(single, batch[i]); similarity < 0.999 {
+			t.Errorf("Face %d: batch feature differs from single (similarity %.4f)", i, similarity)
+		}
+	}
+}
+
+// Test: Mat detection
+
+func TestDetectFacesFromMat(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config)
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	empty := gocv.NewMat()
+	defer empty.Close()
+	if faces := recognizer.DetectFacesFromMat(empty); faces != nil {
+		t.Errorf("Expected nil for empty Mat, got %v", faces)
+	}
+
+	testImg := createTestImage(640, 480)
+	defer testImg.Close()
+
+	goImg, err := testImg.ToImage()
+	if err != nil {
+		t.Fatalf("Failed to convert image: %v", err)
+	}
+
+	fromImage := recognizer.DetectFaces(goImg)
+	fromMat := recognizer.DetectFacesFromMat(testImg)
+	if len(fromImage) != len(fromMat) {
+		t.Errorf("Expected %d faces from Mat path, got %d", len(fromImage), len(fromMat))
+	}
+}
+
+// Test: Rotated detection
+
+func TestGrayscaleConversion(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{G: 255, A: 255})
+	img.Set(1, 0, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	tests := []struct {
+		name      string
+		converter GrayscaleConverter
+		green     uint8
+	}{
+		{"Default is BT.601", nil, 150},
+		{"BT.601", GrayscaleBT601, 150},
+		{"BT.709", GrayscaleBT709, 183},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{}
+			WithGrayscaleMethod(tt.converter)(fr)
 
-			if math.Abs(float64(result-tt.expected)) > float64(tt.tolerance) {
-				t.Errorf("Expected %.4f, got %.4f", tt.expected, result)
+			pixels, width, height := fr.toGrayscale(img)
+			if width != 2 || height != 1 {
+				t.Fatalf("Expected 2x1, got %dx%d", width, height)
+			}
+			if pixels[0] != tt.green {
+				t.Errorf("Expected pure green to map to %d, got %d", tt.green, pixels[0])
+			}
+			if pixels[1] != 255 {
+				t.Errorf("Expected white to map to 255, got %d", pixels[1])
 			}
 		})
 	}
 }
 
-func TestNormalizeFeature(t *testing.T) {
+func TestGrayscaleConversion_GrayInput(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 4, 3))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 10)
+	}
+
+	fr := &FaceRecognizer{}
+	WithGrayscaleMethod(GrayscaleBT709)(fr)
+
+	pixels, width, height := fr.toGrayscale(gray)
+	if width != 4 || height != 3 {
+		t.Fatalf("Expected 4x3, got %dx%d", width, height)
+	}
+	if &pixels[0] != &gray.Pix[0] {
+		t.Error("Expected grayscale input to be used without conversion")
+	}
+
+	// A sub-image does not start at the origin and is converted instead
+	sub := gray.SubImage(image.Rect(1, 1, 3, 3)).(*image.Gray)
+	pixels, _, _ = fr.toGrayscale(sub)
+	if &pixels[0] == &gray.Pix[0] {
+		t.Error("Expected sub-image to be converted into a new buffer")
+	}
+}
+
+func TestWithDetectionAngle(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxAngle float64
+		expected []float64
+	}{
+		{"Upright only", 0, []float64{0}},
+		{"Single step", 15, []float64{0, -15, 15}},
+		{"Full sweep", 45, []float64{0, -15, 15, -30, 30, -45, 45}},
+		{"Clamped", 90, []float64{0, -15, 15, -30, 30, -45, 45}},
+		{"Off-step maximum", 20, []float64{0, -15, 15, -20, 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{}
+			WithDetectionAngle(tt.maxAngle)(fr)
+
+			if len(fr.angles) != len(tt.expected) {
+				t.Fatalf("Expected angles %v, got %v", tt.expected, fr.angles)
+			}
+			for i := range tt.expected {
+				if fr.angles[i] != tt.expected[i] {
+					t.Errorf("Expected angles %v, got %v", tt.expected, fr.angles)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWithClusterThreshold(t *testing.T) {
 	tests := []struct {
 		name      string
-		input     []float32
-		expectLen float32 // Expected L2 norm (should be 1.0 after normalization)
+		threshold float64
+		expected  float64
 	}{
-		{
-			name:      "Simple vector",
-			input:     []float32{3, 4},
-			expectLen: 1.0,
-		},
-		{
-			name:      "Already normalized",
-			input:     []float32{1, 0, 0},
-			expectLen: 1.0,
-		},
-		{
-			name:      "Multi-dimensional",
-			input:     []float32{1, 2, 3, 4},
-			expectLen: 1.0,
-		},
-		{
-			name:      "Negative values",
-			input:     []float32{-3, 4},
-			expectLen: 1.0,
-		},
-		{
-			name:      "Zero vector",
-			input:     []float32{0, 0, 0},
-			expectLen: 0.0,
-		},
+		{"In range", 0.35, 0.35},
+		{"Zero keeps the default", 0, defaultClusterThreshold},
+		{"Out of range left for validation", 1.5, 1.5},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			normalized := normalizeFeature(tt.input)
+			fr := &FaceRecognizer{}
+			WithClusterThreshold(tt.threshold)(fr)
+			if fr.pigoParams.ClusterThreshold != tt.expected {
+				t.Errorf("Expected %.2f, got %.2f", tt.expected, fr.pigoParams.ClusterThreshold)
+			}
+		})
+	}
 
-			// Calculate L2 norm
-			var sumSquares float32
-			for _, v := range normalized {
-				sumSquares += v * v
+	// WithPigoParams without a cluster threshold keeps the default
+	fr := &FaceRecognizer{}
+	WithPigoParams(PigoParams{MinSize: 50, MaxSize: 500})(fr)
+	if fr.pigoParams.ClusterThreshold != defaultClusterThreshold {
+		t.Errorf("Expected default %.2f, got %.2f", defaultClusterThreshold, fr.pigoParams.ClusterThreshold)
+	}
+}
+
+func TestPigoParams_Validate(t *testing.T) {
+	valid := PigoParams{MinSize: 100, MaxSize: 1000, ShiftFactor: 0.1, ScaleFactor: 1.1, QualityThreshold: 5}
+
+	tests := []struct {
+		name    string
+		modify  func(p *PigoParams)
+		errText string
+	}{
+		{"Valid", func(p *PigoParams) {}, ""},
+		{"Shift factor of 1", func(p *PigoParams) { p.ShiftFactor = 1 }, ""},
+		{"Zero quality threshold", func(p *PigoParams) { p.QualityThreshold = 0 }, ""},
+		{"Scale factor of 1", func(p *PigoParams) { p.ScaleFactor = 1 }, "scale factor"},
+		{"Scale factor below 1", func(p *PigoParams) { p.ScaleFactor = 0.9 }, "scale factor"},
+		{"Scale factor NaN", func(p *PigoParams) { p.ScaleFactor = math.NaN() }, "scale factor"},
+		{"Zero shift factor", func(p *PigoParams) { p.ShiftFactor = 0 }, "shift factor"},
+		{"Shift factor above 1", func(p *PigoParams) { p.ShiftFactor = 1.5 }, "shift factor"},
+		{"Zero min size", func(p *PigoParams) { p.MinSize = 0 }, "minimum face size"},
+		{"Min size equals max size", func(p *PigoParams) { p.MaxSize = p.MinSize }, "less than maximum"},
+		{"Negative quality threshold", func(p *PigoParams) { p.QualityThreshold = -1 }, "quality threshold"},
+		{"Cluster threshold near 1", func(p *PigoParams) { p.ClusterThreshold = 0.99 }, ""},
+		{"Negative cluster threshold", func(p *PigoParams) { p.ClusterThreshold = -0.5 }, "cluster threshold"},
+		{"Cluster threshold of 1", func(p *PigoParams) { p.ClusterThreshold = 1 }, "cluster threshold"},
+		{"Cluster threshold NaN", func(p *PigoParams) { p.ClusterThreshold = math.NaN() }, "cluster threshold"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := valid
+			tt.modify(&params)
+
+			err := params.validate()
+			if tt.errText == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %v", tt.errText, err)
 			}
-			length := float32(math.Sqrt(float64(sumSquares)))
+		})
+	}
+}
 
-			tolerance := float32(0.0001)
-			if math.Abs(float64(length-tt.expectLen)) > float64(tolerance) {
-				t.Errorf("Expected L2 norm %.4f, got %.4f", tt.expectLen, length)
+func TestNewFaceRecognizer_InvalidPigoParams(t *testing.T) {
+	// Rejected before any model file is read
+	_, err := NewFaceDetector("./testdata/missing", WithPigoParams(PigoParams{MinSize: 20, MaxSize: 500, ShiftFactor: 0.1, ScaleFactor: 1}))
+	if err == nil || !strings.Contains(err.Error(), "invalid Pigo parameters") {
+		t.Errorf("Expected invalid Pigo parameters error, got %v", err)
+	}
+
+	_, err = NewFaceDetector("./testdata/missing", WithClusterThreshold(math.NaN()))
+	if err == nil || !strings.Contains(err.Error(), "cluster threshold") {
+		t.Errorf("Expected cluster threshold error, got %v", err)
+	}
+}
+
+func TestPigoAngle(t *testing.T) {
+	tests := []struct {
+		degrees  float64
+		expected float64
+	}{
+		{0, 0},
+		{45, 0.125},
+		{-45, 0.875},
+		{90, 0.25},
+	}
+
+	for _, tt := range tests {
+		result := pigoAngle(tt.degrees)
+		if math.Abs(result-tt.expected) > 0.0001 {
+			t.Errorf("pigoAngle(%.0f): expected %.4f, got %.4f", tt.degrees, tt.expected, result)
+		}
+	}
+}
+
+func TestRotatedBounds(t *testing.T) {
+	upright := rotatedBounds(100, 100, 50, 0)
+	if upright != image.Rect(75, 75, 125, 125) {
+		t.Errorf("Expected upright box (75,75)-(125,125), got %v", upright)
+	}
+
+	// A square rotated by 45° needs a box sqrt(2) times larger
+	rotated := rotatedBounds(100, 100, 50, 45)
+	if rotated.Dx() != 71 || rotated.Dy() != 71 {
+		t.Errorf("Expected 71x71 box for 45° rotation, got %dx%d", rotated.Dx(), rotated.Dy())
+	}
+}
+
+func TestClipDetections_ImageEdges(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	dets := []rotatedDetection{
+		{Detection: pigo.Detection{Row: 50, Col: 100, Scale: 40}},  // Inside
+		{Detection: pigo.Detection{Row: 10, Col: 5, Scale: 40}},    // Negative origin
+		{Detection: pigo.Detection{Row: 50, Col: 230, Scale: 40}},  // Past the right edge
+		{Detection: pigo.Detection{Row: 50, Col: 50, Scale: 0}},    // Zero size
+		{Detection: pigo.Detection{Row: 100, Col: 200, Scale: 40}}, // On the bottom-right corner
+	}
+
+	clipped := clipDetections(dets, bounds)
+
+	expected := []image.Rectangle{
+		image.Rect(80, 30, 120, 70),
+		image.Rect(0, 0, 25, 30),
+		image.Rect(180, 80, 200, 100),
+	}
+	if len(clipped) != len(expected) {
+		t.Fatalf("Expected %d detections, got %d", len(expected), len(clipped))
+	}
+	for i, det := range clipped {
+		if det.rect != expected[i] {
+			t.Errorf("Detection %d: expected %v, got %v", i, expected[i], det.rect)
+		}
+		if det.rect.Empty() || !det.rect.In(bounds) {
+			t.Errorf("Detection %d: box %v is not a valid region of %v", i, det.rect, bounds)
+		}
+	}
+}
+
+func TestEyeOpenness(t *testing.T) {
+	const width, height = 60, 40
+	pupil := image.Pt(30, 20)
+
+	// fill draws a uniform eye region with a disk of the given brightness
+	// around center
+	fill := func(center image.Point, background, disk uint8) []uint8 {
+		pixels := make([]uint8, width*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				pixels[y*width+x] = background
+				if dx, dy := x-center.X, y-center.Y; dx*dx+dy*dy <= 16 {
+					pixels[y*width+x] = disk
+				}
+			}
+		}
+		return pixels
+	}
+
+	tests := []struct {
+		name   string
+		pixels []uint8
+		pupil  image.Point
+		open   bool
+	}{
+		{"Dark iris on sclera", fill(pupil, 200, 40), pupil, true},
+		{"Closed lid", fill(pupil, 120, 115), pupil, false},
+		{"Pupil at the edge", fill(image.Pt(0, 20), 200, 40), image.Pt(0, 20), true},
+		{"Pupil outside", fill(pupil, 200, 40), image.Pt(-50, 20), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := eyeOpenness(tt.pixels, width, height, tt.pupil, 5)
+			if open := score >= eyeOpenContrast; open != tt.open {
+				t.Errorf("Expected open %v, got score %.3f", tt.open, score)
 			}
 		})
 	}
 }
 
+func TestEyesOpen_RequiresPuploc(t *testing.T) {
+	fr := &FaceRecognizer{}
+	img := gocv.NewMatWithSize(100, 100, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	if _, err := fr.EyesOpen(img, image.Rect(10, 10, 90, 90)); err == nil {
+		t.Error("Expected error without a pupil localization cascade")
+	}
+}
+
+func TestDownscaleGray(t *testing.T) {
+	// 4x2 image: left half 0/100, right half 200
+	pixels := []uint8{
+		0, 100, 200, 200,
+		100, 0, 200, 200,
+	}
+
+	out, width, height := downscaleGray(pixels, 4, 2, 0.5)
+	if width != 2 || height != 1 {
+		t.Fatalf("Expected 2x1, got %dx%d", width, height)
+	}
+	if !reflect.DeepEqual(out, []uint8{50, 200}) {
+		t.Errorf("Expected block averages [50 200], got %v", out)
+	}
+
+	// Non-integer ratios cover every source pixel exactly once
+	big := make([]uint8, 4000*3000)
+	for i := range big {
+		big[i] = 80
+	}
+	out, width, height = downscaleGray(big, 4000, 3000, 1000.0/4000)
+	if width != 1000 || height != 750 {
+		t.Fatalf("Expected 1000x750, got %dx%d", width, height)
+	}
+	for i, v := range out {
+		if v != 80 {
+			t.Fatalf("Pixel %d: expected 80, got %d", i, v)
+		}
+	}
+}
+
+func TestUpscaleDetections(t *testing.T) {
+	dets := []rotatedDetection{{Detection: pigo.Detection{Row: 75, Col: 100, Scale: 30}}}
+	upscaleDetections(dets, 4, 4)
+
+	clipped := clipDetections(dets, image.Rect(0, 0, 4000, 3000))
+	if expected := image.Rect(340, 240, 460, 360); clipped[0].rect != expected {
+		t.Errorf("Expected %v, got %v", expected, clipped[0].rect)
+	}
+}
+
+func TestMergeRotatedDetections(t *testing.T) {
+	dets := []rotatedDetection{
+		{Detection: pigo.Detection{Row: 100, Col: 100, Scale: 80, Q: 10}, angle: 0},
+		{Detection: pigo.Detection{Row: 104, Col: 98, Scale: 84, Q: 25}, angle: 15},
+		{Detection: pigo.Detection{Row: 400, Col: 400, Scale: 80, Q: 8}, angle: -15},
+	}
+
+	merged := mergeRotatedDetections(dets, 0.2)
+	if len(merged) != 2 {
+		t.Fatalf("Expected 2 merged detections, got %d", len(merged))
+	}
+
+	if merged[0].angle != 15 || merged[0].Q != 25 {
+		t.Errorf("Expected strongest detection (angle 15) to be kept, got angle %.0f (Q=%.0f)",
+			merged[0].angle, merged[0].Q)
+	}
+}
+
 // Test: Model configuration
 
 func TestModelConfigs(t *testing.T) {
@@ -517,6 +3378,7 @@ func TestModelConfigs(t *testing.T) {
 		{ModelFaceNet, image.Pt(160, 160), 128},
 		{ModelArcFace, image.Pt(112, 112), 512},
 		{ModelDlib, image.Pt(150, 150), 128},
+		{ModelSFace, image.Pt(112, 112), 128},
 	}
 
 	for _, tt := range tests {
@@ -556,6 +3418,30 @@ func BenchmarkCosineSimilarity(b *testing.B) {
 	}
 }
 
+// BenchmarkSimilarity_512 compares cosineSimilarity with the dotProduct
+// used for matching normalized features, at a 512-dim embedding size
+func BenchmarkSimilarity_512(b *testing.B) {
+	a := make([]float32, 512)
+	vec := make([]float32, 512)
+	for i := range a {
+		a[i] = float32(i)
+		vec[i] = float32(i + 1)
+	}
+	a = normalizeFeature(a)
+	vec = normalizeFeature(vec)
+
+	b.Run("cosine", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cosineSimilarity(a, vec)
+		}
+	})
+	b.Run("dot", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dotProduct(a, vec)
+		}
+	})
+}
+
 func BenchmarkEuclideanDistance(b *testing.B) {
 	a := make([]float32, 128)
 	vec := make([]float32, 128)
@@ -611,6 +3497,41 @@ func BenchmarkDetectFaces(b *testing.B) {
 	}
 }
 
+func BenchmarkDetectFaces_1080p(b *testing.B) {
+	// Skip if models not available
+	if _, err := os.Stat("./testdata/facefinder"); os.IsNotExist(err) {
+		b.Skip("Model files not available")
+	}
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config)
+	if err != nil {
+		b.Skipf("Failed to initialize recognizer: %v", err)
+	}
+	defer recognizer.Close()
+
+	testImg := createTestImage(1920, 1080)
+	defer testImg.Close()
+
+	// Previous path: Mat -> image.Image -> per-pixel grayscale
+	b.Run("ImageConversion", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			goImg, _ := testImg.ToImage()
+			recognizer.DetectFaces(goImg)
+		}
+	})
+
+	b.Run("Mat", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			recognizer.DetectFacesFromMat(testImg)
+		}
+	})
+}
+
 func BenchmarkExtractFeature(b *testing.B) {
 	// Skip if models not available
 	if _, err := os.Stat("./testdata/facefinder"); os.IsNotExist(err) {
@@ -637,6 +3558,102 @@ func BenchmarkExtractFeature(b *testing.B) {
 	}
 }
 
+// BenchmarkFirstInference reports the latency of the first and second
+// inference of a freshly loaded model, the gap that Warmup removes
+func BenchmarkFirstInference(b *testing.B) {
+	if _, err := os.Stat("./testdata/facefinder"); os.IsNotExist(err) {
+		b.Skip("Model files not available")
+	}
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	testImg := createTestImage(200, 200)
+	defer testImg.Close()
+
+	var first, second time.Duration
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		recognizer, err := NewFaceRecognizer(config)
+		if err != nil {
+			b.Skipf("Failed to initialize recognizer: %v", err)
+		}
+		b.StartTimer()
+
+		start := time.Now()
+		recognizer.ExtractFeature(testImg)
+		first += time.Since(start)
+
+		start = time.Now()
+		recognizer.ExtractFeature(testImg)
+		second += time.Since(start)
+
+		b.StopTimer()
+		recognizer.Close()
+		b.StartTimer()
+	}
+
+	b.ReportMetric(float64(first.Microseconds())/float64(b.N), "first-µs")
+	b.ReportMetric(float64(second.Microseconds())/float64(b.N), "second-µs")
+}
+
+// BenchmarkExtractFeature_Interpolation upscales a small crop with each
+// resize mode and reports how close its feature stays to the feature of the
+// full-resolution face ("similarity", higher is more stable)
+func BenchmarkExtractFeature_Interpolation(b *testing.B) {
+	// Skip if models not available
+	if _, err := os.Stat("./testdata/facefinder"); os.IsNotExist(err) {
+		b.Skip("Model files not available")
+	}
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	testImg := createTestImage(200, 200)
+	defer testImg.Close()
+
+	smallCrop := gocv.NewMat()
+	defer smallCrop.Close()
+	gocv.Resize(testImg, &smallCrop, image.Pt(40, 40), 0, 0, gocv.InterpolationArea)
+
+	modes := []struct {
+		name          string
+		interpolation gocv.InterpolationFlags
+	}{
+		{"Linear", gocv.InterpolationLinear},
+		{"Cubic", gocv.InterpolationCubic},
+		{"Lanczos4", gocv.InterpolationLanczos4},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			recognizer, err := NewFaceRecognizer(config, WithResizeInterpolation(mode.interpolation))
+			if err != nil {
+				b.Skipf("Failed to initialize recognizer: %v", err)
+			}
+			defer recognizer.Close()
+
+			reference, err := recognizer.ExtractFeature(testImg)
+			if err != nil {
+				b.Fatalf("Failed to extract reference feature: %v", err)
+			}
+
+			var feature []float32
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				feature, _ = recognizer.ExtractFeature(smallCrop)
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(cosineSimilarity(reference, feature)), "similarity")
+		})
+	}
+}
+
 // Table-driven tests for comprehensive coverage
 
 func TestAddPerson_TableDriven(t *testing.T) {
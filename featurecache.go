@@ -0,0 +1,151 @@
+package face
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// FeatureCacheStats reports the counters of the feature cache, see
+// WithFeatureCache
+type FeatureCacheStats struct {
+	Hits     uint64 // ExtractFeature calls answered from the cache
+	Misses   uint64 // ExtractFeature calls that ran the encoder
+	Entries  int    // Features currently cached
+	Capacity int    // Maximum number of cached features
+}
+
+// featureKey is the SHA-256 of a face crop's size, type and pixels
+type featureKey [sha256.Size]byte
+
+// featureCache is a concurrency-safe LRU cache of extracted features
+type featureCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Most recently used first
+	entries  map[featureKey]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+// featureCacheEntry is the value of a featureCache list element
+type featureCacheEntry struct {
+	key     featureKey
+	feature []float32
+}
+
+// WithFeatureCache caches the features of up to size face crops, keyed by a
+// hash of the crop's pixels, so ExtractFeature (and recognition and
+// enrollment, which use it) skip the encoder when the same crop is seen
+// again, e.g. on retries or re-renders. The least recently used feature is
+// evicted when the cache is full. size <= 0 disables the cache (default).
+func WithFeatureCache(size int) Option {
+	return func(fr *FaceRecognizer) {
+		fr.featureCache = nil
+		if size > 0 {
+			fr.featureCache = newFeatureCache(size)
+		}
+	}
+}
+
+// newFeatureCache creates a feature cache holding up to capacity features
+func newFeatureCache(capacity int) *featureCache {
+	return &featureCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[featureKey]*list.Element, capacity),
+	}
+}
+
+// featureCacheKey hashes a face crop. Crops that are not continuous (e.g.
+// Mat regions) are copied first so only their own pixels are hashed.
+func featureCacheKey(faceImg gocv.Mat) featureKey {
+	if !faceImg.IsContinuous() {
+		continuous := faceImg.Clone()
+		defer continuous.Close()
+		faceImg = continuous
+	}
+
+	h := sha256.New()
+	var header [12]byte
+	binary.LittleEndian.PutUint32(header[0:], uint32(faceImg.Rows()))
+	binary.LittleEndian.PutUint32(header[4:], uint32(faceImg.Cols()))
+	binary.LittleEndian.PutUint32(header[8:], uint32(faceImg.Type()))
+	h.Write(header[:])
+	h.Write(faceImg.ToBytes())
+
+	var key featureKey
+	h.Sum(key[:0])
+	return key
+}
+
+// get returns a copy of the cached feature for key and counts the hit or miss
+func (c *featureCache) get(key featureKey) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[key]
+	if !exists {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	c.order.MoveToFront(elem)
+	return append([]float32(nil), elem.Value.(*featureCacheEntry).feature...), true
+}
+
+// put caches a copy of feature under key, evicting the least recently used
+// entry if the cache is full
+func (c *featureCache) put(key featureKey, feature []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	feature = append([]float32(nil), feature...)
+	if elem, exists := c.entries[key]; exists {
+		elem.Value.(*featureCacheEntry).feature = feature
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&featureCacheEntry{key: key, feature: feature})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*featureCacheEntry).key)
+	}
+}
+
+// clear drops all cached features, keeping the counters
+func (c *featureCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.entries)
+}
+
+// stats returns the current counters
+func (c *featureCache) stats() FeatureCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return FeatureCacheStats{
+		Hits:     c.hits,
+		Misses:   c.misses,
+		Entries:  c.order.Len(),
+		Capacity: c.capacity,
+	}
+}
+
+// CacheStats returns the hit/miss counters of the feature cache. All fields
+// are zero if WithFeatureCache is not enabled.
+func (fr *FaceRecognizer) CacheStats() FeatureCacheStats {
+	if fr.featureCache == nil {
+		return FeatureCacheStats{}
+	}
+	return fr.featureCache.stats()
+}
@@ -0,0 +1,127 @@
+package face
+
+import (
+	"image"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestFeatureCache(t *testing.T) {
+	cache := newFeatureCache(2)
+	a, b, c := featureKey{1}, featureKey{2}, featureKey{3}
+
+	if _, ok := cache.get(a); ok {
+		t.Fatal("Expected miss on empty cache")
+	}
+
+	feature := []float32{0.1, 0.2}
+	cache.put(a, feature)
+	cache.put(b, []float32{0.3})
+	feature[0] = 9 // The cache keeps its own copy
+
+	got, ok := cache.get(a)
+	if !ok || !reflect.DeepEqual(got, []float32{0.1, 0.2}) {
+		t.Fatalf("Expected cached [0.1 0.2], got %v (hit %v)", got, ok)
+	}
+	got[1] = 9 // Callers get their own copy too
+
+	// a was used last, so adding c evicts b
+	cache.put(c, []float32{0.4})
+	if _, ok := cache.get(b); ok {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if got, ok := cache.get(a); !ok || got[1] != 0.2 {
+		t.Errorf("Expected a to stay cached unchanged, got %v (hit %v)", got, ok)
+	}
+
+	expected := FeatureCacheStats{Hits: 2, Misses: 2, Entries: 2, Capacity: 2}
+	if stats := cache.stats(); stats != expected {
+		t.Errorf("Expected stats %+v, got %+v", expected, stats)
+	}
+}
+
+func TestFeatureCache_Concurrent(t *testing.T) {
+	cache := newFeatureCache(8)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := featureKey{byte(i), byte(j % 16)}
+				if _, ok := cache.get(key); !ok {
+					cache.put(key, []float32{float32(j)})
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := cache.stats()
+	if stats.Hits+stats.Misses != 800 {
+		t.Errorf("Expected 800 lookups, got %d hits and %d misses", stats.Hits, stats.Misses)
+	}
+	if stats.Entries != 8 {
+		t.Errorf("Expected cache to be full with 8 entries, got %d", stats.Entries)
+	}
+}
+
+func TestWithFeatureCache(t *testing.T) {
+	fr := &FaceRecognizer{}
+	if stats := fr.CacheStats(); stats != (FeatureCacheStats{}) {
+		t.Errorf("Expected zero stats without cache, got %+v", stats)
+	}
+
+	WithFeatureCache(16)(fr)
+	if stats := fr.CacheStats(); stats.Capacity != 16 {
+		t.Errorf("Expected capacity 16, got %d", stats.Capacity)
+	}
+
+	WithFeatureCache(0)(fr)
+	if fr.featureCache != nil {
+		t.Error("Expected size 0 to disable the cache")
+	}
+}
+
+func TestExtractFeature_Cache(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	config := Config{
+		PigoCascadeFile:  "./testdata/facefinder",
+		FaceEncoderModel: "./testdata/nn4.small2.v1.t7",
+	}
+
+	recognizer, err := NewFaceRecognizer(config, WithFeatureCache(4))
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer recognizer.Close()
+
+	img := gocv.NewMatWithSize(200, 200, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	// A region is not continuous and must hash only its own pixels
+	face := img.Region(image.Rect(50, 50, 150, 150))
+	defer face.Close()
+
+	first, err := recognizer.ExtractFeature(face)
+	if err != nil {
+		t.Fatalf("Failed to extract feature: %v", err)
+	}
+	second, err := recognizer.ExtractFeature(face)
+	if err != nil {
+		t.Fatalf("Failed to extract feature: %v", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Error("Expected the cached feature to equal the extracted one")
+	}
+	if stats := recognizer.CacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", stats)
+	}
+}
@@ -0,0 +1,182 @@
+package face
+
+import (
+	"sort"
+	"sync"
+)
+
+// galleryMatrix holds the matching vectors of all persons packed into one
+// contiguous buffer, so a brute-force match is a single pass over memory
+// instead of a walk over the person map and every person's samples.
+//
+// Changes only bump a version; the buffer is rebuilt by the next match that
+// finds it out of date. A rebuild that races with a change records the
+// version it started from, so the change is picked up by the following one.
+type galleryMatrix struct {
+	syncMu sync.Mutex // Serializes rebuilding (see FaceRecognizer.packedGallery)
+
+	mu      sync.Mutex
+	version uint64       // Bumped by every change to persons or samples
+	built   uint64       // version the current data was built from
+	current *galleryData // nil until the first build
+}
+
+// galleryData is an immutable snapshot of the packed gallery
+type galleryData struct {
+	dim     int       // Length of every row; 0 if rows differ in length or there are none
+	data    []float32 // Row-major vectors, dim floats per row
+	offsets []int     // Rows of person i are offsets[i] to offsets[i+1]
+	ids     []string  // Person ID per person index
+	names   []string  // Person name per person index
+}
+
+// invalidate marks the packed gallery out of date
+func (g *galleryMatrix) invalidate() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.version++
+}
+
+// snapshot returns the current data if it is up to date
+func (g *galleryMatrix) snapshot() (*galleryData, uint64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.current, g.version, g.current != nil && g.built == g.version
+}
+
+// packedGallery returns the packed gallery, rebuilding it if persons or
+// samples changed since it was built. The caller must hold fr.mu.
+func (fr *FaceRecognizer) packedGallery() *galleryData {
+	if data, _, ok := fr.gallery.snapshot(); ok {
+		return data
+	}
+
+	fr.gallery.syncMu.Lock()
+	defer fr.gallery.syncMu.Unlock()
+
+	data, version, ok := fr.gallery.snapshot()
+	if ok {
+		return data
+	}
+
+	data = fr.buildGallery()
+
+	fr.gallery.mu.Lock()
+	fr.gallery.current, fr.gallery.built = data, version
+	fr.gallery.mu.Unlock()
+
+	return data
+}
+
+// buildGallery packs the vectors scorePerson compares against: the centroid
+// of each person under StrategyCentroid, otherwise every sample. Persons
+// that scorePerson skips (disabled, no samples) are left out. Persons are
+// sorted by ID. The caller must hold fr.mu.
+func (fr *FaceRecognizer) buildGallery() *galleryData {
+	ids := make([]string, 0, len(fr.persons))
+	for id := range fr.persons {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	data := &galleryData{offsets: []int{0}}
+	dim, rows, uniform := -1, 0, true
+	for _, id := range ids {
+		person := fr.persons[id]
+
+		person.mu.RLock()
+		if !person.Disabled && len(person.Features) > 0 {
+			var vectors [][]float32
+			if fr.strategy == StrategyCentroid {
+				vectors = [][]float32{fr.centroid(person)}
+			} else {
+				vectors = make([][]float32, 0, len(person.Features))
+				for _, sample := range person.Features {
+					vectors = append(vectors, sample.Feature)
+				}
+			}
+
+			for _, v := range vectors {
+				if dim < 0 {
+					dim = len(v)
+				} else if len(v) != dim {
+					uniform = false
+				}
+				data.data = append(data.data, v...)
+			}
+			rows += len(vectors)
+
+			data.offsets = append(data.offsets, rows)
+			data.ids = append(data.ids, person.ID)
+			data.names = append(data.names, person.Name)
+		}
+		person.mu.RUnlock()
+	}
+
+	if uniform && dim > 0 {
+		data.dim = dim
+	}
+
+	return data
+}
+
+// fits reports whether feature can be matched against the packed rows.
+// Galleries with mixed dimensions are matched person by person instead.
+func (g *galleryData) fits(feature []float32) bool {
+	return g.dim > 0 && len(feature) == g.dim
+}
+
+// persons returns the number of persons in the gallery
+func (g *galleryData) persons() int {
+	return len(g.ids)
+}
+
+// matchGallery returns the best match among the persons with index lo to hi,
+// scoring each by its best row like scorePerson
+func (fr *FaceRecognizer) matchGallery(feature []float32, g *galleryData, lo, hi int) personMatch {
+	var best personMatch
+	var runnerUp float32
+
+	// Unit-length rows: cosine similarity is a plain dot product
+	cosine := fr.metric != MetricEuclidean && fr.normalization == NormL2
+	dim := g.dim
+	for p := lo; p < hi; p++ {
+		first, last := g.offsets[p]*dim, g.offsets[p+1]*dim
+
+		similarity := float32(0)
+		for off := first; off < last; off += dim {
+			row := g.data[off : off+dim]
+
+			var s float32
+			if cosine {
+				s = dotProduct(feature, row)
+			} else {
+				s = fr.normalizedSimilarity(feature, row)
+			}
+			if off == first || s > similarity {
+				similarity = s
+			}
+		}
+
+		if !(similarity > 0) {
+			continue
+		}
+
+		candidate := personMatch{
+			personID:   g.ids[p],
+			personName: g.names[p],
+			confidence: similarity,
+		}
+		if candidate.betterThan(best) {
+			runnerUp = max(runnerUp, best.confidence)
+			best = candidate
+		} else {
+			runnerUp = max(runnerUp, similarity)
+		}
+	}
+	best.runnerUp = runnerUp
+
+	return best
+}
@@ -0,0 +1,149 @@
+package face
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// sampledGallery returns n persons with samples random unit-length samples
+// each
+func sampledGallery(rng *rand.Rand, n, samples, dim int) map[string]*Person {
+	persons := make(map[string]*Person, n)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("%05d", i)
+		features := make([]FaceFeature, samples)
+		for j := range features {
+			features[j] = FaceFeature{PersonID: id, Feature: randomUnitVector(rng, dim)}
+		}
+		persons[id] = &Person{ID: id, Name: "Person " + id, Features: features}
+	}
+	return persons
+}
+
+func TestGalleryMatrix_MatchesPerPerson(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	persons := sampledGallery(rng, 300, 3, 64)
+	persons["00007"].Disabled = true
+	persons["00008"].Features = nil
+
+	ids := make([]string, 0, len(persons))
+	all := make([]*Person, 0, len(persons))
+	for id, person := range persons {
+		ids = append(ids, id)
+		all = append(all, person)
+	}
+
+	tests := []struct {
+		name     string
+		metric   DistanceMetric
+		strategy MatchStrategy
+		workers  int
+	}{
+		{"Cosine max-sample", MetricCosine, StrategyMaxSample, 0},
+		{"Cosine centroid", MetricCosine, StrategyCentroid, 0},
+		{"Euclidean max-sample", MetricEuclidean, StrategyMaxSample, 0},
+		{"Parallel", MetricCosine, StrategyMaxSample, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &FaceRecognizer{persons: persons, metric: tt.metric, strategy: tt.strategy, workers: tt.workers}
+
+			for i := 0; i < 50; i++ {
+				source := persons[ids[rng.Intn(len(ids))]]
+				query := randomUnitVector(rng, 64)
+				if len(source.Features) > 0 {
+					query = noisyCopy(rng, source.Features[0].Feature, 0.3)
+				}
+
+				got := fr.bestMatch(query)
+				fr.mu.RLock()
+				expected := fr.matchShard(query, all)
+				fr.mu.RUnlock()
+
+				if got != expected {
+					t.Fatalf("Query %d: expected %+v, got %+v", i, expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGalleryMatrix_Rebuild(t *testing.T) {
+	fr := &FaceRecognizer{persons: map[string]*Person{
+		"001": {ID: "001", Name: "Alice", Features: []FaceFeature{{PersonID: "001", Feature: []float32{1, 0}}}},
+		"002": {ID: "002", Name: "Bob", Features: []FaceFeature{{PersonID: "002", Feature: []float32{0.8, 0.6}}}},
+	}}
+	query := []float32{1, 0}
+
+	if id, name, _ := fr.matchPerson(query); id != "001" || name != "Alice" {
+		t.Fatalf("Expected 001 Alice, got %s %s", id, name)
+	}
+
+	if err := fr.UpdatePerson("001", "Alicia"); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+	if _, name, _ := fr.matchPerson(query); name != "Alicia" {
+		t.Errorf("Expected renamed person, got %s", name)
+	}
+
+	if err := fr.SetPersonEnabled("001", false); err != nil {
+		t.Fatalf("Failed to disable: %v", err)
+	}
+	if id, _, _ := fr.matchPerson(query); id != "002" {
+		t.Errorf("Expected 002 after disabling 001, got %s", id)
+	}
+
+	if err := fr.RemovePerson("002"); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	if id, _, _ := fr.matchPerson(query); id != "" {
+		t.Errorf("Expected no match, got %s", id)
+	}
+}
+
+func TestGalleryMatrix_MixedDimensions(t *testing.T) {
+	fr := &FaceRecognizer{persons: map[string]*Person{
+		"001": {ID: "001", Name: "Alice", Features: []FaceFeature{{PersonID: "001", Feature: []float32{1, 0}}}},
+		"002": {ID: "002", Name: "Bob", Features: []FaceFeature{{PersonID: "002", Feature: []float32{1, 0, 0}}}},
+	}}
+
+	fr.mu.RLock()
+	gallery := fr.packedGallery()
+	fr.mu.RUnlock()
+	if gallery.fits([]float32{1, 0}) {
+		t.Error("Expected a gallery with mixed dimensions not to be packed")
+	}
+
+	// Falls back to matching person by person
+	if id, _, _ := fr.matchPerson([]float32{1, 0}); id != "001" {
+		t.Errorf("Expected 001, got %s", id)
+	}
+}
+
+func BenchmarkMatchPerson_10kSamples(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	persons := sampledGallery(rng, 2000, 5, 128)
+	query := randomUnitVector(rng, 128)
+
+	all := make([]*Person, 0, len(persons))
+	for _, person := range persons {
+		all = append(all, person)
+	}
+	fr := &FaceRecognizer{persons: persons}
+
+	b.Run("Packed", func(b *testing.B) {
+		fr.bestMatch(query) // Build outside the timed loop
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			fr.bestMatch(query)
+		}
+	})
+
+	b.Run("PerPerson", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fr.matchShard(query, all)
+		}
+	})
+}
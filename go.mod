@@ -4,6 +4,25 @@ go 1.25.4
 
 require (
 	github.com/esimov/pigo v1.4.6
+	github.com/jdeng/goheif v0.1.2
+	go.etcd.io/bbolt v1.5.0
 	gocv.io/x/gocv v0.42.0
+	golang.org/x/image v0.38.0
 	golang.org/x/net v0.47.0
+	modernc.org/sqlite v1.40.0
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/crypto v0.44.0 // indirect
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
+	golang.org/x/sys v0.45.0 // indirect
+	golang.org/x/text v0.35.0 // indirect
+	modernc.org/libc v1.66.10 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
 )
@@ -0,0 +1,14 @@
+//go:build heic
+
+package face
+
+// HEIC/HEIF decoder for LoadImage (see loadHEIF). Neither OpenCV nor the
+// standard library reads HEIF; github.com/jdeng/goheif bundles libde265 and
+// needs cgo, so it is only compiled with the "heic" build tag:
+//
+//	go build -tags heic
+import _ "github.com/jdeng/goheif"
+
+func init() {
+	SupportedImageFormats = append(SupportedImageFormats, ".heic", ".heif")
+}
@@ -0,0 +1,62 @@
+//go:build heic
+
+package face
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// testdata/thumbnail.heic is the 320x240 thumbnail of goheif's camel.heic
+// (MIT), stored as the primary image
+const heicTestFile = "testdata/thumbnail.heic"
+
+func TestHEIC_Registered(t *testing.T) {
+	for _, name := range []string{"photo.heic", "photo.HEIF"} {
+		if !IsSupportedImageFormat(name) {
+			t.Errorf("Expected %s to be supported with the heic tag", name)
+		}
+	}
+
+	f, err := os.Open(heicTestFile)
+	if err != nil {
+		t.Fatalf("Failed to open test image: %v", err)
+	}
+	defer f.Close()
+
+	img, format, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("Failed to decode HEIC: %v", err)
+	}
+	if format != "heic" || img.Bounds().Dx() != 320 || img.Bounds().Dy() != 240 {
+		t.Errorf("Expected a 320x240 heic image, got %s %v", format, img.Bounds())
+	}
+
+	width, height, channels, err := GetImageInfo(heicTestFile)
+	if err != nil || width != 320 || height != 240 || channels != 3 {
+		t.Errorf("Expected 320x240x3 from GetImageInfo, got %dx%dx%d, %v", width, height, channels, err)
+	}
+}
+
+func TestLoadImage_HEIC(t *testing.T) {
+	img, err := LoadImage(heicTestFile)
+	if err != nil {
+		t.Fatalf("Failed to load HEIC: %v", err)
+	}
+	defer img.Close()
+
+	if img.Cols() != 320 || img.Rows() != 240 || img.Type() != gocv.MatTypeCV8UC3 {
+		t.Errorf("Expected a 320x240 BGR Mat, got %dx%d type %v", img.Cols(), img.Rows(), img.Type())
+	}
+
+	// HEIC is load-only
+	err = SaveImage(filepath.Join(t.TempDir(), "out.heic"), img)
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Expected saving HEIC to be unsupported, got %v", err)
+	}
+}
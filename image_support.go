@@ -1,11 +1,16 @@
 package face
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,7 +18,9 @@ import (
 	"gocv.io/x/gocv"
 )
 
-// SupportedImageFormats lists all supported image formats
+// SupportedImageFormats lists the supported image formats. Building with the
+// "heic" tag adds .heic and .heif, which can be loaded but not saved (see
+// image_heic.go).
 var SupportedImageFormats = []string{
 	".jpg", ".jpeg", // JPEG
 	".png",          // PNG
@@ -34,35 +41,357 @@ func IsSupportedImageFormat(filename string) bool {
 	return false
 }
 
+// MaxImagePixels is the largest width*height the image loaders (LoadImage,
+// LoadImageNoAutoRotate, LoadImageFromBytes, LoadImageFromReader and
+// LoadImageFromReaderLimit) accept. The dimensions are read from the image
+// header before decoding, so an image declaring huge dimensions fails with
+// ErrImageTooLarge instead of making the decoder allocate gigabytes. Only
+// formats with a registered Go decoder (GIF, JPEG, PNG; BMP, TIFF and WebP
+// with the "ximage" build tag) can be checked up front; OpenCV applies its
+// own limit (OPENCV_IO_MAX_IMAGE_PIXELS) to the others. The default of 2^27
+// (~134 megapixels) fits any camera photo; 0 disables the check.
+var MaxImagePixels int64 = 1 << 27
+
+// isHEIFFormat reports whether the file extension is HEIC/HEIF
+func isHEIFFormat(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".heic" || ext == ".heif"
+}
+
 // LoadImage loads an image from file path
-// Supports: JPG, PNG, BMP, TIFF, WebP, GIF
+// Supports: JPG, PNG, BMP, TIFF, WebP, GIF, and HEIC/HEIF with the "heic" tag
+// JPEGs carrying an EXIF orientation tag are rotated/flipped upright.
+// Images larger than MaxImagePixels fail with ErrImageTooLarge.
 func LoadImage(filepath string) (gocv.Mat, error) {
+	img, err := LoadImageNoAutoRotate(filepath)
+	if err != nil {
+		return gocv.Mat{}, err
+	}
+
+	orientation := exifOrientationFromFile(filepath)
+	if orientation <= 1 {
+		return img, nil
+	}
+
+	oriented, err := applyOrientation(img, orientation)
+	img.Close()
+	if err != nil {
+		return gocv.Mat{}, fmt.Errorf("failed to apply EXIF orientation: %v", err)
+	}
+
+	return oriented, nil
+}
+
+// LoadImageNoAutoRotate loads an image from file path without applying
+// the EXIF orientation tag, returning the pixels as stored
+func LoadImageNoAutoRotate(filepath string) (gocv.Mat, error) {
 	if !IsSupportedImageFormat(filepath) {
 		return gocv.Mat{}, fmt.Errorf("unsupported image format: %s", filepath)
 	}
 
-	img := gocv.IMRead(filepath, gocv.IMReadColor)
+	if err := checkImageFilePixels(filepath); err != nil {
+		return gocv.Mat{}, err
+	}
+
+	if isHEIFFormat(filepath) {
+		return loadHEIF(filepath)
+	}
+
+	img := gocv.IMRead(filepath, gocv.IMReadColor|gocv.IMReadIgnoreOrientation)
 	if img.Empty() {
-		return gocv.Mat{}, fmt.Errorf("failed to load image: %s", filepath)
+		img.Close()
+
+		// OpenCV may be built without a codec (GIF before 4.11, or WebP/TIFF
+		// in minimal builds); fall back to the registered Go decoders
+		f, err := os.Open(filepath)
+		if err != nil {
+			return gocv.Mat{}, fmt.Errorf("failed to load image: %v", err)
+		}
+		defer f.Close()
+
+		fallback, err := decodeStdImage(f)
+		if err != nil {
+			return gocv.Mat{}, fmt.Errorf("failed to load image: %s", filepath)
+		}
+		return fallback, nil
 	}
 
 	return img, nil
 }
 
-// LoadImageFromBytes loads an image from byte slice
+// checkImagePixels returns ErrImageTooLarge if the header of the encoded
+// image in r declares more than MaxImagePixels pixels. Images whose header
+// can't be read by a registered Go decoder are left to the decoder.
+func checkImagePixels(r io.Reader) error {
+	if MaxImagePixels <= 0 {
+		return nil
+	}
+
+	config, _, err := image.DecodeConfig(bufio.NewReader(r))
+	if err != nil {
+		return nil
+	}
+
+	if pixels := int64(config.Width) * int64(config.Height); pixels > MaxImagePixels {
+		return fmt.Errorf("%w: %dx%d is more than %d pixels", ErrImageTooLarge, config.Width, config.Height, MaxImagePixels)
+	}
+	return nil
+}
+
+// checkImageFilePixels is checkImagePixels for an image file. Files that
+// can't be opened are left to the loader, which reports the error.
+func checkImageFilePixels(filepath string) error {
+	if MaxImagePixels <= 0 {
+		return nil
+	}
+
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	return checkImagePixels(f)
+}
+
+// decodeStdImage decodes r with the decoders registered in the image package
+// into a 3-channel BGR Mat. GIF, JPEG and PNG are always registered; building
+// with the "ximage" tag adds BMP, TIFF and WebP (see image_ximage.go).
+func decodeStdImage(r io.Reader) (gocv.Mat, error) {
+	img, _, err := image.Decode(bufio.NewReader(r))
+	if err != nil {
+		return gocv.Mat{}, err
+	}
+
+	return LoadImageFromStdImage(img)
+}
+
+// loadHEIF decodes a HEIC/HEIF file into a 3-channel BGR Mat.
+//
+// Neither OpenCV nor the standard library can decode HEIF, so decoding goes
+// through the image package registry, where the "heic" build tag registers
+// github.com/jdeng/goheif (see image_heic.go).
+func loadHEIF(filepath string) (gocv.Mat, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return gocv.Mat{}, fmt.Errorf("failed to load image: %v", err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(bufio.NewReader(f))
+	if err == image.ErrFormat {
+		return gocv.Mat{}, fmt.Errorf("no HEIF decoder registered, cannot load %s (build with -tags heic)", filepath)
+	}
+	if err != nil {
+		return gocv.Mat{}, fmt.Errorf("failed to decode HEIF image %s: %v", filepath, err)
+	}
+
+	return LoadImageFromStdImage(img)
+}
+
+// exifOrientationFromFile returns the EXIF orientation (1-8) of a JPEG file,
+// or 1 for other formats and files without the tag
+func exifOrientationFromFile(filepath string) int {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+
+	return readJPEGOrientation(bufio.NewReader(f))
+}
+
+// readJPEGOrientation scans the JPEG segments preceding the image data for an
+// EXIF APP1 block and returns its orientation tag (1-8), or 1 if none is found
+func readJPEGOrientation(r io.Reader) int {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return 1
+	}
+
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil || marker[0] != 0xFF {
+			return 1
+		}
+		// Skip fill bytes
+		for marker[1] == 0xFF {
+			if _, err := io.ReadFull(r, marker[1:]); err != nil {
+				return 1
+			}
+		}
+		// Start of scan / end of image: no more metadata
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return 1
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil || length < 2 {
+			return 1
+		}
+		size := int64(length) - 2
+
+		if marker[1] != 0xE1 {
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				return 1
+			}
+			continue
+		}
+
+		segment := make([]byte, size)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return 1
+		}
+		if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFFOrientation(segment[6:])
+		}
+	}
+}
+
+// parseTIFFOrientation reads the orientation tag (0x0112) from IFD0 of a
+// TIFF-structured EXIF payload
+func parseTIFFOrientation(data []byte) int {
+	if len(data) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(data[2:4]) != 42 {
+		return 1
+	}
+
+	offset := int(order.Uint32(data[4:8]))
+	if offset < 8 || offset+2 > len(data) {
+		return 1
+	}
+
+	count := int(order.Uint16(data[offset : offset+2]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(data) {
+			return 1
+		}
+		if order.Uint16(data[entry:entry+2]) != 0x0112 {
+			continue
+		}
+		// Orientation is a single SHORT stored inline in the value field
+		if order.Uint16(data[entry+2:entry+4]) != 3 {
+			return 1
+		}
+		orientation := int(order.Uint16(data[entry+8 : entry+10]))
+		if orientation < 1 || orientation > 8 {
+			return 1
+		}
+		return orientation
+	}
+
+	return 1
+}
+
+// applyOrientation returns a copy of img transformed so that an image tagged
+// with the given EXIF orientation is displayed upright
+func applyOrientation(img gocv.Mat, orientation int) (gocv.Mat, error) {
+	dst := gocv.NewMat()
+
+	var err error
+	switch orientation {
+	case 2: // Mirrored horizontally
+		err = gocv.Flip(img, &dst, 1)
+	case 3: // Rotated 180
+		err = gocv.Rotate(img, &dst, gocv.Rotate180Clockwise)
+	case 4: // Mirrored vertically
+		err = gocv.Flip(img, &dst, 0)
+	case 5: // Transposed
+		err = gocv.Transpose(img, &dst)
+	case 6: // Rotated 90 CW
+		err = gocv.Rotate(img, &dst, gocv.Rotate90Clockwise)
+	case 7: // Transversed
+		if err = gocv.Rotate(img, &dst, gocv.Rotate90Clockwise); err == nil {
+			err = gocv.Flip(dst, &dst, 0)
+		}
+	case 8: // Rotated 90 CCW
+		err = gocv.Rotate(img, &dst, gocv.Rotate90CounterClockwise)
+	default:
+		err = img.CopyTo(&dst)
+	}
+
+	if err != nil {
+		dst.Close()
+		return gocv.Mat{}, err
+	}
+
+	return dst, nil
+}
+
+// LoadImageFromBytes loads an image from byte slice. Images larger than
+// MaxImagePixels fail with ErrImageTooLarge.
 func LoadImageFromBytes(data []byte) (gocv.Mat, error) {
+	if err := checkImagePixels(bytes.NewReader(data)); err != nil {
+		return gocv.Mat{}, err
+	}
+
 	img, err := gocv.IMDecode(data, gocv.IMReadColor)
 	if err != nil {
 		return gocv.Mat{}, fmt.Errorf("failed to decode image: %v", err)
 	}
 
 	if img.Empty() {
+		img.Close()
+
+		// Fall back to the registered Go decoders, as in LoadImageNoAutoRotate
+		if fallback, err := decodeStdImage(bytes.NewReader(data)); err == nil {
+			return fallback, nil
+		}
 		return gocv.Mat{}, fmt.Errorf("decoded image is empty")
 	}
 
 	return img, nil
 }
 
+// ErrImageTooLarge is returned by LoadImageFromReaderLimit when the encoded
+// image exceeds the size limit, and by the image loaders when its dimensions
+// exceed MaxImagePixels
+var ErrImageTooLarge = errors.New("image too large")
+
+// LoadImageFromReader reads an encoded image from r (e.g. an HTTP upload)
+// and decodes it like LoadImageFromBytes. For untrusted input use
+// LoadImageFromReaderLimit to bound memory.
+func LoadImageFromReader(r io.Reader) (gocv.Mat, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return gocv.Mat{}, fmt.Errorf("failed to read image: %v", err)
+	}
+
+	return LoadImageFromBytes(data)
+}
+
+// LoadImageFromReaderLimit is LoadImageFromReader for at most maxBytes of
+// encoded data. Larger input fails with ErrImageTooLarge after reading just
+// past the limit, so a server can bound memory per request.
+func LoadImageFromReaderLimit(r io.Reader, maxBytes int64) (gocv.Mat, error) {
+	if maxBytes <= 0 {
+		return gocv.Mat{}, fmt.Errorf("max bytes must be positive, got %d", maxBytes)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return gocv.Mat{}, fmt.Errorf("failed to read image: %v", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return gocv.Mat{}, fmt.Errorf("%w: more than %d bytes", ErrImageTooLarge, maxBytes)
+	}
+
+	return LoadImageFromBytes(data)
+}
+
 // LoadImageFromStdImage converts standard Go image.Image to gocv.Mat
 func LoadImageFromStdImage(img image.Image) (gocv.Mat, error) {
 	bounds := img.Bounds()
@@ -84,13 +413,40 @@ func LoadImageFromStdImage(img image.Image) (gocv.Mat, error) {
 	return mat, nil
 }
 
-// SaveImage saves a Mat to file
+// PNGNoCompression stores PNG data uncompressed (ImageWriteParams.PNGCompression)
+const PNGNoCompression = -1
+
+// ImageWriteParams controls encoder settings for SaveImageWithParams. Zero
+// values keep the OpenCV defaults; a setting may only be used with its own
+// format.
+type ImageWriteParams struct {
+	JPEGQuality    int // JPEG quality 1-100, higher is better (0 = default 95)
+	PNGCompression int // PNG compression level 1-9, or PNGNoCompression (0 = default)
+}
+
+// SaveImage saves a Mat to file with the default encoder settings
 func SaveImage(filepath string, img gocv.Mat) error {
-	if !IsSupportedImageFormat(filepath) {
+	return SaveImageWithParams(filepath, img, ImageWriteParams{})
+}
+
+// SaveImageWithParams saves a Mat to file with the given encoder settings
+func SaveImageWithParams(filepath string, img gocv.Mat, params ImageWriteParams) error {
+	if !IsSupportedImageFormat(filepath) || isHEIFFormat(filepath) {
 		return fmt.Errorf("unsupported image format: %s", filepath)
 	}
 
-	success := gocv.IMWrite(filepath, img)
+	flags, err := params.flags(filepath)
+	if err != nil {
+		return err
+	}
+
+	// IMWriteWithParams indexes the first flag, so it needs at least one
+	var success bool
+	if len(flags) == 0 {
+		success = gocv.IMWrite(filepath, img)
+	} else {
+		success = gocv.IMWriteWithParams(filepath, img, flags)
+	}
 	if !success {
 		return fmt.Errorf("failed to save image: %s", filepath)
 	}
@@ -98,11 +454,68 @@ func SaveImage(filepath string, img gocv.Mat) error {
 	return nil
 }
 
-// GetImageInfo returns information about an image file
+// flags validates the params against the file format and returns them as
+// IMWrite flag/value pairs
+func (p ImageWriteParams) flags(filename string) ([]int, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	var flags []int
+
+	if p.JPEGQuality != 0 {
+		if p.JPEGQuality < 0 || p.JPEGQuality > 100 {
+			return nil, fmt.Errorf("JPEG quality must be between 1 and 100, got %d", p.JPEGQuality)
+		}
+		if ext != ".jpg" && ext != ".jpeg" {
+			return nil, fmt.Errorf("JPEG quality cannot be used with %s files", ext)
+		}
+		flags = append(flags, gocv.IMWriteJpegQuality, p.JPEGQuality)
+	}
+
+	if p.PNGCompression != 0 {
+		level := p.PNGCompression
+		if level == PNGNoCompression {
+			level = 0
+		} else if level < 1 || level > 9 {
+			return nil, fmt.Errorf("PNG compression must be between 1 and 9 (or PNGNoCompression), got %d", p.PNGCompression)
+		}
+		if ext != ".png" {
+			return nil, fmt.Errorf("PNG compression cannot be used with %s files", ext)
+		}
+		flags = append(flags, gocv.IMWritePngCompression, level)
+	}
+
+	return flags, nil
+}
+
+// GetImageInfo returns the size of an image file as LoadImage would load
+// it, i.e. upright per the EXIF orientation, and its channel count. Formats
+// registered in the image package (always JPEG, PNG and GIF) are measured
+// from the header alone; others are fully decoded by OpenCV.
 func GetImageInfo(filepath string) (width, height, channels int, err error) {
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+	f, err := os.Open(filepath)
+	if os.IsNotExist(err) {
 		return 0, 0, 0, fmt.Errorf("file does not exist: %s", filepath)
 	}
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to read image: %v", err)
+	}
+	defer f.Close()
+
+	// Images are always loaded as 3-channel BGR
+	config, format, configErr := image.DecodeConfig(bufio.NewReader(f))
+	if configErr == nil {
+		width, height = config.Width, config.Height
+		if format == "jpeg" {
+			if _, err := f.Seek(0, io.SeekStart); err == nil && readJPEGOrientation(bufio.NewReader(f)) >= 5 {
+				// Orientations 5-8 transpose the image
+				width, height = height, width
+			}
+		}
+		return width, height, 3, nil
+	}
+
+	if isHEIFFormat(filepath) {
+		return 0, 0, 0, fmt.Errorf("failed to read image: %s: %v", filepath, configErr)
+	}
 
 	img := gocv.IMRead(filepath, gocv.IMReadColor)
 	if img.Empty() {
@@ -0,0 +1,412 @@
+package face
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"gocv.io/x/gocv"
+)
+
+// buildJPEGHeader builds the leading segments of a JPEG carrying an EXIF
+// orientation tag (orientation 0 omits the EXIF block)
+func buildJPEGHeader(order binary.ByteOrder, orientation uint16) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xFF, 0xD8})
+
+	// JFIF APP0 segment that must be skipped
+	buf.Write([]byte{0xFF, 0xE0, 0x00, 0x07, 'J', 'F', 'I', 'F', 0x00})
+
+	if orientation != 0 {
+		var tiff bytes.Buffer
+		if order == binary.LittleEndian {
+			tiff.WriteString("II")
+		} else {
+			tiff.WriteString("MM")
+		}
+		binary.Write(&tiff, order, uint16(42))
+		binary.Write(&tiff, order, uint32(8))
+		binary.Write(&tiff, order, uint16(1))
+		binary.Write(&tiff, order, uint16(0x0112))
+		binary.Write(&tiff, order, uint16(3))
+		binary.Write(&tiff, order, uint32(1))
+		binary.Write(&tiff, order, orientation)
+		binary.Write(&tiff, order, uint16(0))
+		binary.Write(&tiff, order, uint32(0))
+
+		payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+		buf.Write([]byte{0xFF, 0xE1})
+		binary.Write(&buf, binary.BigEndian, uint16(len(payload)+2))
+		buf.Write(payload)
+	}
+
+	// Start of scan
+	buf.Write([]byte{0xFF, 0xDA, 0x00, 0x02})
+	return buf.Bytes()
+}
+
+func TestReadJPEGOrientation(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected int
+	}{
+		{"Little-endian rotated 90 CW", buildJPEGHeader(binary.LittleEndian, 6), 6},
+		{"Big-endian rotated 180", buildJPEGHeader(binary.BigEndian, 3), 3},
+		{"Big-endian rotated 90 CCW", buildJPEGHeader(binary.BigEndian, 8), 8},
+		{"No EXIF block", buildJPEGHeader(binary.LittleEndian, 0), 1},
+		{"Out of range value", buildJPEGHeader(binary.LittleEndian, 9), 1},
+		{"Not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"Truncated", []byte{0xFF, 0xD8, 0xFF}, 1},
+		{"Empty", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readJPEGOrientation(bytes.NewReader(tt.data)); got != tt.expected {
+				t.Errorf("Expected orientation %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 4x2 image with one marked pixel at column 1, row 0
+	const width, height = 4, 2
+	src := gocv.Zeros(height, width, gocv.MatTypeCV8UC1)
+	defer src.Close()
+	src.SetUCharAt(0, 1, 255)
+
+	tests := []struct {
+		orientation int
+		cols, rows  int
+		x, y        int // Expected position of the marked pixel
+	}{
+		{1, 4, 2, 1, 0},
+		{2, 4, 2, 2, 0}, // Mirrored horizontally
+		{3, 4, 2, 2, 1}, // Rotated 180
+		{4, 4, 2, 1, 1}, // Mirrored vertically
+		{5, 2, 4, 0, 1}, // Transposed: (x, y) -> (y, x)
+		{6, 2, 4, 1, 1}, // Rotated 90 CW: (x, y) -> (h-1-y, x)
+		{7, 2, 4, 1, 2}, // Transversed: (x, y) -> (h-1-y, w-1-x)
+		{8, 2, 4, 0, 2}, // Rotated 90 CCW: (x, y) -> (y, w-1-x)
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("Orientation %d", tt.orientation), func(t *testing.T) {
+			dst, err := applyOrientation(src, tt.orientation)
+			if err != nil {
+				t.Fatalf("Failed to apply orientation: %v", err)
+			}
+			defer dst.Close()
+
+			if dst.Cols() != tt.cols || dst.Rows() != tt.rows {
+				t.Fatalf("Expected %dx%d, got %dx%d", tt.cols, tt.rows, dst.Cols(), dst.Rows())
+			}
+			for y := 0; y < dst.Rows(); y++ {
+				for x := 0; x < dst.Cols(); x++ {
+					marked := dst.GetUCharAt(y, x) == 255
+					if marked != (x == tt.x && y == tt.y) {
+						t.Errorf("Pixel (%d,%d): expected marked=%v", x, y, !marked)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLoadImage_EXIFOrientation(t *testing.T) {
+	const width, height = 40, 20
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+
+	// Splice the APP1 block of a "rotated 90 CW" header in after SOI
+	header := buildJPEGHeader(binary.LittleEndian, 6)
+	start := bytes.Index(header, []byte{0xFF, 0xE1})
+	app1 := header[start : start+2+int(binary.BigEndian.Uint16(header[start+2:]))]
+	data := append(append(append([]byte{}, encoded.Bytes()[:2]...), app1...), encoded.Bytes()[2:]...)
+
+	path := filepath.Join(t.TempDir(), "rotated.jpg")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write JPEG: %v", err)
+	}
+
+	if w, h, _, err := GetImageInfo(path); err != nil || w != height || h != width {
+		t.Errorf("Expected GetImageInfo to report %dx%d, got %dx%d, %v", height, width, w, h, err)
+	}
+
+	img, err := LoadImage(path)
+	if err != nil {
+		t.Fatalf("Failed to load image: %v", err)
+	}
+	defer img.Close()
+	if img.Cols() != height || img.Rows() != width {
+		t.Errorf("Expected an upright %dx%d image, got %dx%d", height, width, img.Cols(), img.Rows())
+	}
+
+	raw, err := LoadImageNoAutoRotate(path)
+	if err != nil {
+		t.Fatalf("Failed to load image: %v", err)
+	}
+	defer raw.Close()
+	if raw.Cols() != width || raw.Rows() != height {
+		t.Errorf("Expected the stored %dx%d image, got %dx%d", width, height, raw.Cols(), raw.Rows())
+	}
+}
+
+func TestImageWriteParams_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		params   ImageWriteParams
+		expected []int
+		wantErr  bool
+	}{
+		{"Defaults", "out.jpg", ImageWriteParams{}, nil, false},
+		{"JPEG quality", "out.JPEG", ImageWriteParams{JPEGQuality: 80}, []int{gocv.IMWriteJpegQuality, 80}, false},
+		{"PNG compression", "out.png", ImageWriteParams{PNGCompression: 9}, []int{gocv.IMWritePngCompression, 9}, false},
+		{"PNG uncompressed", "out.png", ImageWriteParams{PNGCompression: PNGNoCompression}, []int{gocv.IMWritePngCompression, 0}, false},
+		{"JPEG quality too high", "out.jpg", ImageWriteParams{JPEGQuality: 101}, nil, true},
+		{"PNG compression too high", "out.png", ImageWriteParams{PNGCompression: 10}, nil, true},
+		{"JPEG quality on PNG", "out.png", ImageWriteParams{JPEGQuality: 80}, nil, true},
+		{"PNG compression on JPEG", "out.jpg", ImageWriteParams{PNGCompression: 3}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := tt.params.flags(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(flags, tt.expected) {
+				t.Errorf("Expected flags %v, got %v", tt.expected, flags)
+			}
+		})
+	}
+}
+
+func TestSaveImageWithParams_JPEGQuality(t *testing.T) {
+	img := createTestImage(200, 200)
+	defer img.Close()
+
+	dir := t.TempDir()
+	sizes := make(map[int]int64)
+	for _, quality := range []int{10, 95} {
+		path := filepath.Join(dir, fmt.Sprintf("q%d.jpg", quality))
+		if err := SaveImageWithParams(path, img, ImageWriteParams{JPEGQuality: quality}); err != nil {
+			t.Fatalf("Failed to save image: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat image: %v", err)
+		}
+		sizes[quality] = info.Size()
+	}
+
+	if sizes[10] >= sizes[95] {
+		t.Errorf("Expected lower quality to produce a smaller file, got %d >= %d bytes", sizes[10], sizes[95])
+	}
+}
+
+func TestLoadImage_FormatRoundTrip(t *testing.T) {
+	const width, height = 32, 24
+	fill := color.RGBA{200, 120, 40, 255}
+
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(src.Pix); i += 4 {
+		copy(src.Pix[i:i+4], []uint8{fill.R, fill.G, fill.B, fill.A})
+	}
+	mat, err := LoadImageFromStdImage(src)
+	if err != nil {
+		t.Fatalf("Failed to convert image: %v", err)
+	}
+	defer mat.Close()
+
+	dir := t.TempDir()
+	for _, ext := range SupportedImageFormats {
+		if isHEIFFormat(ext) {
+			continue // Load-only, see TestLoadImage_HEIC
+		}
+
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(dir, "image"+ext)
+			if ext == ".gif" {
+				// A single-color palette keeps the quantizer from dithering
+				paletted := image.NewPaletted(src.Bounds(), color.Palette{fill})
+				var buf bytes.Buffer
+				if err := gif.Encode(&buf, paletted, nil); err != nil {
+					t.Fatalf("Failed to encode GIF: %v", err)
+				}
+				if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+					t.Fatalf("Failed to write GIF: %v", err)
+				}
+			} else if err := SaveImage(path, mat); err != nil {
+				t.Fatalf("Failed to save image: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read image: %v", err)
+			}
+
+			for name, load := range map[string]func() (gocv.Mat, error){
+				"LoadImage":          func() (gocv.Mat, error) { return LoadImage(path) },
+				"LoadImageFromBytes": func() (gocv.Mat, error) { return LoadImageFromBytes(data) },
+			} {
+				loaded, err := load()
+				if err != nil {
+					t.Fatalf("%s failed: %v", name, err)
+				}
+
+				if loaded.Cols() != width || loaded.Rows() != height {
+					t.Errorf("%s: expected %dx%d, got %dx%d", name, width, height, loaded.Cols(), loaded.Rows())
+				} else {
+					// Lossy formats (JPEG, WebP) may shift the color slightly
+					bgr := []uint8{fill.B, fill.G, fill.R}
+					for c, expected := range bgr {
+						got := loaded.GetUCharAt(height/2, (width/2)*3+c)
+						if diff := int(got) - int(expected); diff < -8 || diff > 8 {
+							t.Errorf("%s: channel %d expected ~%d, got %d", name, c, expected, got)
+						}
+					}
+				}
+				loaded.Close()
+			}
+		})
+	}
+}
+
+func TestLoadImageFromReader(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Over the limit: rejected before decoding
+	_, err := LoadImageFromReaderLimit(bytes.NewReader(data), int64(len(data)-1))
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected ErrImageTooLarge, got %v", err)
+	}
+
+	if _, err := LoadImageFromReaderLimit(bytes.NewReader(data), 0); err == nil {
+		t.Error("Expected error for a non-positive limit")
+	}
+
+	if _, err := LoadImageFromReader(iotest.ErrReader(errors.New("connection reset"))); err == nil {
+		t.Error("Expected read error to be returned")
+	}
+
+	for name, load := range map[string]func() (gocv.Mat, error){
+		"Unlimited":     func() (gocv.Mat, error) { return LoadImageFromReader(bytes.NewReader(data)) },
+		"Exactly limit": func() (gocv.Mat, error) { return LoadImageFromReaderLimit(bytes.NewReader(data), int64(len(data))) },
+	} {
+		img, err := load()
+		if err != nil {
+			t.Fatalf("%s: failed to load image: %v", name, err)
+		}
+		if img.Cols() != 16 || img.Rows() != 8 {
+			t.Errorf("%s: expected 16x8, got %dx%d", name, img.Cols(), img.Rows())
+		}
+		img.Close()
+	}
+}
+
+func TestMaxImagePixels(t *testing.T) {
+	original := MaxImagePixels
+	defer func() { MaxImagePixels = original }()
+
+	// A GIF header declaring 65535x65535 pixels, with no image data
+	bomb := []byte{'G', 'I', 'F', '8', '9', 'a', 0xff, 0xff, 0xff, 0xff, 0, 0, 0}
+	if _, err := LoadImageFromBytes(bomb); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected ErrImageTooLarge for declared dimensions, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "bomb.gif")
+	if err := os.WriteFile(path, bomb, 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	if _, err := LoadImage(path); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected ErrImageTooLarge from LoadImage, got %v", err)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+
+	MaxImagePixels = 16*8 - 1
+	if _, err := LoadImageFromReader(bytes.NewReader(buf.Bytes())); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected ErrImageTooLarge below the image size, got %v", err)
+	}
+
+	for _, limit := range []int64{16 * 8, 0} {
+		MaxImagePixels = limit
+		if err := checkImagePixels(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Errorf("Limit %d: expected image to pass, got %v", limit, err)
+		}
+	}
+}
+
+func TestGetImageInfo(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	dir := t.TempDir()
+
+	var pngData, gifData, jpegData bytes.Buffer
+	png.Encode(&pngData, src)
+	gif.Encode(&gifData, src, nil)
+	jpeg.Encode(&jpegData, src, nil)
+
+	// Splice an EXIF block rotating the image 90 CW in after the SOI marker
+	header := buildJPEGHeader(binary.LittleEndian, 6)
+	rotated := append(append([]byte{}, header[:len(header)-4]...), jpegData.Bytes()[2:]...)
+
+	tests := []struct {
+		name   string
+		file   string
+		data   []byte
+		width  int
+		height int
+	}{
+		{"PNG", "a.png", pngData.Bytes(), 40, 30},
+		{"GIF", "a.gif", gifData.Bytes(), 40, 30},
+		{"JPEG", "a.jpg", jpegData.Bytes(), 40, 30},
+		{"Rotated JPEG", "rotated.jpg", rotated, 30, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			width, height, channels, err := GetImageInfo(path)
+			if err != nil {
+				t.Fatalf("Failed to get image info: %v", err)
+			}
+			if width != tt.width || height != tt.height || channels != 3 {
+				t.Errorf("Expected %dx%dx3, got %dx%dx%d", tt.width, tt.height, width, height, channels)
+			}
+		})
+	}
+
+	if _, _, _, err := GetImageInfo(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
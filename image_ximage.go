@@ -0,0 +1,17 @@
+//go:build ximage
+
+package face
+
+// Pure Go BMP, TIFF and WebP decoders for the fallback used when OpenCV
+// cannot decode a file (see decodeStdImage). WebP decoding is lossy and
+// lossless, but not animated.
+//
+// They are only compiled with the "ximage" build tag so default builds don't
+// link golang.org/x/image:
+//
+//	go build -tags ximage
+import (
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
@@ -0,0 +1,89 @@
+//go:build ximage
+
+package face
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func TestXImage_FormatRoundTrip(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i, c := range []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 0, 255}, {0, 255, 255, 255}, {255, 255, 255, 255}} {
+		src.SetRGBA(i%3, i/3, c)
+	}
+
+	var bmpData, tiffData bytes.Buffer
+	if err := bmp.Encode(&bmpData, src); err != nil {
+		t.Fatalf("Failed to encode BMP: %v", err)
+	}
+	if err := tiff.Encode(&tiffData, src, nil); err != nil {
+		t.Fatalf("Failed to encode TIFF: %v", err)
+	}
+	// x/image has no WebP encoder; a 1x1 transparent lossless WebP
+	webpData := []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+
+	tests := []struct {
+		ext    string
+		format string
+		data   []byte
+		want   image.Image
+	}{
+		{".bmp", "bmp", bmpData.Bytes(), src},
+		{".tiff", "tiff", tiffData.Bytes(), src},
+		{".webp", "webp", webpData, image.NewRGBA(image.Rect(0, 0, 1, 1))},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			img, format, err := image.Decode(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Failed to decode: %v", err)
+			}
+			if format != tt.format {
+				t.Errorf("Expected format %s, got %s", tt.format, format)
+			}
+			if img.Bounds() != tt.want.Bounds() {
+				t.Fatalf("Expected bounds %v, got %v", tt.want.Bounds(), img.Bounds())
+			}
+			for y := 0; y < img.Bounds().Dy(); y++ {
+				for x := 0; x < img.Bounds().Dx(); x++ {
+					got := color.RGBAModel.Convert(img.At(x, y))
+					want := color.RGBAModel.Convert(tt.want.At(x, y))
+					if got != want {
+						t.Errorf("Pixel (%d,%d): expected %v, got %v", x, y, want, got)
+					}
+				}
+			}
+
+			// The header is read without OpenCV
+			path := filepath.Join(dir, "image"+tt.ext)
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatalf("Failed to write image: %v", err)
+			}
+			width, height, _, err := GetImageInfo(path)
+			if err != nil || width != tt.want.Bounds().Dx() || height != tt.want.Bounds().Dy() {
+				t.Errorf("Expected %v from GetImageInfo, got %dx%d, %v", tt.want.Bounds().Size(), width, height, err)
+			}
+
+			if width*height < 2 {
+				return // A limit of 0 disables the check
+			}
+			original := MaxImagePixels
+			defer func() { MaxImagePixels = original }()
+			MaxImagePixels = int64(width*height) - 1
+			if err := checkImagePixels(bytes.NewReader(tt.data)); !errors.Is(err, ErrImageTooLarge) {
+				t.Errorf("Expected ErrImageTooLarge, got %v", err)
+			}
+		})
+	}
+}
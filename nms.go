@@ -0,0 +1,59 @@
+package face
+
+import (
+	"image"
+	"sort"
+)
+
+// IoU returns the intersection over union of two rectangles, from 0
+// (disjoint or empty) to 1 (identical)
+func IoU(a, b image.Rectangle) float64 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+
+	interArea := inter.Dx() * inter.Dy()
+	union := a.Dx()*a.Dy() + b.Dx()*b.Dy() - interArea
+	return float64(interArea) / float64(union)
+}
+
+// NonMaxSuppression de-duplicates the boxes of an external detector (e.g.
+// YOLO-face) before RecognizeRegions, like the clustering Pigo detections
+// get. Boxes are visited by descending score, and a box is dropped when its
+// IoU with an already kept box is above iouThreshold (e.g. 0.3-0.5). It
+// returns the indices of the kept boxes, highest score first; boxes with
+// equal scores keep their input order. Boxes without a score count as
+// scoring 0, and extra scores are ignored.
+func NonMaxSuppression(boxes []image.Rectangle, scores []float32, iouThreshold float64) []int {
+	score := func(i int) float32 {
+		if i < len(scores) {
+			return scores[i]
+		}
+		return 0
+	}
+
+	order := make([]int, len(boxes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return score(order[i]) > score(order[j])
+	})
+
+	kept := make([]int, 0, len(boxes))
+	for _, i := range order {
+		duplicate := false
+		for _, k := range kept {
+			if IoU(boxes[i], boxes[k]) > iouThreshold {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			kept = append(kept, i)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,65 @@
+package face
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestIoU(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     image.Rectangle
+		expected float64
+	}{
+		{"Identical", image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 10), 1},
+		{"Half overlap", image.Rect(0, 0, 10, 10), image.Rect(5, 0, 15, 10), 50.0 / 150.0},
+		{"Contained", image.Rect(0, 0, 10, 10), image.Rect(0, 0, 5, 5), 0.25},
+		{"Disjoint", image.Rect(0, 0, 10, 10), image.Rect(20, 20, 30, 30), 0},
+		{"Empty", image.Rect(0, 0, 10, 10), image.Rectangle{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IoU(tt.a, tt.b); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNonMaxSuppression(t *testing.T) {
+	boxes := []image.Rectangle{
+		image.Rect(0, 0, 10, 10),
+		image.Rect(1, 1, 11, 11), // IoU 81/119 with box 0
+		image.Rect(50, 50, 60, 60),
+		image.Rect(5, 0, 15, 10), // IoU 1/3 with box 0
+	}
+
+	tests := []struct {
+		name      string
+		scores    []float32
+		threshold float64
+		expected  []int
+	}{
+		{"Strongest of overlapping boxes", []float32{0.9, 0.8, 0.7, 0.6}, 0.5, []int{0, 2, 3}},
+		{"Weaker box listed first", []float32{0.8, 0.9, 0.7, 0.6}, 0.5, []int{1, 2, 3}},
+		{"Strict threshold", []float32{0.9, 0.8, 0.7, 0.6}, 0.3, []int{0, 2}},
+		{"Equal scores keep input order", []float32{0.5, 0.5, 0.5, 0.5}, 0.5, []int{0, 2, 3}},
+		{"Threshold 1 keeps all", []float32{0.9, 0.8, 0.7, 0.6}, 1, []int{0, 1, 2, 3}},
+		{"Missing scores count as 0", []float32{0.1, 0.9}, 0.5, []int{1, 2, 3}},
+		{"Extra scores ignored", []float32{0.9, 0.8, 0.7, 0.6, 1}, 0.5, []int{0, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NonMaxSuppression(boxes, tt.scores, tt.threshold); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	if got := NonMaxSuppression(nil, nil, 0.5); len(got) != 0 {
+		t.Errorf("Expected no boxes, got %v", got)
+	}
+}
@@ -0,0 +1,94 @@
+package face
+
+import (
+	"gocv.io/x/gocv"
+)
+
+// minReferenceSkin is the fraction of skin pixels the upper face needs for
+// EstimateOcclusion to trust the skin tone comparison
+const minReferenceSkin = 0.15
+
+// WithMaxOcclusion makes recognition and enrollment reject faces whose
+// EstimateOcclusion score is above maxOcclusion (e.g. 0.6), such as faces
+// behind a mask. Recognize reports them as unknown with zero confidence and
+// Occlusion set, without extracting a feature; AddFaceSample fails with
+// ErrOccludedFace. 0 disables the check (default).
+func WithMaxOcclusion(maxOcclusion float64) Option {
+	return func(fr *FaceRecognizer) {
+		fr.maxOcclusion = maxOcclusion
+	}
+}
+
+// EstimateOcclusion estimates how much of the lower face (mouth and chin) of
+// a face crop is covered, from 0 (uncovered) to 1 (fully covered), by
+// comparing its skin coverage with that of the upper face (forehead and
+// eyes). It is a heuristic for flagging masked faces: skin-colored masks and
+// hands go unnoticed, and grayscale crops or crops whose upper face shows
+// too little skin (e.g. unusual lighting) score 0.
+func (fr *FaceRecognizer) EstimateOcclusion(faceCrop gocv.Mat) float64 {
+	if faceCrop.Empty() || (faceCrop.Type() != gocv.MatTypeCV8UC3 && faceCrop.Type() != gocv.MatTypeCV8UC4) {
+		return 0
+	}
+
+	bgr := gocv.NewMat()
+	defer bgr.Close()
+
+	rgb := fr.colorOrder == ColorOrderRGB
+	var err error
+	switch {
+	case faceCrop.Channels() == 4 && rgb:
+		err = gocv.CvtColor(faceCrop, &bgr, gocv.ColorRGBAToBGR)
+	case faceCrop.Channels() == 4:
+		err = gocv.CvtColor(faceCrop, &bgr, gocv.ColorBGRAToBGR)
+	case rgb:
+		err = gocv.CvtColor(faceCrop, &bgr, gocv.ColorRGBToBGR)
+	default:
+		// Copy so the buffer is continuous even for ROIs
+		err = faceCrop.CopyTo(&bgr)
+	}
+	if err != nil {
+		return 0
+	}
+
+	return occlusionScore(bgr.ToBytes(), bgr.Cols(), bgr.Rows())
+}
+
+// occlusionScore compares the skin coverage of the lower face with the upper
+// face of a BGR pixel buffer, see EstimateOcclusion
+func occlusionScore(pixels []uint8, width, height int) float64 {
+	// skinFraction returns the fraction of skin pixels in the band between
+	// the given fractions of the crop's height and width
+	skinFraction := func(top, bottom, left, right float64) float64 {
+		skin, n := 0, 0
+		for y := int(top * float64(height)); y < int(bottom*float64(height)); y++ {
+			for x := int(left * float64(width)); x < int(right*float64(width)); x++ {
+				i := (y*width + x) * 3
+				if isSkin(pixels[i], pixels[i+1], pixels[i+2]) {
+					skin++
+				}
+				n++
+			}
+		}
+		if n == 0 {
+			return 0
+		}
+		return float64(skin) / float64(n)
+	}
+
+	upper := skinFraction(0.1, 0.4, 0.2, 0.8)
+	if upper < minReferenceSkin {
+		return 0
+	}
+	lower := skinFraction(0.6, 0.9, 0.25, 0.75)
+
+	return max(0, min(1, 1-lower/upper))
+}
+
+// isSkin reports whether a BGR pixel falls in the skin range of the YCrCb
+// color space (Chai and Ngan), which holds across skin tones
+func isSkin(b, g, r uint8) bool {
+	y := 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+	cr := (float64(r)-y)*0.713 + 128
+	cb := (float64(b)-y)*0.564 + 128
+	return cr >= 133 && cr <= 173 && cb >= 77 && cb <= 127
+}
@@ -0,0 +1,132 @@
+package face
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	skinBGR = [3]uint8{120, 150, 200}
+	maskBGR = [3]uint8{230, 200, 180} // Light blue surgical mask
+)
+
+// faceCrop returns a BGR face crop filled with skin, with the given color
+// below the given fraction of its height
+func faceCrop(width, height int, from float64, lower [3]uint8) []uint8 {
+	pixels := make([]uint8, width*height*3)
+	for y := 0; y < height; y++ {
+		color := skinBGR
+		if float64(y) >= from*float64(height) {
+			color = lower
+		}
+		for x := 0; x < width; x++ {
+			copy(pixels[(y*width+x)*3:], color[:])
+		}
+	}
+	return pixels
+}
+
+func TestOcclusionScore(t *testing.T) {
+	const width, height = 80, 100
+	gray := [3]uint8{128, 128, 128}
+
+	tests := []struct {
+		name     string
+		pixels   []uint8
+		min, max float64
+	}{
+		{"Uncovered", faceCrop(width, height, 1, skinBGR), 0, 0.05},
+		{"Masked", faceCrop(width, height, 0.5, maskBGR), 0.95, 1},
+		{"Half covered", faceCrop(width, height, 0.75, maskBGR), 0.4, 0.6},
+		{"No skin reference", faceCrop(width, height, 0, gray), 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := occlusionScore(tt.pixels, width, height)
+			if score < tt.min || score > tt.max {
+				t.Errorf("Expected occlusion in [%.2f, %.2f], got %.3f", tt.min, tt.max, score)
+			}
+		})
+	}
+}
+
+func TestEstimateOcclusion(t *testing.T) {
+	const width, height = 80, 100
+	masked, err := gocv.NewMatFromBytes(height, width, gocv.MatTypeCV8UC3, faceCrop(width, height, 0.5, maskBGR))
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	defer masked.Close()
+
+	fr := &FaceRecognizer{}
+	if score := fr.EstimateOcclusion(masked); score < 0.95 {
+		t.Errorf("Expected masked face to score near 1, got %.3f", score)
+	}
+
+	// The same pixels read as RGB are no longer skin colored
+	WithColorOrder(ColorOrderRGB)(fr)
+	if score := fr.EstimateOcclusion(masked); score != 0 {
+		t.Errorf("Expected 0 without a skin reference, got %.3f", score)
+	}
+
+	gray := gocv.NewMatWithSize(height, width, gocv.MatTypeCV8UC1)
+	defer gray.Close()
+	if score := fr.EstimateOcclusion(gray); score != 0 {
+		t.Errorf("Expected 0 for a grayscale crop, got %.3f", score)
+	}
+}
+
+func TestCheckEnrollmentQuality_Occlusion(t *testing.T) {
+	const width, height = 80, 100
+	masked, err := gocv.NewMatFromBytes(height, width, gocv.MatTypeCV8UC3, faceCrop(width, height, 0.5, maskBGR))
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	defer masked.Close()
+
+	fr := &FaceRecognizer{}
+	if err := fr.checkEnrollmentQuality(masked); err != nil {
+		t.Errorf("Expected no check by default, got %v", err)
+	}
+
+	WithMaxOcclusion(0.6)(fr)
+	if err := fr.checkEnrollmentQuality(masked); !errors.Is(err, ErrOccludedFace) {
+		t.Errorf("Expected ErrOccludedFace, got %v", err)
+	}
+}
+
+func TestRecognizeFaces_Occluded(t *testing.T) {
+	fr := &FaceRecognizer{
+		persons: map[string]*Person{
+			"001": {ID: "001", Name: "Alice", Features: []FaceFeature{{PersonID: "001", Feature: []float32{1, 0}}}},
+		},
+		threshold:   0.6,
+		unknownID:   DefaultUnknownID,
+		unknownName: DefaultUnknownName,
+	}
+
+	faces := []extractedFace{
+		{Detection: Detection{Rect: image.Rect(0, 0, 10, 10)}, feature: []float32{1, 0}, occlusion: 0.1},
+		{Detection: Detection{Rect: image.Rect(20, 0, 30, 10)}, occlusion: 0.9, occluded: true},
+	}
+
+	results, err := fr.recognizeFaces(context.Background(), faces)
+	if err != nil {
+		t.Fatalf("Recognition failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	if results[0].PersonID != "001" || results[0].Occlusion != 0.1 {
+		t.Errorf("Expected Alice with occlusion 0.1, got %+v", results[0])
+	}
+	if results[1].PersonID != DefaultUnknownID || results[1].Confidence != 0 || results[1].Occlusion != 0.9 {
+		t.Errorf("Expected occluded face as unknown with zero confidence, got %+v", results[1])
+	}
+}
@@ -0,0 +1,88 @@
+package face
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RecognizerStats reports the operational counters of a recognizer since it
+// was created or ResetStats was last called, see FaceRecognizer.Stats
+type RecognizerStats struct {
+	Detections         uint64        // Detector runs, one per image or frame
+	FacesDetected      uint64        // Faces found by those runs
+	Extractions        uint64        // Features extracted by ExtractFeature or ExtractFeatures (cache hits included)
+	ExtractionFailures uint64        // Faces whose feature extraction failed
+	Matches            uint64        // Match searches whose best person is above the threshold
+	NonMatches         uint64        // Match searches below the threshold (unknown faces)
+	AvgMatchLatency    time.Duration // Mean duration of a match search
+}
+
+// recognizerStats holds the counters behind RecognizerStats. Every counter
+// is updated with a single atomic add so recording stays off the locks of
+// the hot path.
+type recognizerStats struct {
+	detections         atomic.Uint64
+	facesDetected      atomic.Uint64
+	extractions        atomic.Uint64
+	extractionFailures atomic.Uint64
+	matches            atomic.Uint64
+	nonMatches         atomic.Uint64
+	matchNanos         atomic.Uint64 // Total duration of all match searches
+}
+
+// recordDetection counts a detector run that found faces faces
+func (s *recognizerStats) recordDetection(faces int) {
+	s.detections.Add(1)
+	s.facesDetected.Add(uint64(faces))
+}
+
+// recordExtractions counts n feature extractions that succeeded, or failed
+// if err is set
+func (s *recognizerStats) recordExtractions(n int, err error) {
+	if err != nil {
+		s.extractionFailures.Add(uint64(n))
+		return
+	}
+	s.extractions.Add(uint64(n))
+}
+
+// recordMatch counts a match search that took elapsed
+func (s *recognizerStats) recordMatch(matched bool, elapsed time.Duration) {
+	if matched {
+		s.matches.Add(1)
+	} else {
+		s.nonMatches.Add(1)
+	}
+	s.matchNanos.Add(uint64(elapsed))
+}
+
+// Stats returns the operational counters of the recognizer. Counters are
+// read one by one, so a snapshot taken during concurrent calls may be off
+// by the calls in flight.
+func (fr *FaceRecognizer) Stats() RecognizerStats {
+	s := &fr.stats
+	stats := RecognizerStats{
+		Detections:         s.detections.Load(),
+		FacesDetected:      s.facesDetected.Load(),
+		Extractions:        s.extractions.Load(),
+		ExtractionFailures: s.extractionFailures.Load(),
+		Matches:            s.matches.Load(),
+		NonMatches:         s.nonMatches.Load(),
+	}
+	if searches := stats.Matches + stats.NonMatches; searches > 0 {
+		stats.AvgMatchLatency = time.Duration(s.matchNanos.Load() / searches)
+	}
+	return stats
+}
+
+// ResetStats sets all counters reported by Stats back to zero
+func (fr *FaceRecognizer) ResetStats() {
+	s := &fr.stats
+	s.detections.Store(0)
+	s.facesDetected.Store(0)
+	s.extractions.Store(0)
+	s.extractionFailures.Store(0)
+	s.matches.Store(0)
+	s.nonMatches.Store(0)
+	s.matchNanos.Store(0)
+}
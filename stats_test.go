@@ -0,0 +1,73 @@
+package face
+
+import (
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestStats(t *testing.T) {
+	fr := &FaceRecognizer{
+		persons: map[string]*Person{
+			"001": {ID: "001", Name: "Alice", Features: []FaceFeature{{PersonID: "001", Feature: []float32{1, 0}}}},
+		},
+		threshold: 0.6,
+	}
+
+	if stats := fr.Stats(); stats != (RecognizerStats{}) {
+		t.Errorf("Expected zero stats, got %+v", stats)
+	}
+
+	fr.bestMatch([]float32{1, 0}) // Alice
+	fr.bestMatch([]float32{0, 1}) // Unknown
+	fr.bestMatch([]float32{0.8, 0.6})
+
+	// Without an encoder every extraction fails
+	face := gocv.NewMatWithSize(96, 96, gocv.MatTypeCV8UC3)
+	defer face.Close()
+	fr.ExtractFeature(face)
+	fr.ExtractFeatures([]gocv.Mat{face, face})
+
+	fr.stats.recordDetection(3)
+	fr.stats.recordDetection(0)
+
+	stats := fr.Stats()
+	if stats.Matches != 2 || stats.NonMatches != 1 {
+		t.Errorf("Expected 2 matches and 1 non-match, got %d and %d", stats.Matches, stats.NonMatches)
+	}
+	if stats.AvgMatchLatency <= 0 {
+		t.Errorf("Expected a positive match latency, got %v", stats.AvgMatchLatency)
+	}
+	if stats.Extractions != 0 || stats.ExtractionFailures != 3 {
+		t.Errorf("Expected 0 extractions and 3 failures, got %d and %d", stats.Extractions, stats.ExtractionFailures)
+	}
+	if stats.Detections != 2 || stats.FacesDetected != 3 {
+		t.Errorf("Expected 2 detections with 3 faces, got %d with %d", stats.Detections, stats.FacesDetected)
+	}
+
+	fr.ResetStats()
+	if stats := fr.Stats(); stats != (RecognizerStats{}) {
+		t.Errorf("Expected zero stats after reset, got %+v", stats)
+	}
+}
+
+func TestStats_Detection(t *testing.T) {
+	skipIfModelsNotAvailable(t)
+
+	detector, err := NewFaceDetector("./testdata/facefinder")
+	if err != nil {
+		t.Skipf("Skip test (model files not available): %v", err)
+		return
+	}
+	defer detector.Close()
+
+	img := createTestImage(200, 200)
+	defer img.Close()
+
+	detector.DetectFacesFromMat(img)
+	detector.DetectFacesFromMat(img)
+
+	if stats := detector.Stats(); stats.Detections != 2 {
+		t.Errorf("Expected 2 detections, got %d", stats.Detections)
+	}
+}
@@ -0,0 +1,158 @@
+//go:build bolt
+
+package face
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltPersonsBucket is the bucket holding one JSON value per person ID
+var boltPersonsBucket = []byte("persons")
+
+// BoltStorage implements embedded key/value storage on bbolt (persistent,
+// crash-safe, no external server)
+//
+// It is only compiled with the "bolt" build tag so default builds don't
+// link bbolt:
+//
+//	go build -tags bolt
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// NewBoltStorage opens (or creates) a bbolt database file. bbolt allows a
+// single process to hold the file; opening fails after a second if another
+// process has it open.
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltPersonsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create bucket: %v", err)
+	}
+
+	return &BoltStorage{db: db}, nil
+}
+
+func (s *BoltStorage) SavePerson(person *Person) error {
+	data, err := json.Marshal(person)
+	if err != nil {
+		return fmt.Errorf("failed to marshal person: %v", err)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltPersonsBucket).Put([]byte(person.ID), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save person: %v", err)
+	}
+
+	return nil
+}
+
+func (s *BoltStorage) LoadPerson(id string) (*Person, error) {
+	var person *Person
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltPersonsBucket).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+		}
+
+		// data is only valid inside the transaction; Unmarshal copies it
+		person = &Person{}
+		if err := json.Unmarshal(data, person); err != nil {
+			return fmt.Errorf("failed to unmarshal person: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return person, nil
+}
+
+func (s *BoltStorage) LoadAllPersons() ([]*Person, error) {
+	persons := make([]*Person, 0)
+	err := s.IterPersons(func(person *Person) error {
+		persons = append(persons, person)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return persons, nil
+}
+
+// IterPersons walks the bucket in ID order inside a single read transaction
+func (s *BoltStorage) IterPersons(fn func(*Person) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltPersonsBucket).ForEach(func(_, data []byte) error {
+			var person Person
+			if err := json.Unmarshal(data, &person); err != nil {
+				// Skip corrupted entries
+				return nil
+			}
+			return fn(&person)
+		})
+	})
+}
+
+func (s *BoltStorage) DeletePerson(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltPersonsBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+		}
+		if err := bucket.Delete([]byte(id)); err != nil {
+			return fmt.Errorf("failed to delete person: %v", err)
+		}
+		return nil
+	})
+}
+
+func (s *BoltStorage) PersonExists(id string) (bool, error) {
+	exists := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(boltPersonsBucket).Get([]byte(id)) != nil
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check person: %v", err)
+	}
+
+	return exists, nil
+}
+
+// Clear drops and recreates the persons bucket
+func (s *BoltStorage) Clear() error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltPersonsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(boltPersonsBucket)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear persons: %v", err)
+	}
+
+	return nil
+}
+
+// Close closes the database file
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
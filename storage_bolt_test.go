@@ -0,0 +1,83 @@
+//go:build bolt
+
+package face
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var _ FaceStorage = (*BoltStorage)(nil)
+
+func TestBoltStorage_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.db")
+
+	storage, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create bolt storage: %v", err)
+	}
+
+	alice := &Person{
+		ID:       "001",
+		Name:     "Alice",
+		Features: []FaceFeature{{PersonID: "001", Feature: []float32{0.1, 0.2, 0.3}}},
+		Metadata: map[string]string{"department": "R&D"},
+	}
+	for _, person := range []*Person{alice, {ID: "002", Name: "Bob"}} {
+		if err := storage.SavePerson(person); err != nil {
+			t.Fatalf("Failed to save person: %v", err)
+		}
+	}
+	if err := storage.DeletePerson("002"); err != nil {
+		t.Fatalf("Failed to delete person: %v", err)
+	}
+	if err := storage.DeletePerson("002"); err == nil {
+		t.Error("Expected error when deleting a missing person")
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Failed to close storage: %v", err)
+	}
+
+	// Reopen the file and verify the data survived
+	storage, err = NewBoltStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen bolt storage: %v", err)
+	}
+	defer storage.Close()
+
+	loaded, err := storage.LoadPerson("001")
+	if err != nil {
+		t.Fatalf("Failed to load person: %v", err)
+	}
+	if loaded.Name != "Alice" || len(loaded.Features) != 1 || loaded.Features[0].Feature[2] != 0.3 {
+		t.Errorf("Loaded person does not match saved person: %+v", loaded)
+	}
+	if loaded.Metadata["department"] != "R&D" {
+		t.Errorf("Expected metadata to persist, got %v", loaded.Metadata)
+	}
+
+	if exists, _ := storage.PersonExists("002"); exists {
+		t.Error("Expected deleted person to stay deleted")
+	}
+	if _, err := storage.LoadPerson("002"); err == nil {
+		t.Error("Expected error when loading a missing person")
+	}
+
+	persons, err := storage.LoadAllPersons()
+	if err != nil {
+		t.Fatalf("Failed to load all persons: %v", err)
+	}
+	if len(persons) != 1 || persons[0].ID != "001" {
+		t.Errorf("Expected only Alice after reopen, got %d persons", len(persons))
+	}
+
+	if err := storage.Clear(); err != nil {
+		t.Fatalf("Failed to clear storage: %v", err)
+	}
+	if exists, _ := storage.PersonExists("001"); exists {
+		t.Error("Expected no persons after Clear")
+	}
+	if err := storage.SavePerson(alice); err != nil {
+		t.Errorf("Failed to save person after Clear: %v", err)
+	}
+}
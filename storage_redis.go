@@ -0,0 +1,504 @@
+package face
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisOptions configures a RedisStorage
+type RedisOptions struct {
+	Addr        string        // Server address (default "localhost:6379")
+	Password    string        // AUTH password (empty = no AUTH)
+	DB          int           // Database index selected on connect
+	KeyPrefix   string        // Prefix for all keys (default "face:")
+	PoolSize    int           // Maximum idle connections kept (default 10)
+	DialTimeout time.Duration // Connection timeout (default 5s)
+	Timeout     time.Duration // Per-command read/write timeout (default 3s)
+}
+
+// RedisStorage implements Redis-based storage (shared between recognizer instances)
+//
+// Each person is stored as a JSON value under <prefix>person:<id>, and the
+// set <prefix>persons indexes all person IDs.
+type RedisStorage struct {
+	opts   RedisOptions
+	pool   chan *redisConn
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewRedisStorage connects to Redis and verifies the connection with PING
+func NewRedisStorage(opts RedisOptions) (*RedisStorage, error) {
+	if opts.Addr == "" {
+		opts.Addr = "localhost:6379"
+	}
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = "face:"
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 10
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 3 * time.Second
+	}
+
+	s := &RedisStorage{
+		opts: opts,
+		pool: make(chan *redisConn, opts.PoolSize),
+	}
+
+	if _, err := s.do("PING"); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %v", opts.Addr, err)
+	}
+
+	return s, nil
+}
+
+func (s *RedisStorage) personKey(id string) string {
+	return s.opts.KeyPrefix + "person:" + id
+}
+
+func (s *RedisStorage) indexKey() string {
+	return s.opts.KeyPrefix + "persons"
+}
+
+func (s *RedisStorage) SavePerson(person *Person) error {
+	data, err := json.Marshal(person)
+	if err != nil {
+		return fmt.Errorf("failed to marshal person: %v", err)
+	}
+
+	_, err = s.transaction(
+		[]string{"SET", s.personKey(person.ID), string(data)},
+		[]string{"SADD", s.indexKey(), person.ID},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save person: %v", err)
+	}
+
+	return nil
+}
+
+func (s *RedisStorage) LoadPerson(id string) (*Person, error) {
+	reply, err := s.do("GET", s.personKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load person: %v", err)
+	}
+	if reply == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+	}
+
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected Redis reply for person %s", id)
+	}
+
+	var person Person
+	if err := json.Unmarshal(data, &person); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal person: %v", err)
+	}
+
+	return &person, nil
+}
+
+func (s *RedisStorage) LoadAllPersons() ([]*Person, error) {
+	persons := make([]*Person, 0)
+	err := s.IterPersons(func(person *Person) error {
+		persons = append(persons, person)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return persons, nil
+}
+
+// redisIterBatch is the number of persons fetched per MGET by IterPersons
+const redisIterBatch = 100
+
+// IterPersons fetches persons in batches of redisIterBatch
+func (s *RedisStorage) IterPersons(fn func(*Person) error) error {
+	reply, err := s.do("SMEMBERS", s.indexKey())
+	if err != nil {
+		return fmt.Errorf("failed to list persons: %v", err)
+	}
+
+	members, _ := reply.([]interface{})
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		if id, ok := member.([]byte); ok {
+			ids = append(ids, string(id))
+		}
+	}
+	sort.Strings(ids)
+
+	for start := 0; start < len(ids); start += redisIterBatch {
+		batch := ids[start:min(start+redisIterBatch, len(ids))]
+
+		args := make([]string, 0, len(batch)+1)
+		args = append(args, "MGET")
+		for _, id := range batch {
+			args = append(args, s.personKey(id))
+		}
+
+		reply, err := s.do(args...)
+		if err != nil {
+			return fmt.Errorf("failed to load persons: %v", err)
+		}
+
+		values, _ := reply.([]interface{})
+		for _, value := range values {
+			data, ok := value.([]byte)
+			if !ok {
+				// Index entry without a person (deleted concurrently)
+				continue
+			}
+
+			var person Person
+			if err := json.Unmarshal(data, &person); err != nil {
+				// Skip corrupted entries
+				continue
+			}
+
+			if err := fn(&person); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *RedisStorage) DeletePerson(id string) error {
+	replies, err := s.transaction(
+		[]string{"DEL", s.personKey(id)},
+		[]string{"SREM", s.indexKey(), id},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete person: %v", err)
+	}
+
+	if deleted, _ := replies[0].(int64); deleted == 0 {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+	}
+
+	return nil
+}
+
+func (s *RedisStorage) PersonExists(id string) (bool, error) {
+	reply, err := s.do("EXISTS", s.personKey(id))
+	if err != nil {
+		return false, fmt.Errorf("failed to check person: %v", err)
+	}
+
+	count, _ := reply.(int64)
+	return count > 0, nil
+}
+
+// Clear deletes every indexed person and the index in one transaction
+func (s *RedisStorage) Clear() error {
+	reply, err := s.do("SMEMBERS", s.indexKey())
+	if err != nil {
+		return fmt.Errorf("failed to list persons: %v", err)
+	}
+
+	members, _ := reply.([]interface{})
+	del := make([]string, 0, len(members)+2)
+	del = append(del, "DEL", s.indexKey())
+	for _, member := range members {
+		if id, ok := member.([]byte); ok {
+			del = append(del, s.personKey(string(id)))
+		}
+	}
+
+	if _, err := s.transaction(del); err != nil {
+		return fmt.Errorf("failed to clear persons: %v", err)
+	}
+
+	return nil
+}
+
+// Close closes all pooled connections
+func (s *RedisStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	close(s.pool)
+	for conn := range s.pool {
+		conn.Close()
+	}
+
+	return nil
+}
+
+// do runs a single command on a pooled connection
+func (s *RedisStorage) do(args ...string) (interface{}, error) {
+	conn, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.send(s.opts.Timeout, args); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reply, err := conn.readReply()
+	s.release(conn, err)
+	return reply, err
+}
+
+// transaction runs commands atomically with MULTI/EXEC and returns their replies
+func (s *RedisStorage) transaction(commands ...[]string) ([]interface{}, error) {
+	conn, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+
+	// Pipeline MULTI, the commands and EXEC in one write
+	pipeline := make([][]string, 0, len(commands)+2)
+	pipeline = append(pipeline, []string{"MULTI"})
+	pipeline = append(pipeline, commands...)
+	pipeline = append(pipeline, []string{"EXEC"})
+	if err := conn.send(s.opts.Timeout, pipeline...); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// MULTI and each queued command reply +OK / +QUEUED; a command error
+	// here makes EXEC fail, but every reply must still be consumed
+	var queueErr error
+	for i := 0; i < len(commands)+1; i++ {
+		if _, err := conn.readReply(); err != nil {
+			if !isRedisError(err) {
+				conn.Close()
+				return nil, err
+			}
+			if queueErr == nil {
+				queueErr = err
+			}
+		}
+	}
+
+	reply, err := conn.readReply()
+	s.release(conn, err)
+	if queueErr != nil {
+		return nil, queueErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	replies, ok := reply.([]interface{})
+	if !ok || len(replies) != len(commands) {
+		return nil, errors.New("transaction aborted")
+	}
+	for _, r := range replies {
+		if rerr, ok := r.(redisError); ok {
+			return nil, rerr
+		}
+	}
+
+	return replies, nil
+}
+
+// get returns an idle pooled connection or dials a new one
+func (s *RedisStorage) get() (*redisConn, error) {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return nil, errors.New("redis storage is closed")
+	}
+
+	select {
+	case conn, ok := <-s.pool:
+		if ok {
+			return conn, nil
+		}
+		return nil, errors.New("redis storage is closed")
+	default:
+	}
+
+	return s.dial()
+}
+
+// release returns a connection to the pool unless it failed at the protocol
+// level or the pool is full or closed
+func (s *RedisStorage) release(conn *redisConn, err error) {
+	if err != nil && !isRedisError(err) {
+		conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		conn.Close()
+		return
+	}
+
+	select {
+	case s.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (s *RedisStorage) dial() (*redisConn, error) {
+	netConn, err := net.DialTimeout("tcp", s.opts.Addr, s.opts.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{Conn: netConn, r: bufio.NewReader(netConn)}
+
+	if s.opts.Password != "" {
+		if err := conn.command(s.opts.Timeout, "AUTH", s.opts.Password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("AUTH failed: %v", err)
+		}
+	}
+
+	if s.opts.DB != 0 {
+		if err := conn.command(s.opts.Timeout, "SELECT", strconv.Itoa(s.opts.DB)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("SELECT failed: %v", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// redisError is an error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+func isRedisError(err error) bool {
+	var rerr redisError
+	return errors.As(err, &rerr)
+}
+
+// redisConn is a single RESP connection
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// command sends one command and discards its reply
+func (c *redisConn) command(timeout time.Duration, args ...string) error {
+	if err := c.send(timeout, args); err != nil {
+		return err
+	}
+	_, err := c.readReply()
+	return err
+}
+
+// send writes commands as RESP arrays of bulk strings and sets the deadline
+// for reading their replies
+func (c *redisConn) send(timeout time.Duration, commands ...[]string) error {
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(c.Conn)
+	for _, args := range commands {
+		writeCommand(w, args)
+	}
+	return w.Flush()
+}
+
+func (c *redisConn) readReply() (interface{}, error) {
+	return readReply(c.r)
+}
+
+// writeCommand encodes a command as a RESP array of bulk strings
+func writeCommand(w *bufio.Writer, args []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+// readReply decodes one RESP reply: simple strings as string, errors as
+// redisError, integers as int64, bulk strings as []byte and arrays as
+// []interface{}. Null bulk strings and arrays decode to nil.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed Redis reply: %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		n, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed Redis integer: %q", payload)
+		}
+		return n, nil
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("malformed Redis bulk length: %q", payload)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("malformed Redis array length: %q", payload)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := readReply(r)
+			if err != nil && !isRedisError(err) {
+				return nil, err
+			}
+			if err != nil {
+				item = err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unknown Redis reply type %q", kind)
+	}
+}
@@ -0,0 +1,442 @@
+package face
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sqliteDriver is a database/sql SQLite driver and the DSN parameter that
+// sets its busy timeout. A PRAGMA only reaches the pooled connection that
+// runs it, so the timeout goes in the DSN to cover every connection.
+type sqliteDriver struct {
+	name        string
+	busyTimeout string
+}
+
+// sqliteDrivers lists the common SQLite drivers in order of preference
+var sqliteDrivers = []sqliteDriver{
+	{"sqlite3", "_busy_timeout=5000"},        // github.com/mattn/go-sqlite3
+	{"sqlite", "_pragma=busy_timeout(5000)"}, // modernc.org/sqlite
+}
+
+// SQLiteStorage implements SQLite-based storage (persistent, scales to large datasets)
+//
+// Build with the "sqlite" tag to bundle the pure-Go modernc.org/sqlite driver,
+// or import a driver in your program, e.g.
+//
+//	import _ "github.com/mattn/go-sqlite3"
+//
+// Features are stored as full float32 blobs; WithStoreQuantized saves no
+// space on this backend.
+type SQLiteStorage struct {
+	db *sql.DB
+	mu sync.RWMutex
+}
+
+// NewSQLiteStorage opens (or creates) a SQLite database and its schema
+func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
+	driver, err := findSQLiteDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open(driver.name, dbPath+separator+driver.busyTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %v", err)
+	}
+
+	storage := &SQLiteStorage{db: db}
+	if err := storage.init(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize SQLite schema: %v", err)
+	}
+
+	return storage, nil
+}
+
+// findSQLiteDriver returns the first registered SQLite driver
+func findSQLiteDriver() (sqliteDriver, error) {
+	registered := make(map[string]bool)
+	for _, name := range sql.Drivers() {
+		registered[name] = true
+	}
+
+	for _, driver := range sqliteDrivers {
+		if registered[driver.name] {
+			return driver, nil
+		}
+	}
+
+	return sqliteDriver{}, errors.New("no SQLite driver registered (import github.com/mattn/go-sqlite3 or modernc.org/sqlite)")
+}
+
+func (s *SQLiteStorage) init() error {
+	statements := []string{
+		// WAL allows concurrent readers while a write is in progress
+		`PRAGMA journal_mode=WAL`,
+		`CREATE TABLE IF NOT EXISTS persons (
+			id             TEXT PRIMARY KEY,
+			name           TEXT NOT NULL,
+			metadata       TEXT,
+			disabled       INTEGER NOT NULL DEFAULT 0,
+			schema_version INTEGER NOT NULL DEFAULT 1
+		)`,
+		`CREATE TABLE IF NOT EXISTS features (
+			id           INTEGER PRIMARY KEY AUTOINCREMENT,
+			person_id    TEXT NOT NULL REFERENCES persons(id),
+			feature      BLOB NOT NULL,
+			created_at   INTEGER,
+			source_image TEXT,
+			quality      REAL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_features_person_id ON features(person_id)`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// Databases created before person metadata, the disabled flag or
+	// schema versions existed lack the columns; their rows are version 1
+	if err := s.addColumnIfMissing("persons", "metadata", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("persons", "disabled", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("persons", "schema_version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+
+	// Likewise for sample provenance, which stays NULL for older samples
+	for _, column := range [][2]string{{"created_at", "INTEGER"}, {"source_image", "TEXT"}, {"quality", "REAL"}} {
+		if err := s.addColumnIfMissing("features", column[0], column[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table
+func (s *SQLiteStorage) addColumnIfMissing(table, column, columnType string) error {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, columnType))
+	return err
+}
+
+// sampleColumns holds the provenance columns of a features row, which are
+// NULL for samples stored before they existed
+type sampleColumns struct {
+	createdAt sql.NullInt64 // Unix nanoseconds
+	source    sql.NullString
+	quality   sql.NullFloat64
+}
+
+// sampleArgs returns the provenance column values of a sample
+func sampleArgs(feature FaceFeature) []interface{} {
+	var createdAt sql.NullInt64
+	if !feature.CreatedAt.IsZero() {
+		createdAt = sql.NullInt64{Int64: feature.CreatedAt.UnixNano(), Valid: true}
+	}
+	return []interface{}{createdAt, feature.SourceImage, feature.Quality}
+}
+
+// feature returns the sample of a features row
+func (c sampleColumns) feature(personID string, blob []byte) FaceFeature {
+	feature := FaceFeature{
+		PersonID:    personID,
+		Feature:     decodeFeature(blob),
+		SourceImage: c.source.String,
+		Quality:     float32(c.quality.Float64),
+	}
+	if c.createdAt.Valid {
+		feature.CreatedAt = time.Unix(0, c.createdAt.Int64).UTC()
+	}
+	return feature
+}
+
+// encodeMetadata serializes person metadata for the metadata column (NULL when empty)
+func encodeMetadata(metadata map[string]string) (sql.NullString, error) {
+	if len(metadata) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// decodeMetadata deserializes the metadata column
+func decodeMetadata(column sql.NullString) (map[string]string, error) {
+	if !column.Valid || column.String == "" {
+		return nil, nil
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(column.String), &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func (s *SQLiteStorage) SavePerson(person *Person) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	metadata, err := encodeMetadata(person.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %v", err)
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO persons (id, name, metadata, disabled, schema_version) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(id) DO UPDATE SET name = excluded.name, metadata = excluded.metadata, disabled = excluded.disabled,
+		 schema_version = excluded.schema_version`,
+		person.ID, person.Name, metadata, person.Disabled, PersonSchemaVersion,
+	); err != nil {
+		return fmt.Errorf("failed to save person: %v", err)
+	}
+
+	// Replace all features of the person
+	if _, err := tx.Exec(`DELETE FROM features WHERE person_id = ?`, person.ID); err != nil {
+		return fmt.Errorf("failed to clear features: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO features (person_id, feature, created_at, source_image, quality) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare feature insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, feature := range person.Features {
+		args := append([]interface{}{person.ID, encodeFeature(feature.Feature)}, sampleArgs(feature)...)
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("failed to save feature: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) LoadPerson(id string) (*Person, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	person := &Person{Features: make([]FaceFeature, 0)}
+	var metadata sql.NullString
+	err := s.db.QueryRow(`SELECT id, name, metadata, disabled, schema_version FROM persons WHERE id = ?`, id).
+		Scan(&person.ID, &person.Name, &metadata, &person.Disabled, &person.SchemaVersion)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load person: %v", err)
+	}
+
+	if person.Metadata, err = decodeMetadata(metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %v", err)
+	}
+
+	rows, err := s.db.Query(`SELECT feature, created_at, source_image, quality FROM features WHERE person_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load features: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var blob []byte
+		var sample sampleColumns
+		if err := rows.Scan(&blob, &sample.createdAt, &sample.source, &sample.quality); err != nil {
+			return nil, fmt.Errorf("failed to read feature: %v", err)
+		}
+		person.Features = append(person.Features, sample.feature(id, blob))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load features: %v", err)
+	}
+
+	return person, nil
+}
+
+func (s *SQLiteStorage) LoadAllPersons() ([]*Person, error) {
+	persons := make([]*Person, 0)
+	err := s.IterPersons(func(person *Person) error {
+		persons = append(persons, person)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return persons, nil
+}
+
+// IterPersons streams persons and their features in a single ordered pass,
+// holding only one person in memory at a time
+func (s *SQLiteStorage) IterPersons(fn func(*Person) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(
+		`SELECT p.id, p.name, p.metadata, p.disabled, p.schema_version, f.feature, f.created_at, f.source_image, f.quality
+		 FROM persons p LEFT JOIN features f ON f.person_id = p.id
+		 ORDER BY p.id, f.id`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load persons: %v", err)
+	}
+	defer rows.Close()
+
+	var current *Person
+	for rows.Next() {
+		var id, name string
+		var metadata sql.NullString
+		var disabled bool
+		var version int
+		var blob []byte
+		var sample sampleColumns
+		if err := rows.Scan(&id, &name, &metadata, &disabled, &version, &blob, &sample.createdAt, &sample.source, &sample.quality); err != nil {
+			return fmt.Errorf("failed to read person: %v", err)
+		}
+
+		if current == nil || current.ID != id {
+			if current != nil {
+				if err := fn(current); err != nil {
+					return err
+				}
+			}
+			current = &Person{ID: id, Name: name, Disabled: disabled, SchemaVersion: version, Features: make([]FaceFeature, 0)}
+			if current.Metadata, err = decodeMetadata(metadata); err != nil {
+				return fmt.Errorf("failed to unmarshal metadata: %v", err)
+			}
+		}
+
+		// A person without features yields a single row with a NULL feature
+		if blob != nil {
+			current.Features = append(current.Features, sample.feature(id, blob))
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load persons: %v", err)
+	}
+
+	if current != nil {
+		return fn(current)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) DeletePerson(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM features WHERE person_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete features: %v", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM persons WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete person: %v", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("%w: %s", ErrPersonNotFound, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) PersonExists(id string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var exists int
+	err := s.db.QueryRow(`SELECT 1 FROM persons WHERE id = ?`, id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SQLiteStorage) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM features`); err != nil {
+		return fmt.Errorf("failed to delete features: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM persons`); err != nil {
+		return fmt.Errorf("failed to delete persons: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Vacuum rebuilds the database file to reclaim space left by deleted persons
+func (s *SQLiteStorage) Vacuum() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("failed to vacuum database: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
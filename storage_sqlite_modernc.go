@@ -0,0 +1,9 @@
+//go:build sqlite
+
+package face
+
+// Building with the "sqlite" tag bundles the pure-Go modernc.org/sqlite
+// driver, so NewSQLiteStorage works without importing a driver:
+//
+//	go build -tags sqlite
+import _ "modernc.org/sqlite"
@@ -0,0 +1,196 @@
+//go:build sqlite
+
+package face
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStorage_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.db")
+
+	storage, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create SQLite storage: %v", err)
+	}
+
+	alice := &Person{
+		ID:   "001",
+		Name: "Alice",
+		Features: []FaceFeature{
+			{PersonID: "001", Feature: []float32{0.1, 0.2, 0.3}, CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), SourceImage: "alice.jpg", Quality: 7.5},
+			{PersonID: "001", Feature: []float32{0.4, 0.5, 0.6}},
+		},
+		Metadata: map[string]string{"department": "R&D"},
+		Disabled: true,
+	}
+	for _, person := range []*Person{alice, {ID: "002", Name: "Bob"}, {ID: "003", Name: "Carol"}} {
+		if err := storage.SavePerson(person); err != nil {
+			t.Fatalf("Failed to save person: %v", err)
+		}
+	}
+
+	// Saving again replaces the samples
+	bob := &Person{ID: "002", Name: "Robert", Features: []FaceFeature{{PersonID: "002", Feature: []float32{1, 0, 0}}}}
+	if err := storage.SavePerson(bob); err != nil {
+		t.Fatalf("Failed to update person: %v", err)
+	}
+
+	if err := storage.DeletePerson("003"); err != nil {
+		t.Fatalf("Failed to delete person: %v", err)
+	}
+	if err := storage.DeletePerson("003"); !errors.Is(err, ErrPersonNotFound) {
+		t.Errorf("Expected ErrPersonNotFound deleting a missing person, got %v", err)
+	}
+	if err := storage.Vacuum(); err != nil {
+		t.Fatalf("Failed to vacuum: %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Failed to close storage: %v", err)
+	}
+
+	// Reopen the file and verify the data survived
+	storage, err = NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen SQLite storage: %v", err)
+	}
+	defer storage.Close()
+
+	loaded, err := storage.LoadPerson("001")
+	if err != nil {
+		t.Fatalf("Failed to load person: %v", err)
+	}
+	if loaded.Name != "Alice" || !loaded.Disabled || loaded.Metadata["department"] != "R&D" || loaded.SchemaVersion != PersonSchemaVersion {
+		t.Errorf("Loaded person does not match saved person: %+v", loaded)
+	}
+	if !reflect.DeepEqual(loaded.Features, alice.Features) {
+		t.Errorf("Expected samples %+v, got %+v", alice.Features, loaded.Features)
+	}
+	if _, err := storage.LoadPerson("003"); !errors.Is(err, ErrPersonNotFound) {
+		t.Errorf("Expected ErrPersonNotFound loading a deleted person, got %v", err)
+	}
+	if exists, _ := storage.PersonExists("002"); !exists {
+		t.Error("Expected Bob to exist")
+	}
+
+	persons, err := storage.LoadAllPersons()
+	if err != nil {
+		t.Fatalf("Failed to load all persons: %v", err)
+	}
+	if len(persons) != 2 || persons[0].ID != "001" || persons[1].Name != "Robert" || len(persons[1].Features) != 1 {
+		t.Errorf("Expected Alice and Robert in ID order, got %+v", persons)
+	}
+	if !reflect.DeepEqual(persons[0].Features, alice.Features) {
+		t.Errorf("Expected iterated samples %+v, got %+v", alice.Features, persons[0].Features)
+	}
+
+	if err := storage.Clear(); err != nil {
+		t.Fatalf("Failed to clear storage: %v", err)
+	}
+	if persons, _ := storage.LoadAllPersons(); len(persons) != 0 {
+		t.Errorf("Expected no persons after Clear, got %d", len(persons))
+	}
+}
+
+func TestSQLiteStorage_MigratesOldSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.db")
+
+	// Schema of the first release: no metadata, disabled, schema version or
+	// sample provenance columns
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE persons (id TEXT PRIMARY KEY, name TEXT NOT NULL)`,
+		`CREATE TABLE features (id INTEGER PRIMARY KEY AUTOINCREMENT, person_id TEXT NOT NULL REFERENCES persons(id), feature BLOB NOT NULL)`,
+		`INSERT INTO persons (id, name) VALUES ('001', 'Alice')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to create old schema: %v", err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO features (person_id, feature) VALUES ('001', ?)`, encodeFeature([]float32{0.6, 0.8})); err != nil {
+		t.Fatalf("Failed to insert feature: %v", err)
+	}
+	db.Close()
+
+	storage, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	defer storage.Close()
+
+	person, err := storage.LoadPerson("001")
+	if err != nil {
+		t.Fatalf("Failed to load person: %v", err)
+	}
+	if person.SchemaVersion != 1 || person.Disabled || person.Metadata != nil {
+		t.Errorf("Expected a version 1 person with defaults, got %+v", person)
+	}
+	if len(person.Features) != 1 || !person.Features[0].CreatedAt.IsZero() || person.Features[0].Feature[1] != 0.8 {
+		t.Errorf("Expected the old sample without provenance, got %+v", person.Features)
+	}
+
+	if migrated, err := MigrateStorage(storage); err != nil || migrated != 1 {
+		t.Fatalf("Expected 1 migrated person, got %d, %v", migrated, err)
+	}
+	if person, _ := storage.LoadPerson("001"); person.SchemaVersion != PersonSchemaVersion {
+		t.Errorf("Expected schema version %d after migration, got %d", PersonSchemaVersion, person.SchemaVersion)
+	}
+}
+
+func TestSQLiteStorage_ConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.db")
+
+	// Two storages stand in for two processes sharing the file; every pooled
+	// connection must wait for the other writer instead of failing with
+	// SQLITE_BUSY
+	var storages [2]*SQLiteStorage
+	for i := range storages {
+		storage, err := NewSQLiteStorage(path)
+		if err != nil {
+			t.Fatalf("Failed to open storage %d: %v", i, err)
+		}
+		defer storage.Close()
+		// A fresh connection for every statement, as a busy pool would open
+		storage.db.SetMaxIdleConns(0)
+		storages[i] = storage
+	}
+
+	const perWriter = 50
+	errs := make(chan error, 2*perWriter)
+	var wg sync.WaitGroup
+	for w, storage := range storages {
+		for i := 0; i < perWriter; i++ {
+			wg.Add(1)
+			go func(storage *SQLiteStorage, id string) {
+				defer wg.Done()
+				errs <- storage.SavePerson(&Person{ID: id, Name: id, Features: []FaceFeature{{PersonID: id, Feature: []float32{1, 0}}}})
+			}(storage, fmt.Sprintf("%d-%03d", w, i))
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent save failed: %v", err)
+		}
+	}
+
+	persons, err := storages[0].LoadAllPersons()
+	if err != nil {
+		t.Fatalf("Failed to load persons: %v", err)
+	}
+	if len(persons) != 2*perWriter {
+		t.Errorf("Expected %d persons, got %d", 2*perWriter, len(persons))
+	}
+}
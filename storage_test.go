@@ -0,0 +1,791 @@
+package face
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Every backend implements FaceStorage
+var (
+	_ FaceStorage = (*MemoryStorage)(nil)
+	_ FaceStorage = (*FileStorage)(nil)
+	_ FaceStorage = (*JSONStorage)(nil)
+	_ FaceStorage = (*SQLiteStorage)(nil)
+	_ FaceStorage = (*RedisStorage)(nil)
+)
+
+// Test: Streaming iteration
+
+func TestIterPersons(t *testing.T) {
+	dir := t.TempDir()
+	fileStorage, err := NewFileStorage(dir + "/files")
+	if err != nil {
+		t.Fatalf("Failed to create file storage: %v", err)
+	}
+	jsonStorage, err := NewJSONStorage(dir + "/faces.json")
+	if err != nil {
+		t.Fatalf("Failed to create JSON storage: %v", err)
+	}
+
+	backends := map[string]FaceStorage{
+		"Memory": NewMemoryStorage(),
+		"File":   fileStorage,
+		"JSON":   jsonStorage,
+	}
+
+	for name, storage := range backends {
+		t.Run(name, func(t *testing.T) {
+			for i, id := range []string{"001", "002", "003"} {
+				features := make([]FaceFeature, i)
+				for j := range features {
+					features[j] = FaceFeature{PersonID: id, Feature: []float32{float32(j)}}
+				}
+				storage.SavePerson(&Person{ID: id, Name: "Person " + id, Features: features})
+			}
+
+			metadata, err := GetStorageMetadata(storage)
+			if err != nil {
+				t.Fatalf("Failed to get metadata: %v", err)
+			}
+			if metadata.TotalPersons != 3 || metadata.TotalFeatures != 3 {
+				t.Errorf("Expected 3 persons / 3 features, got %d / %d", metadata.TotalPersons, metadata.TotalFeatures)
+			}
+
+			// An error from fn stops the iteration and is returned as-is
+			stop := errors.New("stop")
+			visited := 0
+			err = storage.IterPersons(func(*Person) error {
+				visited++
+				return stop
+			})
+			if err != stop || visited != 1 {
+				t.Errorf("Expected iteration to stop after 1 person with the fn error, got %d visits, %v", visited, err)
+			}
+
+			if err := storage.Clear(); err != nil {
+				t.Fatalf("Failed to clear: %v", err)
+			}
+			if persons, _ := storage.LoadAllPersons(); len(persons) != 0 {
+				t.Errorf("Expected no persons after Clear, got %d", len(persons))
+			}
+		})
+	}
+}
+
+// Test: Atomic writes
+
+func TestWriteAtomic_InterruptedWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "faces.json")
+
+	storage, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create JSON storage: %v", err)
+	}
+	if err := storage.SavePerson(&Person{ID: "001", Name: "Alice"}); err != nil {
+		t.Fatalf("Failed to save person: %v", err)
+	}
+	good, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read database: %v", err)
+	}
+
+	// Simulate a crash halfway through rewriting the database
+	crash := errors.New("crash")
+	err = writeAtomic(path, 0644, func(w io.Writer) error {
+		w.Write(good[:len(good)/2])
+		return crash
+	})
+	if err != crash {
+		t.Fatalf("Expected the write error to be returned, got %v", err)
+	}
+
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, good) {
+		t.Errorf("Expected previous file to be intact, got %q", data)
+	}
+	reopened, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen JSON storage: %v", err)
+	}
+	if exists, _ := reopened.PersonExists("001"); !exists {
+		t.Error("Expected person to survive the interrupted write")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected temporary file to be removed, found %d entries", len(entries))
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0644 {
+		t.Errorf("Expected file mode 0644, got %v", info.Mode().Perm())
+	}
+}
+
+// Test: Feature serialization
+
+func TestEncodeDecodeFeature(t *testing.T) {
+	tests := []struct {
+		name    string
+		feature []float32
+	}{
+		{"Empty", []float32{}},
+		{"Single value", []float32{0.5}},
+		{"Mixed values", []float32{-1.25, 0, 3.5e-8, 42}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeFeature(tt.feature)
+			if len(data) != 4*len(tt.feature) {
+				t.Fatalf("Expected %d bytes, got %d", 4*len(tt.feature), len(data))
+			}
+
+			decoded := decodeFeature(data)
+			if len(decoded) != len(tt.feature) {
+				t.Fatalf("Expected %d values, got %d", len(tt.feature), len(decoded))
+			}
+			for i := range tt.feature {
+				if decoded[i] != tt.feature[i] {
+					t.Errorf("Value %d: expected %v, got %v", i, tt.feature[i], decoded[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFaceFeature_JSON(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	feature := make([]float32, 128)
+	for i := range feature {
+		feature[i] = rng.Float32()*2 - 1
+	}
+	original := FaceFeature{PersonID: "001", Feature: normalizeFeature(feature)}
+
+	packed, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to marshal feature: %v", err)
+	}
+	legacy, _ := json.Marshal(struct {
+		PersonID string    `json:"person_id"`
+		Feature  []float32 `json:"feature"`
+	}{original.PersonID, original.Feature})
+
+	if !bytes.Contains(packed, []byte(`"feature":"`)) {
+		t.Errorf("Expected the feature to be written as a string, got %s", packed)
+	}
+	if 2*len(packed) > len(legacy) {
+		t.Errorf("Expected packed form (%d bytes) to be well under half the array form (%d bytes)", len(packed), len(legacy))
+	}
+
+	// Both forms decode to the exact vector; the array form only inside a
+	// version 1 person, which migrates it
+	var decoded FaceFeature
+	if err := json.Unmarshal(packed, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal feature: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, original) {
+		t.Errorf("Expected %v, got %v", original, decoded)
+	}
+	var person Person
+	if err := json.Unmarshal([]byte(`{"schema_version":1,"id":"001","features":[`+string(legacy)+`]}`), &person); err != nil {
+		t.Fatalf("Failed to unmarshal version 1 person: %v", err)
+	}
+	if len(person.Features) != 1 || !reflect.DeepEqual(person.Features[0], original) {
+		t.Errorf("Expected %v, got %v", original, person.Features)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		feature []float32
+		wantErr bool
+	}{
+		{"Missing feature", `{"person_id":"001"}`, nil, false},
+		{"Null feature", `{"person_id":"001","feature":null}`, nil, false},
+		{"Truncated packed feature", `{"person_id":"001","feature":"AACAPwA="}`, nil, true},
+		{"Invalid base64", `{"person_id":"001","feature":"!!"}`, nil, true},
+		{"Number array", `{"person_id":"001","feature":[0.6,0.8]}`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var decoded FaceFeature
+			err := json.Unmarshal([]byte(tt.data), &decoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(decoded.Feature, tt.feature) {
+				t.Errorf("Expected feature %v, got %v", tt.feature, decoded.Feature)
+			}
+		})
+	}
+}
+
+func TestFaceFeature_Provenance(t *testing.T) {
+	original := FaceFeature{
+		PersonID:    "001",
+		Feature:     []float32{0.6, 0.8},
+		CreatedAt:   time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC),
+		SourceImage: "photos/alice.jpg",
+		Quality:     7.5,
+	}
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to marshal feature: %v", err)
+	}
+	var decoded FaceFeature
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal feature: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, original) {
+		t.Errorf("Expected %+v, got %+v", original, decoded)
+	}
+
+	// Samples without provenance are written as before
+	bare, _ := json.Marshal(FaceFeature{PersonID: "001", Feature: []float32{0.6, 0.8}})
+	for _, field := range []string{"created_at", "source_image", "quality"} {
+		if bytes.Contains(bare, []byte(field)) {
+			t.Errorf("Expected %s to be omitted, got %s", field, bare)
+		}
+	}
+
+	// The quantized form keeps the provenance
+	stored := quantizedPerson(&Person{ID: "001", Features: []FaceFeature{original}})
+	if f := stored.Features[0]; !f.CreatedAt.Equal(original.CreatedAt) || f.SourceImage != original.SourceImage || f.Quality != original.Quality {
+		t.Errorf("Expected provenance of %+v, got %+v", original, f)
+	}
+}
+
+func TestQuantizeFeature_SimilarityError(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomFeature := func(dim int) []float32 {
+		feature := make([]float32, dim)
+		for i := range feature {
+			feature[i] = float32(rng.NormFloat64())
+		}
+		return normalizeFeature(feature)
+	}
+
+	for _, dim := range []int{128, 512} {
+		t.Run(fmt.Sprintf("%d-dim", dim), func(t *testing.T) {
+			var maxError float64
+			for i := 0; i < 200; i++ {
+				a, b := randomFeature(dim), randomFeature(dim)
+				qa := DequantizeFeature(QuantizeFeature(a))
+				qb := DequantizeFeature(QuantizeFeature(b))
+
+				maxError = math.Max(maxError, math.Abs(float64(cosineSimilarity(a, b)-cosineSimilarity(qa, qb))))
+				maxError = math.Max(maxError, math.Abs(float64(1-cosineSimilarity(a, qa))))
+			}
+
+			if maxError >= 0.01 {
+				t.Errorf("Expected cosine similarity error under 1%%, got %.4f", maxError)
+			}
+		})
+	}
+}
+
+func TestQuantizeFeature_EdgeCases(t *testing.T) {
+	if q := QuantizeFeature(nil); len(DequantizeFeature(q)) != 0 {
+		t.Error("Expected empty vector to round-trip to empty")
+	}
+
+	constant := []float32{0.5, 0.5, 0.5}
+	restored := DequantizeFeature(QuantizeFeature(constant))
+	for i, v := range restored {
+		if v != 0.5 {
+			t.Errorf("Index %d: expected 0.5, got %v", i, v)
+		}
+	}
+
+	// Extremes are reproduced exactly
+	restored = DequantizeFeature(QuantizeFeature([]float32{-1, 0.25, 1}))
+	if restored[0] != -1 || math.Abs(float64(restored[2]-1)) > 1e-6 {
+		t.Errorf("Expected min/max to be preserved, got %v", restored)
+	}
+}
+
+func TestQuantizedPerson_JSONRoundTrip(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	person := &Person{ID: "001", Name: "Alice", Features: []FaceFeature{
+		{PersonID: "001", Feature: []float32{0.1, -0.4, 0.9, 0.0}},
+	}}
+	stored := quantizedPerson(person)
+	if err := storage.SavePerson(stored); err != nil {
+		t.Fatalf("Failed to save person: %v", err)
+	}
+
+	data, err := os.ReadFile(storage.getPersonPath("001"))
+	if err != nil {
+		t.Fatalf("Failed to read person file: %v", err)
+	}
+	if strings.Contains(string(data), `"feature"`) {
+		t.Error("Expected only the quantized form to be written")
+	}
+
+	loaded, err := storage.LoadPerson("001")
+	if err != nil {
+		t.Fatalf("Failed to load person: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Features[0].Feature, stored.Features[0].Feature) {
+		t.Errorf("Expected dequantized %v, got %v", stored.Features[0].Feature, loaded.Features[0].Feature)
+	}
+}
+
+// Test: Schema versioning
+
+func TestPerson_SchemaVersion(t *testing.T) {
+	person := &Person{ID: "001", Name: "Alice", Features: []FaceFeature{
+		{PersonID: "001", Feature: []float32{0.6, 0.8}},
+	}}
+	current, err := json.Marshal(person)
+	if err != nil {
+		t.Fatalf("Failed to marshal person: %v", err)
+	}
+	if !bytes.Contains(current, []byte(fmt.Sprintf(`"schema_version":%d`, PersonSchemaVersion))) {
+		t.Errorf("Expected the current schema version to be written, got %s", current)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		version int
+		wantErr bool
+	}{
+		{"Current", string(current), PersonSchemaVersion, false},
+		{"Unversioned", `{"id":"001","name":"Alice","features":[{"person_id":"001","feature":[0.6,0.8]}]}`, 1, false},
+		{"Version 1", `{"schema_version":1,"id":"001","name":"Alice","features":[{"person_id":"001","feature":[0.6,0.8]}]}`, 1, false},
+		{"Newer version", `{"schema_version":99,"id":"001","name":"Alice"}`, 0, true},
+		{"Invalid version", `{"schema_version":0,"id":"001","name":"Alice"}`, 0, true},
+		{"Invalid v1 feature", `{"schema_version":1,"id":"001","features":[{"feature":["x"]}]}`, 0, true},
+		{"Array feature in version 2", `{"schema_version":2,"id":"001","name":"Alice","features":[{"person_id":"001","feature":[0.6,0.8]}]}`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var loaded Person
+			err := json.Unmarshal([]byte(tt.data), &loaded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if loaded.SchemaVersion != tt.version {
+				t.Errorf("Expected schema version %d, got %d", tt.version, loaded.SchemaVersion)
+			}
+			if loaded.ID != "001" || loaded.Name != "Alice" || !reflect.DeepEqual(loaded.Features, person.Features) {
+				t.Errorf("Expected %+v, got %+v", person, &loaded)
+			}
+		})
+	}
+}
+
+func TestMigrateStorage(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	legacy := `{"id":"001","name":"Alice","features":[{"person_id":"001","feature":[0.6,0.8]}]}`
+	if err := os.WriteFile(storage.getPersonPath("001"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write legacy person: %v", err)
+	}
+	if err := storage.SavePerson(&Person{ID: "002", Name: "Bob"}); err != nil {
+		t.Fatalf("Failed to save person: %v", err)
+	}
+
+	migrated, err := MigrateStorage(storage)
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if migrated != 1 {
+		t.Errorf("Expected 1 migrated person, got %d", migrated)
+	}
+
+	data, err := os.ReadFile(storage.getPersonPath("001"))
+	if err != nil {
+		t.Fatalf("Failed to read person file: %v", err)
+	}
+	if !bytes.Contains(data, []byte(`"feature": "`)) {
+		t.Errorf("Expected the feature to be rewritten packed, got %s", data)
+	}
+
+	person, err := storage.LoadPerson("001")
+	if err != nil {
+		t.Fatalf("Failed to load person: %v", err)
+	}
+	if person.SchemaVersion != PersonSchemaVersion || !reflect.DeepEqual(person.Features[0].Feature, []float32{0.6, 0.8}) {
+		t.Errorf("Expected migrated person, got %+v", person)
+	}
+
+	if migrated, err := MigrateStorage(storage); err != nil || migrated != 0 {
+		t.Errorf("Expected nothing left to migrate, got %d, %v", migrated, err)
+	}
+}
+
+// Test: SQLite storage
+
+func TestNewSQLiteStorage_NoDriver(t *testing.T) {
+	// The test binary does not register a SQLite driver
+	if _, err := findSQLiteDriver(); err == nil {
+		t.Skip("SQLite driver registered")
+	}
+
+	_, err := NewSQLiteStorage(t.TempDir() + "/faces.db")
+	if err == nil {
+		t.Fatal("Expected error when no SQLite driver is registered")
+	}
+
+	if !strings.Contains(err.Error(), "driver") {
+		t.Errorf("Error should mention the missing driver, got: %v", err)
+	}
+}
+
+// Test: Redis storage
+
+func TestRedisWriteCommand(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeCommand(w, []string{"SET", "face:person:001", "{}"})
+	w.Flush()
+
+	expected := "*3\r\n$3\r\nSET\r\n$15\r\nface:person:001\r\n$2\r\n{}\r\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestRedisReadReply(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    interface{}
+		expectError bool
+	}{
+		{"Simple string", "+OK\r\n", "OK", false},
+		{"Error", "-ERR unknown command\r\n", nil, true},
+		{"Integer", ":42\r\n", int64(42), false},
+		{"Bulk string", "$5\r\nhello\r\n", []byte("hello"), false},
+		{"Null bulk string", "$-1\r\n", nil, false},
+		{"Array", "*2\r\n$1\r\na\r\n:1\r\n", []interface{}{[]byte("a"), int64(1)}, false},
+		{"Array with null", "*2\r\n$-1\r\n$1\r\nb\r\n", []interface{}{nil, []byte("b")}, false},
+		{"Malformed", "?\r\n", nil, true},
+		{"Truncated bulk", "$5\r\nhe", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := readReply(bufio.NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Expected error=%v, got %v", tt.expectError, err)
+			}
+			if !tt.expectError && !reflect.DeepEqual(reply, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, reply)
+			}
+		})
+	}
+}
+
+func TestNewRedisStorage_ConnectionRefused(t *testing.T) {
+	// Reserve a port, then close it so nothing is listening
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = NewRedisStorage(RedisOptions{Addr: addr, DialTimeout: time.Second})
+	if err == nil {
+		t.Fatal("Expected error when Redis is unreachable")
+	}
+	if !strings.Contains(err.Error(), addr) {
+		t.Errorf("Error should mention the address, got: %v", err)
+	}
+}
+
+// fakeRedis is an in-process RESP server implementing the commands
+// RedisStorage uses, with MULTI/EXEC queuing
+type fakeRedis struct {
+	mu        sync.Mutex
+	values    map[string][]byte
+	sets      map[string]map[string]bool
+	failQueue string // Command rejected while queued in MULTI
+	mgetSizes []int
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeRedis{values: make(map[string][]byte), sets: make(map[string]map[string]bool)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	return f, listener.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	var queued [][]string
+	inMulti, aborted := false, false
+	for {
+		request, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := request.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			data, _ := item.([]byte)
+			args[i] = string(data)
+		}
+
+		switch command := strings.ToUpper(args[0]); {
+		case command == "MULTI":
+			inMulti = true
+			w.WriteString("+OK\r\n")
+		case command == "EXEC":
+			if aborted {
+				w.WriteString("-EXECABORT Transaction discarded because of previous errors.\r\n")
+			} else {
+				fmt.Fprintf(w, "*%d\r\n", len(queued))
+				for _, args := range queued {
+					w.WriteString(f.exec(args))
+				}
+			}
+			queued, inMulti, aborted = nil, false, false
+		case inMulti && command == f.queueFailure():
+			aborted = true
+			w.WriteString("-ERR injected failure\r\n")
+		case inMulti:
+			queued = append(queued, args)
+			w.WriteString("+QUEUED\r\n")
+		default:
+			w.WriteString(f.exec(args))
+		}
+
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) queueFailure() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failQueue
+}
+
+// exec runs one command and returns its RESP-encoded reply
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bulk := func(data []byte) string {
+		if data == nil {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(data), data)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		return bulk(f.values[args[1]])
+	case "SET":
+		f.values[args[1]] = []byte(args[2])
+		return "+OK\r\n"
+	case "EXISTS":
+		if _, ok := f.values[args[1]]; ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := f.values[key]; ok {
+				delete(f.values, key)
+				deleted++
+			}
+			if _, ok := f.sets[key]; ok {
+				delete(f.sets, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "MGET":
+		f.mgetSizes = append(f.mgetSizes, len(args)-1)
+		reply := fmt.Sprintf("*%d\r\n", len(args)-1)
+		for _, key := range args[1:] {
+			reply += bulk(f.values[key])
+		}
+		return reply
+	case "SADD":
+		if f.sets[args[1]] == nil {
+			f.sets[args[1]] = make(map[string]bool)
+		}
+		added := 0
+		for _, member := range args[2:] {
+			if !f.sets[args[1]][member] {
+				f.sets[args[1]][member] = true
+				added++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", added)
+	case "SREM":
+		removed := 0
+		for _, member := range args[2:] {
+			if f.sets[args[1]][member] {
+				delete(f.sets[args[1]], member)
+				removed++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", removed)
+	case "SMEMBERS":
+		reply := fmt.Sprintf("*%d\r\n", len(f.sets[args[1]]))
+		for member := range f.sets[args[1]] {
+			reply += bulk([]byte(member))
+		}
+		return reply
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func TestRedisStorage_FakeServer(t *testing.T) {
+	server, addr := newFakeRedis(t)
+
+	storage, err := NewRedisStorage(RedisOptions{Addr: addr, DialTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to connect to fake Redis: %v", err)
+	}
+	defer storage.Close()
+
+	// More persons than one MGET batch
+	const count = redisIterBatch + 50
+	for i := 0; i < count; i++ {
+		person := &Person{
+			ID:       fmt.Sprintf("%03d", i),
+			Name:     fmt.Sprintf("Person %d", i),
+			Features: []FaceFeature{{PersonID: fmt.Sprintf("%03d", i), Feature: []float32{float32(i), 1}}},
+		}
+		if err := storage.SavePerson(person); err != nil {
+			t.Fatalf("Failed to save person %d: %v", i, err)
+		}
+	}
+
+	person, err := storage.LoadPerson("042")
+	if err != nil {
+		t.Fatalf("Failed to load person: %v", err)
+	}
+	if person.Name != "Person 42" || len(person.Features) != 1 || person.Features[0].Feature[0] != 42 {
+		t.Errorf("Loaded person does not match saved person: %+v", person)
+	}
+	if _, err := storage.LoadPerson("999"); !errors.Is(err, ErrPersonNotFound) {
+		t.Errorf("Expected ErrPersonNotFound, got %v", err)
+	}
+
+	if exists, err := storage.PersonExists("042"); err != nil || !exists {
+		t.Errorf("Expected person 042 to exist, got %v, %v", exists, err)
+	}
+	if exists, err := storage.PersonExists("999"); err != nil || exists {
+		t.Errorf("Expected person 999 not to exist, got %v, %v", exists, err)
+	}
+
+	persons, err := storage.LoadAllPersons()
+	if err != nil {
+		t.Fatalf("Failed to load all persons: %v", err)
+	}
+	if len(persons) != count {
+		t.Fatalf("Expected %d persons, got %d", count, len(persons))
+	}
+	for i, person := range persons {
+		if person.ID != fmt.Sprintf("%03d", i) {
+			t.Fatalf("Expected persons in ID order, got %s at %d", person.ID, i)
+		}
+	}
+	server.mu.Lock()
+	if !reflect.DeepEqual(server.mgetSizes, []int{redisIterBatch, count - redisIterBatch}) {
+		t.Errorf("Expected MGET batches of %d and %d, got %v", redisIterBatch, count-redisIterBatch, server.mgetSizes)
+	}
+	server.mu.Unlock()
+
+	// Stopping the iteration returns the callback error
+	stop := errors.New("stop")
+	if err := storage.IterPersons(func(*Person) error { return stop }); err != stop {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+
+	if err := storage.DeletePerson("000"); err != nil {
+		t.Fatalf("Failed to delete person: %v", err)
+	}
+	if err := storage.DeletePerson("000"); !errors.Is(err, ErrPersonNotFound) {
+		t.Errorf("Expected ErrPersonNotFound deleting a missing person, got %v", err)
+	}
+
+	// An error reply while queuing discards the whole transaction
+	server.mu.Lock()
+	server.failQueue = "SADD"
+	server.mu.Unlock()
+	err = storage.SavePerson(&Person{ID: "new", Name: "New"})
+	if err == nil || !strings.Contains(err.Error(), "injected failure") {
+		t.Errorf("Expected the queued command error, got %v", err)
+	}
+	server.mu.Lock()
+	server.failQueue = ""
+	server.mu.Unlock()
+	if exists, _ := storage.PersonExists("new"); exists {
+		t.Error("Aborted transaction should not store the person")
+	}
+
+	// The connection stays usable after the error reply
+	if _, err := storage.LoadPerson("001"); err != nil {
+		t.Errorf("Failed to load person after aborted transaction: %v", err)
+	}
+
+	if err := storage.Clear(); err != nil {
+		t.Fatalf("Failed to clear storage: %v", err)
+	}
+	if persons, _ := storage.LoadAllPersons(); len(persons) != 0 {
+		t.Errorf("Expected no persons after Clear, got %d", len(persons))
+	}
+	server.mu.Lock()
+	if len(server.values) != 0 || len(server.sets) != 0 {
+		t.Errorf("Expected an empty server after Clear, got %d values and %d sets", len(server.values), len(server.sets))
+	}
+	server.mu.Unlock()
+}
@@ -0,0 +1,265 @@
+package face
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// Size frames are shrunk to for the motion gate (see WithMotionThreshold):
+// small enough to be cheap, large enough to notice a face moving
+const (
+	motionThumbnailWidth  = 64
+	motionThumbnailHeight = 48
+)
+
+// WithMotionThreshold makes RecognizeStream skip detection on frames that
+// barely differ from the last frame detection ran on, reusing its boxes for
+// recognition. The difference is the mean absolute change of a small
+// grayscale thumbnail as a fraction of the full intensity range, so e.g.
+// 0.02 tolerates sensor noise and small lighting changes. This cuts CPU on
+// mostly-static feeds such as doorbell cameras. 0 disables the gate
+// (default).
+func WithMotionThreshold(threshold float64) Option {
+	return func(fr *FaceRecognizer) {
+		fr.motionThresh = threshold
+	}
+}
+
+// StreamOptions configures RecognizeStream
+type StreamOptions struct {
+	// SkipFrames runs recognition on every SkipFrames-th frame (0 or 1:
+	// every frame). Frames in between are still read so the capture does not
+	// fall behind.
+	SkipFrames int
+
+	// DetectionScale downscales frames by this factor (e.g. 0.5) before
+	// detection; features are still extracted from the full-resolution
+	// frame. 0 or 1 disables downscaling. Face size limits (WithMinFaceSize,
+	// WithMaxFaceSize) apply to the downscaled frame.
+	DetectionScale float64
+
+	// Stop ends the stream when closed
+	Stop <-chan struct{}
+
+	// OnFrame, if set, is called for every frame with the most recent
+	// results (e.g. to draw boxes and show the frame). The frame is reused
+	// for the next read and must not be kept after OnFrame returns.
+	OnFrame func(frame gocv.Mat, results []RecognizeResult)
+}
+
+// RecognizeStream reads frames from capture and emits recognition results
+// on the returned channel until the capture ends or opts.Stop is closed,
+// then closes the channel. Frames whose recognition fails are dropped.
+//
+// The stream owns capture while it runs: don't read from or close it until
+// the channel is closed.
+func (fr *FaceRecognizer) RecognizeStream(capture *gocv.VideoCapture, opts StreamOptions) (<-chan []RecognizeResult, error) {
+	if err := fr.checkEncoder(); err != nil {
+		return nil, err
+	}
+	if capture == nil || !capture.IsOpened() {
+		return nil, errors.New("video capture is not opened")
+	}
+	if opts.DetectionScale < 0 || opts.DetectionScale > 1 {
+		return nil, fmt.Errorf("detection scale must be between 0 and 1, got %v", opts.DetectionScale)
+	}
+
+	skip := max(1, opts.SkipFrames)
+	out := make(chan []RecognizeResult, 1)
+
+	go func() {
+		defer close(out)
+
+		// Reused across iterations; Read and Resize reallocate only when
+		// the frame size changes
+		frame := gocv.NewMat()
+		defer frame.Close()
+		small := gocv.NewMat()
+		defer small.Close()
+
+		var gate *motionGate
+		if fr.motionThresh > 0 {
+			gate = newMotionGate(fr.motionThresh, fr.colorOrder)
+			defer gate.Close()
+		}
+
+		var latest []RecognizeResult
+		for n := 0; ; n++ {
+			select {
+			case <-opts.Stop:
+				return
+			default:
+			}
+
+			if !capture.Read(&frame) {
+				return
+			}
+			if frame.Empty() {
+				continue
+			}
+
+			if n%skip == 0 {
+				results, err := fr.recognizeFrame(frame, &small, opts.DetectionScale, gate)
+				if err == nil {
+					latest = results
+					select {
+					case out <- results:
+					case <-opts.Stop:
+						return
+					}
+				}
+			}
+
+			if opts.OnFrame != nil {
+				opts.OnFrame(frame, latest)
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// recognizeFrame recognizes the faces in a frame, reusing the detections of
+// the previous frame if gate (which may be nil) finds the frame unchanged,
+// and extracting features from the full-resolution frame
+func (fr *FaceRecognizer) recognizeFrame(frame gocv.Mat, small *gocv.Mat, scale float64, gate *motionGate) ([]RecognizeResult, error) {
+	detections, unchanged := gate.detections(frame)
+	if !unchanged {
+		var err error
+		detections, err = fr.detectFrame(frame, small, scale)
+		if err != nil {
+			return nil, err
+		}
+		gate.remember(detections)
+	}
+	if err := fr.checkFaceCount(len(detections)); err != nil {
+		return nil, err
+	}
+
+	faces := make([]extractedFace, 0, len(detections))
+	for _, det := range detections {
+		faces = append(faces, fr.extractFace(frame, det))
+	}
+
+	return fr.recognizeFaces(context.Background(), faces)
+}
+
+// detectFrame detects the faces in a frame, on a copy downscaled by scale
+// (resized into small) if scale is below 1, and returns them in frame
+// coordinates
+func (fr *FaceRecognizer) detectFrame(frame gocv.Mat, small *gocv.Mat, scale float64) ([]Detection, error) {
+	if scale <= 0 || scale >= 1 {
+		detections, err := fr.detectMatWithScores(frame)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert image: %v", err)
+		}
+		return detections, nil
+	}
+
+	gocv.Resize(frame, small, image.Point{}, scale, scale, gocv.InterpolationArea)
+	detections, err := fr.detectMatWithScores(*small)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert image: %v", err)
+	}
+
+	bounds := image.Rect(0, 0, frame.Cols(), frame.Rows())
+	scaled := detections[:0]
+	for _, det := range detections {
+		det.Rect = scaleRect(det.Rect, 1/scale).Intersect(bounds)
+		if !det.Rect.Empty() {
+			scaled = append(scaled, det)
+		}
+	}
+
+	return scaled, nil
+}
+
+// motionGate remembers the detections of the last frame detection ran on,
+// for reuse on frames that barely differ from it (see WithMotionThreshold).
+// Comparing against that frame rather than the previous one lets slow
+// motion add up until it crosses the threshold.
+type motionGate struct {
+	threshold float64
+	order     ColorOrder
+	gray      gocv.Mat // Scratch grayscale frame
+	thumb     gocv.Mat // Scratch thumbnail
+	current   []uint8  // Thumbnail of the frame being processed
+	reference []uint8  // Thumbnail of the frame last detected on
+	last      []Detection
+}
+
+// newMotionGate creates a motion gate; the caller must close it
+func newMotionGate(threshold float64, order ColorOrder) *motionGate {
+	return &motionGate{
+		threshold: threshold,
+		order:     order,
+		gray:      gocv.NewMat(),
+		thumb:     gocv.NewMat(),
+	}
+}
+
+// detections returns the remembered detections if frame differs from the
+// frame they were found in by at most the threshold. A nil gate never
+// reuses detections.
+func (g *motionGate) detections(frame gocv.Mat) ([]Detection, bool) {
+	if g == nil {
+		return nil, false
+	}
+
+	g.current = nil
+	if err := grayMat(frame, &g.gray, g.order); err != nil {
+		return nil, false
+	}
+	if err := gocv.Resize(g.gray, &g.thumb, image.Pt(motionThumbnailWidth, motionThumbnailHeight), 0, 0, gocv.InterpolationArea); err != nil {
+		return nil, false
+	}
+	g.current = g.thumb.ToBytes()
+
+	if g.reference == nil || frameDifference(g.current, g.reference) > g.threshold {
+		return nil, false
+	}
+	return g.last, true
+}
+
+// remember stores the detections of the frame last passed to detections
+func (g *motionGate) remember(detections []Detection) {
+	if g == nil {
+		return
+	}
+	g.reference, g.last = g.current, detections
+}
+
+// Close releases the gate's scratch Mats
+func (g *motionGate) Close() error {
+	g.gray.Close()
+	return g.thumb.Close()
+}
+
+// frameDifference returns the mean absolute difference of two grayscale
+// thumbnails as a fraction of the full intensity range, or 1 if their sizes
+// differ
+func frameDifference(a, b []uint8) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 1
+	}
+
+	var sum int
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return float64(sum) / float64(len(a)) / 255
+}
+
+// scaleRect multiplies the coordinates of a rectangle by factor
+func scaleRect(r image.Rectangle, factor float64) image.Rectangle {
+	scale := func(v int) int { return int(float64(v)*factor + 0.5) }
+	return image.Rect(scale(r.Min.X), scale(r.Min.Y), scale(r.Max.X), scale(r.Max.Y))
+}
@@ -0,0 +1,136 @@
+package face
+
+import (
+	"image"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestRecognizeStream_RejectsInvalidInput(t *testing.T) {
+	fr := &FaceRecognizer{persons: make(map[string]*Person)}
+
+	if _, err := fr.RecognizeStream(nil, StreamOptions{}); err == nil {
+		t.Error("Expected error for nil capture")
+	}
+}
+
+func TestScaleRect(t *testing.T) {
+	tests := []struct {
+		name     string
+		rect     image.Rectangle
+		factor   float64
+		expected image.Rectangle
+	}{
+		{"Identity", image.Rect(10, 20, 30, 40), 1, image.Rect(10, 20, 30, 40)},
+		{"Upscale", image.Rect(10, 20, 30, 40), 2, image.Rect(20, 40, 60, 80)},
+		{"Rounds", image.Rect(1, 1, 3, 3), 1 / 0.4, image.Rect(3, 3, 8, 8)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scaleRect(tt.rect, tt.factor); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFrameDifference(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []uint8
+		expected float64
+	}{
+		{"Identical", []uint8{10, 200, 30, 40}, []uint8{10, 200, 30, 40}, 0},
+		{"Inverted", []uint8{0, 255}, []uint8{255, 0}, 1},
+		{"Small change", []uint8{100, 100, 100, 100}, []uint8{104, 96, 100, 100}, 2.0 / 255},
+		{"Size change", []uint8{1, 2}, []uint8{1, 2, 3}, 1},
+		{"Empty", nil, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := frameDifference(tt.a, tt.b); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMotionGate(t *testing.T) {
+	// Without a gate detection always runs
+	var disabled *motionGate
+	frame := gocv.NewMat()
+	defer frame.Close()
+	disabled.remember([]Detection{{Rect: image.Rect(0, 0, 10, 10)}})
+	if _, ok := disabled.detections(frame); ok {
+		t.Error("Expected a nil gate never to reuse detections")
+	}
+
+	fr := &FaceRecognizer{}
+	WithMotionThreshold(0.02)(fr)
+	if fr.motionThresh != 0.02 {
+		t.Errorf("Expected motion threshold 0.02, got %v", fr.motionThresh)
+	}
+}
+
+func TestMotionGate_ReusesDetections(t *testing.T) {
+	// A gradient frame kept clear of 0 and 255, a copy with +-2 levels of
+	// noise and an inverted copy
+	rng := rand.New(rand.NewSource(1))
+	base := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	noisy := image.NewRGBA(base.Bounds())
+	inverted := image.NewRGBA(base.Bounds())
+	for i := 0; i < len(base.Pix); i += 4 {
+		v := uint8(i/4%64*3 + 2)
+		n := uint8(int(v) + rng.Intn(5) - 2)
+		copy(base.Pix[i:i+4], []uint8{v, v, v, 255})
+		copy(noisy.Pix[i:i+4], []uint8{n, n, n, 255})
+		copy(inverted.Pix[i:i+4], []uint8{255 - v, 255 - v, 255 - v, 255})
+	}
+
+	frames := make([]gocv.Mat, 0, 3)
+	for _, img := range []image.Image{base, noisy, inverted} {
+		mat, err := LoadImageFromStdImage(img)
+		if err != nil {
+			t.Fatalf("Failed to convert frame: %v", err)
+		}
+		defer mat.Close()
+		frames = append(frames, mat)
+	}
+
+	gate := newMotionGate(0.02, ColorOrderBGR)
+	defer gate.Close()
+
+	// Nothing to reuse before the first detection
+	if _, ok := gate.detections(frames[0]); ok {
+		t.Fatal("Expected no detections to reuse on the first frame")
+	}
+	boxes := []Detection{{Rect: image.Rect(10, 10, 30, 30), Quality: 5}}
+	gate.remember(boxes)
+
+	tests := []struct {
+		name  string
+		frame gocv.Mat
+		reuse bool
+	}{
+		{"Same frame", frames[0], true},
+		{"Slight noise", frames[1], true},
+		{"Scene change", frames[2], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := gate.detections(tt.frame)
+			if ok != tt.reuse {
+				t.Fatalf("Expected reuse=%v, got %v", tt.reuse, ok)
+			}
+			if ok && !reflect.DeepEqual(got, boxes) {
+				t.Errorf("Expected remembered detections %v, got %v", boxes, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package face
+
+import (
+	"math"
+	"sort"
+)
+
+// VerificationPair is a labeled pair of feature vectors for TuneThreshold
+type VerificationPair struct {
+	A, B []float32
+	Same bool // Both features belong to the same person
+}
+
+// ThresholdPoint is the verification performance at one candidate threshold.
+// A pair is accepted when its cosine similarity is at or above Threshold.
+type ThresholdPoint struct {
+	Threshold       float32
+	TrueAcceptRate  float32 // Share of same-person pairs accepted
+	FalseAcceptRate float32 // Share of different-person pairs accepted
+	Accuracy        float32 // Share of all pairs classified correctly
+}
+
+// ThresholdReport summarizes TuneThreshold
+type ThresholdReport struct {
+	// Points is the ROC curve, one point per candidate threshold in
+	// ascending order. Candidates are the pair similarities plus one
+	// threshold above all of them that accepts nothing.
+	Points []ThresholdPoint
+
+	// EERThreshold is the threshold where the false accept rate is closest
+	// to the false reject rate (1 - TrueAcceptRate); EER is their mean there
+	EERThreshold float32
+	EER          float32
+
+	// BestAccuracyThreshold maximizes Accuracy (the lowest one on ties)
+	BestAccuracyThreshold float32
+	BestAccuracy          float32
+}
+
+// TuneThreshold evaluates every useful cosine similarity threshold on a
+// labeled validation set, e.g. features from ExtractFeature of known same
+// and different person image pairs. Pass the chosen threshold to
+// WithSimilarityThreshold or SetThreshold of a recognizer using MetricCosine;
+// under MetricEuclidean the threshold is a distance and the report does not
+// apply.
+func TuneThreshold(pairs []VerificationPair) ThresholdReport {
+	if len(pairs) == 0 {
+		return ThresholdReport{}
+	}
+
+	type scored struct {
+		similarity float32
+		same       bool
+	}
+	scores := make([]scored, len(pairs))
+	var totalSame, totalDiff int
+	for i, pair := range pairs {
+		scores[i] = scored{cosineSimilarity(pair.A, pair.B), pair.Same}
+		if pair.Same {
+			totalSame++
+		} else {
+			totalDiff++
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].similarity < scores[j].similarity })
+
+	rate := func(n, total int) float32 {
+		if total == 0 {
+			return 0
+		}
+		return float32(n) / float32(total)
+	}
+
+	// Walk thresholds upwards; everything from index i on is accepted
+	acceptedSame, acceptedDiff := totalSame, totalDiff
+	point := func(threshold float32) ThresholdPoint {
+		correct := acceptedSame + (totalDiff - acceptedDiff)
+		return ThresholdPoint{
+			Threshold:       threshold,
+			TrueAcceptRate:  rate(acceptedSame, totalSame),
+			FalseAcceptRate: rate(acceptedDiff, totalDiff),
+			Accuracy:        rate(correct, len(scores)),
+		}
+	}
+
+	var report ThresholdReport
+	for i := 0; i < len(scores); {
+		threshold := scores[i].similarity
+		report.Points = append(report.Points, point(threshold))
+
+		// Pairs at this similarity are rejected by every higher threshold
+		for ; i < len(scores) && scores[i].similarity == threshold; i++ {
+			if scores[i].same {
+				acceptedSame--
+			} else {
+				acceptedDiff--
+			}
+		}
+	}
+	report.Points = append(report.Points, point(math.Nextafter32(scores[len(scores)-1].similarity, float32(math.Inf(1)))))
+
+	bestGap := float32(math.Inf(1))
+	report.BestAccuracy = -1
+	for _, p := range report.Points {
+		falseReject := 1 - p.TrueAcceptRate
+		if gap := float32(math.Abs(float64(p.FalseAcceptRate - falseReject))); gap < bestGap {
+			bestGap = gap
+			report.EERThreshold = p.Threshold
+			report.EER = (p.FalseAcceptRate + falseReject) / 2
+		}
+		if p.Accuracy > report.BestAccuracy {
+			report.BestAccuracy = p.Accuracy
+			report.BestAccuracyThreshold = p.Threshold
+		}
+	}
+
+	return report
+}
+
+// ThresholdForFAR returns the lowest threshold whose false accept rate is at
+// most maxFAR, i.e. the one accepting the most same-person pairs within that
+// budget. ok is false if the report has no points.
+func (r ThresholdReport) ThresholdForFAR(maxFAR float32) (threshold float32, ok bool) {
+	for _, p := range r.Points {
+		if p.FalseAcceptRate <= maxFAR {
+			return p.Threshold, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package face
+
+import (
+	"math"
+	"testing"
+)
+
+// pairWithSimilarity builds a pair of unit vectors with the given cosine similarity
+func pairWithSimilarity(similarity float32, same bool) VerificationPair {
+	return VerificationPair{
+		A:    []float32{1, 0},
+		B:    []float32{similarity, float32(math.Sqrt(float64(1 - similarity*similarity)))},
+		Same: same,
+	}
+}
+
+func TestTuneThreshold(t *testing.T) {
+	report := TuneThreshold([]VerificationPair{
+		pairWithSimilarity(0.9, true),
+		pairWithSimilarity(0.8, true),
+		pairWithSimilarity(0.7, true),
+		pairWithSimilarity(0.75, false),
+		pairWithSimilarity(0.3, false),
+		pairWithSimilarity(0.1, false),
+	})
+
+	near := func(a, b float32) bool { return math.Abs(float64(a-b)) < 1e-5 }
+
+	expected := []ThresholdPoint{
+		{0.1, 1, 1, 3.0 / 6},
+		{0.3, 1, 2.0 / 3, 4.0 / 6},
+		{0.7, 1, 1.0 / 3, 5.0 / 6},
+		{0.75, 2.0 / 3, 1.0 / 3, 4.0 / 6},
+		{0.8, 2.0 / 3, 0, 5.0 / 6},
+		{0.9, 1.0 / 3, 0, 4.0 / 6},
+		{0.9, 0, 0, 3.0 / 6}, // Just above the highest similarity
+	}
+	if len(report.Points) != len(expected) {
+		t.Fatalf("Expected %d points, got %d", len(expected), len(report.Points))
+	}
+	for i, p := range report.Points {
+		e := expected[i]
+		if !near(p.Threshold, e.Threshold) || !near(p.TrueAcceptRate, e.TrueAcceptRate) ||
+			!near(p.FalseAcceptRate, e.FalseAcceptRate) || !near(p.Accuracy, e.Accuracy) {
+			t.Errorf("Point %d: expected %+v, got %+v", i, e, p)
+		}
+	}
+
+	if !near(report.EERThreshold, 0.75) || !near(report.EER, 1.0/3) {
+		t.Errorf("Expected EER 0.333 at 0.75, got %.3f at %.3f", report.EER, report.EERThreshold)
+	}
+	if !near(report.BestAccuracyThreshold, 0.7) || !near(report.BestAccuracy, 5.0/6) {
+		t.Errorf("Expected best accuracy 0.833 at 0.7, got %.3f at %.3f", report.BestAccuracy, report.BestAccuracyThreshold)
+	}
+
+	tests := []struct {
+		maxFAR   float32
+		expected float32
+	}{
+		{0, 0.8},
+		{0.34, 0.7},
+		{1, 0.1},
+	}
+	for _, tt := range tests {
+		if threshold, ok := report.ThresholdForFAR(tt.maxFAR); !ok || !near(threshold, tt.expected) {
+			t.Errorf("FAR <= %.2f: expected threshold %.2f, got %.3f (ok=%v)", tt.maxFAR, tt.expected, threshold, ok)
+		}
+	}
+}
+
+func TestTuneThreshold_Empty(t *testing.T) {
+	report := TuneThreshold(nil)
+	if len(report.Points) != 0 {
+		t.Errorf("Expected no points, got %d", len(report.Points))
+	}
+	if _, ok := report.ThresholdForFAR(0.01); ok {
+		t.Error("Expected no threshold for an empty report")
+	}
+}
@@ -0,0 +1,185 @@
+package face
+
+import (
+	"image"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Track is a face followed across video frames
+type Track struct {
+	TrackID   int             // Stable ID, unique within a Tracker
+	Rect      image.Rectangle // Matched box, or the predicted box while missed
+	Detection int             // Index of the matched detection in this frame, -1 if missed
+	Age       int             // Frames since the track was created
+	Hits      int             // Frames in which the track was matched
+	Misses    int             // Consecutive frames without a match
+}
+
+// trackState is a track together with its motion estimate
+type trackState struct {
+	Track
+	observed image.Rectangle // Last matched box
+	vx, vy   float64         // Smoothed center velocity in pixels per frame
+}
+
+// trackVelocitySmoothing weighs the latest displacement against the previous
+// velocity estimate
+const trackVelocitySmoothing = 0.5
+
+// Tracker defaults, used when the fields are left at zero
+const (
+	defaultTrackIoUThreshold = 0.3
+	defaultTrackMaxMisses    = 5
+)
+
+// Tracker assigns stable track IDs to face boxes across frames. It only
+// looks at boxes, so detection can run every frame while recognition runs
+// every N frames and is attached to tracks by ID.
+//
+// Detections are associated with the tracks whose predicted boxes (last box
+// moved by a smoothed velocity) overlap them most, greedily by IoU. A
+// Tracker is safe for concurrent use.
+type Tracker struct {
+	IoUThreshold float64 // Minimum IoU to continue a track (0 means the default 0.3)
+	MaxMisses    int     // Frames a track survives without a match (0 means the default 5)
+
+	mu     sync.Mutex
+	tracks []*trackState
+	nextID int
+}
+
+// NewTracker creates a tracker with default settings
+func NewTracker() *Tracker {
+	return &Tracker{
+		IoUThreshold: defaultTrackIoUThreshold,
+		MaxMisses:    defaultTrackMaxMisses,
+	}
+}
+
+// Update associates the detections of a new frame with the existing tracks
+// and returns all live tracks sorted by TrackID. Unmatched detections start
+// new tracks; tracks missed for more than MaxMisses frames are dropped.
+func (t *Tracker) Update(detections []image.Rectangle) []Track {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	iouThreshold, maxMisses := t.IoUThreshold, t.MaxMisses
+	if iouThreshold == 0 {
+		iouThreshold = defaultTrackIoUThreshold
+	}
+	if maxMisses == 0 {
+		maxMisses = defaultTrackMaxMisses
+	}
+
+	// Score every predicted track box against every detection
+	type pair struct {
+		track, detection int
+		iou              float64
+	}
+	var pairs []pair
+	predicted := make([]image.Rectangle, len(t.tracks))
+	for i, track := range t.tracks {
+		predicted[i] = track.predict()
+		for j, det := range detections {
+			if iou := IoU(predicted[i], det); iou >= iouThreshold && iou > 0 {
+				pairs = append(pairs, pair{i, j, iou})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool { return pairs[a].iou > pairs[b].iou })
+
+	// Greedy assignment, best overlap first
+	trackMatched := make([]bool, len(t.tracks))
+	detectionMatched := make([]bool, len(detections))
+	for _, p := range pairs {
+		if trackMatched[p.track] || detectionMatched[p.detection] {
+			continue
+		}
+		trackMatched[p.track] = true
+		detectionMatched[p.detection] = true
+		t.tracks[p.track].correct(detections[p.detection], p.detection)
+	}
+
+	live := t.tracks[:0]
+	for i, track := range t.tracks {
+		track.Age++
+		if !trackMatched[i] {
+			track.Misses++
+			track.Detection = -1
+			track.Rect = predicted[i]
+			if track.Misses > maxMisses {
+				continue
+			}
+		}
+		live = append(live, track)
+	}
+	t.tracks = live
+
+	for j, det := range detections {
+		if detectionMatched[j] {
+			continue
+		}
+		t.nextID++
+		t.tracks = append(t.tracks, &trackState{
+			Track: Track{
+				TrackID:   t.nextID,
+				Rect:      det,
+				Detection: j,
+				Age:       1,
+				Hits:      1,
+			},
+			observed: det,
+		})
+	}
+
+	tracks := make([]Track, 0, len(t.tracks))
+	for _, track := range t.tracks {
+		tracks = append(tracks, track.Track)
+	}
+	sort.Slice(tracks, func(a, b int) bool { return tracks[a].TrackID < tracks[b].TrackID })
+
+	return tracks
+}
+
+// Reset drops all tracks. Track IDs keep increasing across resets.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.tracks = nil
+}
+
+// predict returns the box expected in the next frame
+func (s *trackState) predict() image.Rectangle {
+	frames := float64(s.Misses + 1)
+	return s.observed.Add(image.Pt(int(math.Round(s.vx*frames)), int(math.Round(s.vy*frames))))
+}
+
+// correct updates the track with a matched detection
+func (s *trackState) correct(det image.Rectangle, index int) {
+	// Displacement since the last observed box, spread over missed frames
+	frames := float64(s.Misses + 1)
+	dx := float64(rectCenter(det).X-rectCenter(s.observed).X) / frames
+	dy := float64(rectCenter(det).Y-rectCenter(s.observed).Y) / frames
+
+	if s.Hits == 1 {
+		// Second observation: no velocity estimate to smooth yet
+		s.vx, s.vy = dx, dy
+	} else {
+		s.vx = trackVelocitySmoothing*dx + (1-trackVelocitySmoothing)*s.vx
+		s.vy = trackVelocitySmoothing*dy + (1-trackVelocitySmoothing)*s.vy
+	}
+
+	s.Rect = det
+	s.observed = det
+	s.Detection = index
+	s.Hits++
+	s.Misses = 0
+}
+
+// rectCenter returns the center point of a rectangle
+func rectCenter(r image.Rectangle) image.Point {
+	return r.Min.Add(r.Max).Div(2)
+}
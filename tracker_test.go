@@ -0,0 +1,111 @@
+package face
+
+import (
+	"image"
+	"testing"
+)
+
+func TestTracker_KeepsIDsForMovingFaces(t *testing.T) {
+	tracker := NewTracker()
+
+	// Two faces moving in opposite directions
+	var first []Track
+	for frame := 0; frame < 10; frame++ {
+		tracks := tracker.Update([]image.Rectangle{
+			image.Rect(10+frame*8, 50, 110+frame*8, 150),
+			image.Rect(400-frame*8, 50, 500-frame*8, 150),
+		})
+		if len(tracks) != 2 {
+			t.Fatalf("Frame %d: expected 2 tracks, got %d", frame, len(tracks))
+		}
+		if frame == 0 {
+			first = tracks
+			continue
+		}
+		for i := range tracks {
+			if tracks[i].TrackID != first[i].TrackID {
+				t.Errorf("Frame %d: expected track ID %d, got %d", frame, first[i].TrackID, tracks[i].TrackID)
+			}
+			if tracks[i].Detection != i || tracks[i].Misses != 0 {
+				t.Errorf("Frame %d: expected track %d matched to detection %d, got %+v", frame, tracks[i].TrackID, i, tracks[i])
+			}
+		}
+	}
+
+	if first[0].TrackID == first[1].TrackID {
+		t.Error("Expected distinct track IDs")
+	}
+}
+
+func TestTracker_PredictsThroughMissedFrames(t *testing.T) {
+	tracker := NewTracker()
+
+	// A fast face: boxes two frames apart barely overlap, so only the
+	// velocity prediction can bridge a missed frame
+	box := func(frame int) image.Rectangle {
+		return image.Rect(frame*25, 0, frame*25+60, 60)
+	}
+
+	var id int
+	for frame := 0; frame < 4; frame++ {
+		id = tracker.Update([]image.Rectangle{box(frame)})[0].TrackID
+	}
+
+	// Detector misses the face for one frame
+	tracks := tracker.Update(nil)
+	if len(tracks) != 1 || tracks[0].Misses != 1 || tracks[0].Detection != -1 {
+		t.Fatalf("Expected one coasting track, got %+v", tracks)
+	}
+	if tracks[0].Rect != box(4) {
+		t.Errorf("Expected predicted box %v, got %v", box(4), tracks[0].Rect)
+	}
+
+	tracks = tracker.Update([]image.Rectangle{box(5)})
+	if len(tracks) != 1 || tracks[0].TrackID != id {
+		t.Errorf("Expected face to be reacquired as track %d, got %+v", id, tracks)
+	}
+}
+
+func TestTracker_AgesOutStaleTracks(t *testing.T) {
+	tracker := NewTracker()
+	tracker.MaxMisses = 2
+
+	first := tracker.Update([]image.Rectangle{image.Rect(0, 0, 50, 50)})[0].TrackID
+
+	for frame := 1; frame <= 2; frame++ {
+		if tracks := tracker.Update(nil); len(tracks) != 1 {
+			t.Fatalf("Frame %d: expected track to survive, got %d tracks", frame, len(tracks))
+		}
+	}
+	if tracks := tracker.Update(nil); len(tracks) != 0 {
+		t.Fatalf("Expected track to be dropped after %d misses, got %+v", tracker.MaxMisses, tracks)
+	}
+
+	// The same place later is a new track
+	tracks := tracker.Update([]image.Rectangle{image.Rect(0, 0, 50, 50)})
+	if len(tracks) != 1 || tracks[0].TrackID == first {
+		t.Errorf("Expected a new track ID, got %+v", tracks)
+	}
+}
+
+func TestTracker_ZeroValueUsesDefaults(t *testing.T) {
+	var tracker Tracker
+
+	// 0.25 IoU with the first box: below the default threshold, a new track
+	first := tracker.Update([]image.Rectangle{image.Rect(0, 0, 50, 50)})[0].TrackID
+	tracks := tracker.Update([]image.Rectangle{image.Rect(30, 0, 80, 50)})
+	if len(tracks) != 2 || tracks[1].TrackID == first {
+		t.Fatalf("Expected a low-overlap box to start a new track, got %+v", tracks)
+	}
+
+	// The new track survives the default number of misses, then is dropped
+	second := tracks[1].TrackID
+	for frame := 1; frame <= defaultTrackMaxMisses; frame++ {
+		if tracks := tracker.Update(nil); len(tracks) == 0 || tracks[len(tracks)-1].TrackID != second {
+			t.Fatalf("Frame %d: expected track %d to survive, got %+v", frame, second, tracks)
+		}
+	}
+	if tracks := tracker.Update(nil); len(tracks) != 0 {
+		t.Errorf("Expected tracks to be dropped after %d misses, got %+v", defaultTrackMaxMisses, tracks)
+	}
+}